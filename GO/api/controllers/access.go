@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/gin-gonic/gin"
+)
+
+type AccessController struct {
+	AccessUseCase domain.AccessUseCase
+}
+
+// GrantFileAccess godoc
+// @Summary      Grant another user access to a file
+// @Description  Gives the user read or write access to a file of the caller. Granting again changes the permission
+// @Tags         access
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "File ID"
+// @Param        request body domain.GrantRequest true "User and permission"
+// @Success      200 {object} domain.Grant
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/{id}/grants [post]
+// @Security     BearerAuth
+func (ac *AccessController) GrantFileAccess(c *gin.Context) {
+	ac.grantAccess(c, domain.ResourceFile)
+}
+
+// GetFileGrants godoc
+// @Summary      List grants on a file
+// @Tags         access
+// @Produce      json
+// @Param        id path string true "File ID"
+// @Success      200 {array} domain.Grant
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/{id}/grants [get]
+// @Security     BearerAuth
+func (ac *AccessController) GetFileGrants(c *gin.Context) {
+	ac.getGrants(c, domain.ResourceFile)
+}
+
+// RevokeFileAccess godoc
+// @Summary      Revoke a user's access to a file
+// @Description  The owner can revoke any grant; other users can give up their own
+// @Tags         access
+// @Produce      json
+// @Param        id path string true "File ID"
+// @Param        userId path int true "User ID"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/{id}/grants/{userId} [delete]
+// @Security     BearerAuth
+func (ac *AccessController) RevokeFileAccess(c *gin.Context) {
+	ac.revokeAccess(c, domain.ResourceFile)
+}
+
+// GrantFolderAccess godoc
+// @Summary      Grant another user access to a folder
+// @Description  Gives the user read or write access to a folder of the caller and everything below it. Granting again changes the permission
+// @Tags         access
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "Folder ID"
+// @Param        request body domain.GrantRequest true "User and permission"
+// @Success      200 {object} domain.Grant
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/folders/{id}/grants [post]
+// @Security     BearerAuth
+func (ac *AccessController) GrantFolderAccess(c *gin.Context) {
+	ac.grantAccess(c, domain.ResourceFolder)
+}
+
+// GetFolderGrants godoc
+// @Summary      List grants on a folder
+// @Tags         access
+// @Produce      json
+// @Param        id path string true "Folder ID"
+// @Success      200 {array} domain.Grant
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/folders/{id}/grants [get]
+// @Security     BearerAuth
+func (ac *AccessController) GetFolderGrants(c *gin.Context) {
+	ac.getGrants(c, domain.ResourceFolder)
+}
+
+// RevokeFolderAccess godoc
+// @Summary      Revoke a user's access to a folder
+// @Description  The owner can revoke any grant; other users can give up their own
+// @Tags         access
+// @Produce      json
+// @Param        id path string true "Folder ID"
+// @Param        userId path int true "User ID"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/folders/{id}/grants/{userId} [delete]
+// @Security     BearerAuth
+func (ac *AccessController) RevokeFolderAccess(c *gin.Context) {
+	ac.revokeAccess(c, domain.ResourceFolder)
+}
+
+// GetSharedWithMe godoc
+// @Summary      List files and folders shared with the caller
+// @Description  Returns everything other users granted the authenticated user access to, with the permission of each grant
+// @Tags         access
+// @Produce      json
+// @Success      200 {object} domain.SharedWithMe
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/shared [get]
+// @Security     BearerAuth
+func (ac *AccessController) GetSharedWithMe(c *gin.Context) {
+	shared, err := ac.AccessUseCase.GetSharedWithMe(c.Request.Context(), callerID(c))
+	if err != nil {
+		c.JSON(accessErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, shared)
+}
+
+func (ac *AccessController) grantAccess(c *gin.Context, resourceType domain.ResourceType) {
+	var request domain.GrantRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "error parsing the request"})
+		return
+	}
+
+	resource := domain.Resource{Type: resourceType, ID: c.Param("id")}
+	grant, err := ac.AccessUseCase.GrantAccess(c.Request.Context(), callerID(c), resource, request)
+	if err != nil {
+		c.JSON(accessErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, grant)
+}
+
+func (ac *AccessController) getGrants(c *gin.Context, resourceType domain.ResourceType) {
+	resource := domain.Resource{Type: resourceType, ID: c.Param("id")}
+	grants, err := ac.AccessUseCase.GetGrants(c.Request.Context(), callerID(c), resource)
+	if err != nil {
+		c.JSON(accessErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, grants)
+}
+
+func (ac *AccessController) revokeAccess(c *gin.Context, resourceType domain.ResourceType) {
+	userID, err := strconv.Atoi(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	resource := domain.Resource{Type: resourceType, ID: c.Param("id")}
+	if err := ac.AccessUseCase.RevokeAccess(c.Request.Context(), callerID(c), resource, uint(userID)); err != nil {
+		c.JSON(accessErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "access revoked"})
+}
+
+// callerID returns the authenticated user, stored by JwtAuthMiddleware or
+// DavAuthMiddleware.
+func callerID(c *gin.Context) uint {
+	return uint(c.GetInt("user_id"))
+}
+
+func accessErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrFileNotFound), errors.Is(err, domain.ErrFolderNotFound), errors.Is(err, domain.ErrGrantNotFound),
+		err.Error() == "user not found":
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrInvalidGrant):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -1,31 +1,46 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/integrity"
 	"github.com/gin-gonic/gin"
 )
 
 type FileController struct {
 	FileUseCase domain.FileUseCase
+	// MaxUploadParts limits how many files a single upload request may contain
+	MaxUploadParts int
 }
 
 // UploadFile godoc
-// @Summary      Upload a file for a user
-// @Description  Uploads a file linked to the user ID
+// @Summary      Upload files for a user
+// @Description  Uploads any number of files linked to the user ID in one multipart request. Each file part is streamed to storage and scanned for malware on the way, and the response lists the result of every file in the order sent. folderId, tags, expiresAt and ttl fields apply to the file parts after them; tags are added to those an existing file already has and an expiry replaces its expiry. Expired files are deleted for good. A file part may carry Content-MD5, Content-Digest or Repr-Digest headers; a file that doesn't match them fails with 400 and is not stored. Other callers need write access to the folder, or to the file when it already exists. The content type is detected from the content; a declared type that contradicts it or a type the upload policy rejects fails that file. Files that were stored are kept when others fail: the status is 200 when every file was stored, 207 when some were and otherwise the status of the first failure. More files than the part limit stop the request with 413, and a form field that is too long or a request that can't be read stop it with 400. Files stored before that are kept and the response's error says why
 // @Tags         files
 // @Accept       multipart/form-data
 // @Produce      json
 // @Param        id path int true "User ID"
-// @Param        file formData file true "File to upload"
-// @Success      200 {object} map[string]string
+// @Param        file formData file true "Files to upload; the part name doesn't matter"
+// @Param        folderId formData string false "Folder to upload the following files into; the root when empty"
+// @Param        tags formData string false "Comma separated tags of the following files"
+// @Param        expiresAt formData string false "RFC 3339 time the following files expire at"
+// @Param        ttl formData string false "Lifetime of the following files, e.g. 24h"
+// @Success      200 {object} domain.UploadResponse
+// @Success      207 {object} domain.UploadResponse
 // @Failure      400 {object} map[string]string
-// @Failure      404 {object} map[string]string
+// @Failure      403 {object} domain.UploadResponse
+// @Failure      404 {object} domain.UploadResponse
+// @Failure      413 {object} domain.UploadResponse
+// @Failure      415 {object} domain.UploadResponse
 // @Failure      500 {object} map[string]string
-// @Router       /public/api/files/{id} [post]
+// @Router       /private/api/files/{id} [post]
 // @Security     BearerAuth
 func (fc *FileController) UploadFile(c *gin.Context) {
 	idParam := c.Param("id")
@@ -35,49 +50,268 @@ func (fc *FileController) UploadFile(c *gin.Context) {
 		return
 	}
 
-	file, err := c.FormFile("file")
+	reader, err := c.Request.MultipartReader()
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to get file"})
 		return
 	}
 
-	src, err := file.Open()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open file"})
+	// Parts are read one at a time, so no file is buffered before it is stored
+	folderID := c.Query("folderId")
+	tags := queryList(c, "tags")
+	expiresAt, expiryErr := parseExpiry(c.Query("expiresAt"), c.Query("ttl"))
+	var results []domain.UploadResult
+	var firstErr error
+	// The files stored before the request is rejected are kept; the rest of it is not read
+	var rejected error
+	rejectedStatus := http.StatusBadRequest
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rejected = fmt.Errorf("failed to read request: %w", err)
+			break
+		}
+
+		if part.FileName() == "" {
+			limit, ok := formFieldLimits[part.FormName()]
+			if !ok {
+				continue
+			}
+			value, err := readFormField(part, part.FormName(), limit)
+			if err != nil {
+				rejected = err
+				break
+			}
+			switch part.FormName() {
+			case "folderId":
+				folderID = value
+			case "tags":
+				tags = splitList([]string{value})
+			case "expiresAt":
+				expiresAt, expiryErr = parseExpiry(value, "")
+			case "ttl":
+				expiresAt, expiryErr = parseExpiry("", value)
+			}
+			continue
+		}
+
+		// Failed files count too, so a client can't make the server read on forever
+		if len(results) >= fc.MaxUploadParts {
+			rejected, rejectedStatus = domain.ErrTooManyFiles, http.StatusRequestEntityTooLarge
+			break
+		}
+
+		result := domain.UploadResult{Filename: part.FileName()}
+		// Each part carries the digests of its own content
+		digests, digestErr := integrity.FromHeader(part.Header)
+		switch {
+		case expiryErr != nil:
+			err = expiryErr
+		case digestErr != nil:
+			err = digestErr
+		default:
+			var meta *domain.UserFileMeta
+			meta, err = fc.FileUseCase.UploadFile(c.Request.Context(), callerID(c), uint(userID), folderID, part.FileName(), part.Header.Get("Content-Type"), tags, expiresAt, digests, part)
+			if err == nil {
+				result = domain.UploadResult{ID: meta.ID, Filename: meta.Filename, Size: meta.Size, ScanStatus: meta.ScanStatus}
+			}
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	if len(results) == 0 {
+		message := "failed to get file"
+		if rejected != nil {
+			message = rejected.Error()
+		}
+		c.JSON(rejectedStatus, gin.H{"error": message})
 		return
 	}
-	defer src.Close()
 
-	data, err := io.ReadAll(src)
+	status := http.StatusOK
+	response := domain.UploadResponse{Uploaded: uploaded(results), Failed: len(results) - uploaded(results), Files: results}
+	switch {
+	case rejected != nil:
+		status = rejectedStatus
+		response.Error = rejected.Error()
+	case firstErr != nil:
+		status = http.StatusMultiStatus
+		if uploaded(results) == 0 {
+			status = fileErrorStatus(firstErr)
+		}
+	}
+
+	c.JSON(status, response)
+}
+
+// formFieldLimits are the form fields an upload reads, with the longest value
+// each may have.
+var formFieldLimits = map[string]int64{
+	"folderId":  1024,
+	"tags":      4096,
+	"expiresAt": 1024,
+	"ttl":       1024,
+}
+
+// readFormField reads the value of the form field name, which may be at most
+// limit bytes long.
+func readFormField(part io.Reader, name string, limit int64) (string, error) {
+	value, err := io.ReadAll(io.LimitReader(part, limit+1))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read file"})
-		return
+		return "", fmt.Errorf("failed to read request: %w", err)
 	}
+	if int64(len(value)) > limit {
+		return "", fmt.Errorf("%s field is longer than %d bytes", name, limit)
+	}
+	return string(value), nil
+}
 
-	err = fc.FileUseCase.UploadFile(c.Request.Context(), uint(userID), file.Filename, file.Header.Get("Content-Type"), data)
+// parseExpiry reads an expiry given as an RFC 3339 time or as a lifetime
+// like "24h". Neither means no expiry.
+func parseExpiry(expiresAt, ttl string) (*time.Time, error) {
+	if expiresAt == "" {
+		return expiryTime(nil, ttl)
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(expiresAt))
 	if err != nil {
-		if err.Error() == "user not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
+		return nil, domain.ErrInvalidExpiry
+	}
+	return expiryTime(&t, ttl)
+}
+
+// expiryTime resolves a lifetime to the time it ends. Only one of expiresAt
+// and ttl may be given.
+func expiryTime(expiresAt *time.Time, ttl string) (*time.Time, error) {
+	ttl = strings.TrimSpace(ttl)
+	if ttl == "" {
+		return expiresAt, nil
+	}
+	d, err := time.ParseDuration(ttl)
+	if err != nil || d <= 0 || expiresAt != nil {
+		return nil, domain.ErrInvalidExpiry
+	}
+	t := time.Now().Add(d)
+	return &t, nil
+}
+
+// uploaded counts the results of files that were stored.
+func uploaded(results []domain.UploadResult) int {
+	n := 0
+	for _, result := range results {
+		if result.Error == "" {
+			n++
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return n
+}
+
+// HasContent godoc
+// @Summary      Check whether the server has content
+// @Description  Returns 200 when one of the user's files holds clean content with the hex SHA-256 digest, as returned in a file's digest, and 404 otherwise. Such content can be stored again without being sent. Only the user's own files are looked at
+// @Tags         files
+// @Param        id path int true "User ID"
+// @Param        digest path string true "Hex SHA-256 of the content"
+// @Success      200
+// @Failure      400
+// @Failure      403
+// @Failure      404
+// @Failure      500
+// @Router       /private/api/files/user/{id}/blobs/{digest} [head]
+// @Security     BearerAuth
+func (fc *FileController) HasContent(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	found, err := fc.FileUseCase.HasContent(c.Request.Context(), callerID(c), uint(userID), c.Param("digest"))
+	if err != nil {
+		c.Status(fileErrorStatus(err))
+		return
+	}
+	if !found {
+		c.Status(http.StatusNotFound)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "file uploaded successfully"})
+	c.Status(http.StatusOK)
 }
 
+// UploadKnownContent godoc
+// @Summary      Store content the server already has
+// @Description  Stores the content with the hex SHA-256 digest, which one of the user's files already holds, as a new file or a new version of the file with the name, without the content being sent. Names, tags, expiry, type policy and quota work as for a regular upload
+// @Tags         files
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "User ID"
+// @Param        digest path string true "Hex SHA-256 of the content"
+// @Param        request body domain.KnownContentUpload true "File to store the content as"
+// @Success      200 {object} domain.UserFileMeta
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      413 {object} map[string]string
+// @Failure      415 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/user/{id}/blobs/{digest} [post]
+// @Security     BearerAuth
+func (fc *FileController) UploadKnownContent(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var body domain.KnownContentUpload
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "error parsing the request"})
+		return
+	}
+	expiresAt, err := expiryTime(body.ExpiresAt, body.TTL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	meta, err := fc.FileUseCase.UploadKnownContent(c.Request.Context(), callerID(c), uint(userID), body.FolderID, body.Filename, body.Tags, expiresAt, c.Param("digest"))
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, meta)
+}
 
 // DownloadFile godoc
 // @Summary      Download a user file
-// @Description  Downloads a file by its ID
+// @Description  Downloads a file by its ID. Supports byte ranges (single and multipart), ETag and Last-Modified validators. Files stored compressed are sent compressed with Content-Encoding when the client accepts the codec and asks for no range. Files that have not passed the malware scan yet return 409; quarantined files return 403
 // @Tags         files
 // @Produce      application/octet-stream
 // @Param        id path string true "File ID"
+// @Param        disposition query string false "Content-Disposition type" Enums(attachment, inline) default(attachment)
+// @Param        Range header string false "Byte ranges, e.g. bytes=0-1023"
+// @Param        Accept-Encoding header string false "Accepted content codings, e.g. gzip, zstd"
+// @Param        If-None-Match header string false "ETag of a cached copy"
+// @Param        If-Modified-Since header string false "Date of a cached copy"
 // @Success      200 {file} file
+// @Success      206 {file} file
+// @Success      304
 // @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
 // @Failure      404 {object} map[string]string
-// @Router       /public/api/files/{id} [get]
+// @Failure      409 {object} map[string]string
+// @Failure      416
+// @Router       /private/api/files/{id} [get]
 // @Security     BearerAuth
 func (fc *FileController) DownloadFile(c *gin.Context) {
 	id := c.Param("id")
@@ -87,25 +321,111 @@ func (fc *FileController) DownloadFile(c *gin.Context) {
 	}
 
 	ctx := c.Request.Context()
-	file, err := fc.FileUseCase.GetFileByID(ctx, id)
+	file, content, err := fc.FileUseCase.DownloadFile(ctx, callerID(c), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	serveFileContent(c, file, content)
+}
+
+// UpdateFile godoc
+// @Summary      Update a file
+// @Description  Renames a file, moves it to another folder or changes its content type, description or custom metadata. The content type can only be changed to a more general type of the detected one. Omitted fields are unchanged; an empty folderId moves the file to the root and a null metadata value removes the key
+// @Tags         files
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "File ID"
+// @Param        request body domain.FileUpdate true "Fields to change"
+// @Success      200 {object} domain.UserFile
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      415 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/{id} [patch]
+// @Security     BearerAuth
+func (fc *FileController) UpdateFile(c *gin.Context) {
+	var update domain.FileUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "error parsing the request"})
+		return
+	}
+
+	file, err := fc.FileUseCase.UpdateFile(c.Request.Context(), callerID(c), c.Param("id"), update)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+// ResolvePath godoc
+// @Summary      Find a file by path
+// @Description  Resolves a slash separated path such as /reports/2026/q3.pdf, where every segment but the last is a folder, to the file's metadata. Paths the caller may not read return 404 like paths that don't exist
+// @Tags         files
+// @Produce      json
+// @Param        id path int true "User ID"
+// @Param        path query string true "Path of the file"
+// @Success      200 {object} domain.UserFile
+// @Failure      400 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/user/{id}/resolve [get]
+// @Security     BearerAuth
+func (fc *FileController) ResolvePath(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	file, err := fc.FileUseCase.ResolvePath(c.Request.Context(), callerID(c), uint(userID), c.Query("path"))
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+// DeleteFile godoc
+// @Summary      Move a file to the trash
+// @Description  Moves a file with all of its versions to the owner's trash. It stops counting as a file of its folder but keeps its storage usage until the trash is emptied or the file is purged after the retention period
+// @Tags         files
+// @Produce      json
+// @Param        id path string true "File ID"
+// @Success      200 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/{id} [delete]
+// @Security     BearerAuth
+func (fc *FileController) DeleteFile(c *gin.Context) {
+	if err := fc.FileUseCase.DeleteFile(c.Request.Context(), callerID(c), c.Param("id")); err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.Header("Content-Disposition", "attachment; filename="+file.Filename)
-	c.Data(http.StatusOK, "application/octet-stream", file.Data)
+	c.JSON(http.StatusOK, gin.H{"message": "file moved to trash"})
 }
 
 // GetFilesByUser godoc
 // @Summary      Get all files for a user
-// @Description  Returns file IDs and names for a user ID
+// @Description  Returns the files of a user ID ordered by name, without their version history, optionally only those with any or all of the given tags. Only the user can list their files
 // @Tags         files
 // @Produce      json
 // @Param        id path int true "User ID"
+// @Param        tags query []string false "Tags to filter by" collectionFormat(csv)
+// @Param        tagMatch query string false "Whether files need any or all of the tags" Enums(any, all) default(any)
 // @Success      200 {array} domain.UserFileMeta
 // @Failure      400 {object} map[string]string
-// @Router       /public/api/files/user/{id} [get]
+// @Failure      403 {object} map[string]string
+// @Router       /private/api/files/user/{id} [get]
 // @Security     BearerAuth
 func (fc *FileController) GetFilesByUser(c *gin.Context) {
 	idParam := c.Param("id")
@@ -115,24 +435,337 @@ func (fc *FileController) GetFilesByUser(c *gin.Context) {
 		return
 	}
 
-	files, err := fc.FileUseCase.GetFilesByUserID(c.Request.Context(), uint(userID))
+	tags, ok := tagFilter(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tagMatch"})
+		return
+	}
+
+	files, err := fc.FileUseCase.GetFilesByUserID(c.Request.Context(), callerID(c), uint(userID), tags)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, files)
 }
 
+// tagFilter reads the tags and tagMatch query parameters.
+func tagFilter(c *gin.Context) (domain.TagFilter, bool) {
+	match := c.DefaultQuery("tagMatch", "any")
+	if match != "any" && match != "all" {
+		return domain.TagFilter{}, false
+	}
+	return domain.TagFilter{Tags: queryList(c, "tags"), All: match == "all"}, true
+}
+
+// queryList returns the values of a query parameter that may be repeated or
+// comma separated.
+func queryList(c *gin.Context, key string) []string {
+	return splitList(c.QueryArray(key))
+}
+
+func splitList(values []string) []string {
+	var list []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+// fileSearchQuery is the query string of a file search.
+type fileSearchQuery struct {
+	Name           string     `form:"name"`
+	ContentType    string     `form:"contentType"`
+	MinSize        *int64     `form:"minSize"`
+	MaxSize        *int64     `form:"maxSize"`
+	UploadedAfter  *time.Time `form:"uploadedAfter" time_format:"2006-01-02T15:04:05.999999999Z07:00"`
+	UploadedBefore *time.Time `form:"uploadedBefore" time_format:"2006-01-02T15:04:05.999999999Z07:00"`
+	Sort           string     `form:"sort"`
+	Order          string     `form:"order"`
+	Limit          int        `form:"limit"`
+	Cursor         string     `form:"cursor"`
+}
+
+// SearchFiles godoc
+// @Summary      Search a user's files
+// @Description  Returns a page of the user's files matching the filters, without their version history. name matches filenames containing it, or the whole filename as a glob when it has * or ?, ignoring case. contentType matches exactly or a family like image/*. Sizes are inclusive, uploadedAfter is inclusive and uploadedBefore exclusive. Pass nextCursor as cursor with the same sort and order to get the next page. Only the user can search their files
+// @Tags         files
+// @Produce      json
+// @Param        id path int true "User ID"
+// @Param        name query string false "Filename substring or glob"
+// @Param        contentType query string false "Content type or family" example(image/*)
+// @Param        minSize query int false "Minimum size in bytes"
+// @Param        maxSize query int false "Maximum size in bytes"
+// @Param        uploadedAfter query string false "RFC 3339 timestamp"
+// @Param        uploadedBefore query string false "RFC 3339 timestamp"
+// @Param        tags query []string false "Tags to filter by" collectionFormat(csv)
+// @Param        tagMatch query string false "Whether files need any or all of the tags" Enums(any, all) default(any)
+// @Param        sort query string false "Sort field" Enums(name, size, uploadedAt, contentType) default(name)
+// @Param        order query string false "Sort order" Enums(asc, desc) default(asc)
+// @Param        limit query int false "Page size, at most 500" default(50)
+// @Param        cursor query string false "Cursor of the next page"
+// @Success      200 {object} domain.FileSearchResult
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/user/{id}/search [get]
+// @Security     BearerAuth
+func (fc *FileController) SearchFiles(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var query fileSearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil || (query.Order != "" && query.Order != "asc" && query.Order != "desc") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "error parsing the request"})
+		return
+	}
+	tags, ok := tagFilter(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tagMatch"})
+		return
+	}
+
+	result, err := fc.FileUseCase.SearchFiles(c.Request.Context(), callerID(c), uint(userID), domain.FileSearch{
+		Name:           query.Name,
+		ContentType:    query.ContentType,
+		MinSize:        query.MinSize,
+		MaxSize:        query.MaxSize,
+		UploadedAfter:  query.UploadedAfter,
+		UploadedBefore: query.UploadedBefore,
+		Tags:           tags,
+		Sort:           domain.FileSort(query.Sort),
+		Desc:           query.Order == "desc",
+		Limit:          query.Limit,
+		Cursor:         query.Cursor,
+	})
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// SearchContent godoc
+// @Summary      Search the content of files
+// @Description  Searches the text of plain text, CSV, JSON, Markdown and HTML files the caller owns or was granted read access to, best match first. Words match any of them and their stems, "quoted phrases" must appear and -words must not. Each result has a snippet of the matching text as HTML with the matching words wrapped in <mark>
+// @Tags         files
+// @Produce      json
+// @Param        q query string true "Search query"
+// @Param        limit query int false "Number of results, at most 100" default(20)
+// @Success      200 {array} domain.ContentSearchHit
+// @Failure      400 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/search [get]
+// @Security     BearerAuth
+func (fc *FileController) SearchContent(c *gin.Context) {
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+	}
+
+	hits, err := fc.FileUseCase.SearchContent(c.Request.Context(), callerID(c), c.Query("q"), limit)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, hits)
+}
+
+// SetFileTags godoc
+// @Summary      Set the tags of a file
+// @Description  Replaces the tags of a file. Tags are lowercased with their whitespace collapsed, can't contain commas and a file has at most 32. An empty list removes every tag. Other callers need write access to the file
+// @Tags         files
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "File ID"
+// @Param        request body domain.FileTags true "Tags of the file"
+// @Success      200 {object} domain.UserFileMeta
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/{id}/tags [put]
+// @Security     BearerAuth
+func (fc *FileController) SetFileTags(c *gin.Context) {
+	var body domain.FileTags
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "error parsing the request"})
+		return
+	}
+
+	meta, err := fc.FileUseCase.SetFileTags(c.Request.Context(), callerID(c), c.Param("id"), body.Tags)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, meta)
+}
+
+// SetFileExpiry godoc
+// @Summary      Set when a file expires
+// @Description  Sets the time a file is deleted for good, as expiresAt or as a ttl from now such as 72h, to extend or shorten its lifetime. Sending neither keeps the file until it is deleted. Other callers need write access to the file
+// @Tags         files
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "File ID"
+// @Param        request body domain.FileExpiry true "New expiry"
+// @Success      200 {object} domain.UserFileMeta
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/{id}/expiry [put]
+// @Security     BearerAuth
+func (fc *FileController) SetFileExpiry(c *gin.Context) {
+	var body domain.FileExpiry
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "error parsing the request"})
+		return
+	}
+	expiresAt, err := expiryTime(body.ExpiresAt, body.TTL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	meta, err := fc.FileUseCase.SetFileExpiry(c.Request.Context(), callerID(c), c.Param("id"), expiresAt)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, meta)
+}
+
+// GetTags godoc
+// @Summary      List the tags of a user
+// @Description  Returns every tag on the user's files with the number of files carrying it, most used first. Files in the trash don't count. Only the user can list their tags
+// @Tags         files
+// @Produce      json
+// @Param        id path int true "User ID"
+// @Success      200 {array} domain.TagCount
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/user/{id}/tags [get]
+// @Security     BearerAuth
+func (fc *FileController) GetTags(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	tags, err := fc.FileUseCase.GetTags(c.Request.Context(), callerID(c), uint(userID))
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// RenameTags godoc
+// @Summary      Rename or merge tags
+// @Description  Replaces the from tags with the to tag on every file of the user, including files in the trash. Renaming several tags, or to a tag that is already in use, merges them. Only the user can rename their tags
+// @Tags         files
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "User ID"
+// @Param        request body domain.TagRename true "Tags to rename"
+// @Success      200 {object} map[string]int
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/user/{id}/tags/rename [post]
+// @Security     BearerAuth
+func (fc *FileController) RenameTags(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var rename domain.TagRename
+	if err := c.ShouldBindJSON(&rename); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "error parsing the request"})
+		return
+	}
+
+	updated, err := fc.FileUseCase.RenameTags(c.Request.Context(), callerID(c), uint(userID), rename)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+// DownloadArchive godoc
+// @Summary      Download a user's files as a ZIP archive
+// @Description  Streams a ZIP archive of the user's files with their folder paths, optionally limited to a folder and everything below it or to a list of file IDs. Names that would collide when extracted get a counter, like "report (2).pdf". Files that have not passed the malware scan are left out and counted in X-Archive-Skipped. Only the user can download their files this way
+// @Tags         files
+// @Produce      application/zip
+// @Param        id path int true "User ID"
+// @Param        folderId query string false "Folder to archive"
+// @Param        ids query []string false "File IDs to archive" collectionFormat(csv)
+// @Success      200 {file} file
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Router       /private/api/files/user/{id}/archive [get]
+// @Security     BearerAuth
+func (fc *FileController) DownloadArchive(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	filter := domain.ArchiveFilter{FolderID: c.Query("folderId"), FileIDs: queryList(c, "ids")}
+
+	archive, err := fc.FileUseCase.GetArchive(c.Request.Context(), callerID(c), uint(userID), filter)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", contentDisposition("attachment", archive.Name))
+	c.Header("X-Archive-Skipped", strconv.Itoa(archive.Skipped))
+	c.Status(http.StatusOK)
+
+	// The status is already sent, so a failure can only cut the archive short
+	if err := fc.FileUseCase.WriteArchive(c.Request.Context(), archive, c.Writer); err != nil {
+		_ = c.Error(err)
+	}
+}
+
 // DeleteFilesByUser godoc
-// @Summary      Delete all files for a user
-// @Description  Deletes all files linked to a user ID
+// @Summary      Move all files of a user to the trash
+// @Description  Moves all files linked to a user ID to their trash
 // @Tags         files
 // @Produce      json
 // @Param        id path int true "User ID"
 // @Success      200 {object} map[string]string
 // @Failure      400 {object} map[string]string
-// @Router       /public/api/files/user/{id} [delete]
+// @Failure      403 {object} map[string]string
+// @Router       /private/api/files/user/{id} [delete]
 // @Security     BearerAuth
 func (fc *FileController) DeleteFilesByUser(c *gin.Context) {
 	idParam := c.Param("id")
@@ -142,11 +775,356 @@ func (fc *FileController) DeleteFilesByUser(c *gin.Context) {
 		return
 	}
 
-	err = fc.FileUseCase.DeleteFilesByUserID(c.Request.Context(), uint(userID))
+	err = fc.FileUseCase.DeleteFilesByUserID(c.Request.Context(), callerID(c), uint(userID))
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "all files moved to trash"})
+}
+
+// GetTrash godoc
+// @Summary      List a user's trash
+// @Description  Returns the files the user deleted that were not purged yet, most recently deleted first. Only the user can see their trash
+// @Tags         files
+// @Produce      json
+// @Param        id path int true "User ID"
+// @Success      200 {array} domain.UserFileMeta
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/user/{id}/trash [get]
+// @Security     BearerAuth
+func (fc *FileController) GetTrash(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	files, err := fc.FileUseCase.GetTrash(c.Request.Context(), callerID(c), uint(userID))
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, files)
+}
+
+// EmptyTrash godoc
+// @Summary      Empty a user's trash
+// @Description  Permanently deletes every file in the user's trash and releases its storage usage. Only the user can empty their trash
+// @Tags         files
+// @Produce      json
+// @Param        id path int true "User ID"
+// @Success      200 {object} map[string]int
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/user/{id}/trash [delete]
+// @Security     BearerAuth
+func (fc *FileController) EmptyTrash(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	purged, err := fc.FileUseCase.EmptyTrash(c.Request.Context(), callerID(c), uint(userID))
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+// RestoreFile godoc
+// @Summary      Restore a file from the trash
+// @Description  Moves a file out of the owner's trash into the folder it was deleted from, or into the root when that folder no longer exists. Fails with 409 when a file with the same name was stored there meanwhile, and with 410 when the file has expired
+// @Tags         files
+// @Produce      json
+// @Param        id path string true "File ID"
+// @Success      200 {object} domain.UserFileMeta
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      410 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/{id}/restore [post]
+// @Security     BearerAuth
+func (fc *FileController) RestoreFile(c *gin.Context) {
+	file, err := fc.FileUseCase.RestoreFile(c.Request.Context(), callerID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, file)
+}
+
+// GetFileVersions godoc
+// @Summary      List versions of a file
+// @Description  Returns every stored version of a file ordered by version number
+// @Tags         files
+// @Produce      json
+// @Param        id path string true "File ID"
+// @Success      200 {array} domain.FileVersion
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Router       /private/api/files/{id}/versions [get]
+// @Security     BearerAuth
+func (fc *FileController) GetFileVersions(c *gin.Context) {
+	versions, err := fc.FileUseCase.GetFileVersions(c.Request.Context(), callerID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+// DownloadFileVersion godoc
+// @Summary      Download a specific version of a file
+// @Description  Downloads the content of one version of a file. Supports the same range and conditional requests as downloading the current version
+// @Tags         files
+// @Produce      application/octet-stream
+// @Param        id path string true "File ID"
+// @Param        version path int true "Version number"
+// @Param        disposition query string false "Content-Disposition type" Enums(attachment, inline) default(attachment)
+// @Success      200 {file} file
+// @Success      206 {file} file
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Router       /private/api/files/{id}/versions/{version} [get]
+// @Security     BearerAuth
+func (fc *FileController) DownloadFileVersion(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+		return
+	}
+
+	file, content, err := fc.FileUseCase.DownloadFileVersion(c.Request.Context(), callerID(c), c.Param("id"), version)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	serveFileContent(c, file, content)
+}
+
+// GetThumbnail godoc
+// @Summary      Download a thumbnail of an image
+// @Description  Thumbnails of PNG, JPEG and GIF files are generated in the background after upload; hasThumbnail in the file listing tells when they are available. Small, medium and large fit in 128, 256 and 512 pixel squares
+// @Tags         files
+// @Produce      image/png
+// @Produce      image/jpeg
+// @Param        id path string true "File ID"
+// @Param        size query string false "Thumbnail size" Enums(small, medium, large) default(medium)
+// @Success      200 {file} file
+// @Success      304
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Router       /private/api/files/{id}/thumbnail [get]
+// @Security     BearerAuth
+func (fc *FileController) GetThumbnail(c *gin.Context) {
+	size := domain.ThumbnailSize(c.DefaultQuery("size", string(domain.ThumbnailMedium)))
+
+	thumbnail, content, err := fc.FileUseCase.GetThumbnail(c.Request.Context(), callerID(c), c.Param("id"), size)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	serveThumbnail(c, thumbnail, content)
+}
+
+// RestoreFileVersion godoc
+// @Summary      Restore an older version of a file
+// @Description  Makes a copy of the given version the new current version. The history is kept
+// @Tags         files
+// @Produce      json
+// @Param        id path string true "File ID"
+// @Param        version path int true "Version number"
+// @Success      200 {object} domain.UserFileMeta
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      413 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/{id}/versions/{version}/restore [post]
+// @Security     BearerAuth
+func (fc *FileController) RestoreFileVersion(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+		return
+	}
+
+	meta, err := fc.FileUseCase.RestoreFileVersion(c.Request.Context(), callerID(c), c.Param("id"), version)
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, meta)
+}
+
+// PruneFileVersions godoc
+// @Summary      Prune old versions of a user's files
+// @Description  Deletes old versions of every file of the user that exceed maxVersions (current version included) or are older than maxAgeDays. Zero disables a limit
+// @Tags         files
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "User ID"
+// @Param        request body domain.VersionRetention true "Retention limits"
+// @Success      200 {object} map[string]int
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/user/{id}/versions/prune [post]
+// @Security     BearerAuth
+func (fc *FileController) PruneFileVersions(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var retention domain.VersionRetention
+	if err := c.ShouldBindJSON(&retention); err != nil || retention.MaxVersions < 0 || retention.MaxAgeDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "error parsing the request"})
+		return
+	}
+
+	pruned, err := fc.FileUseCase.PruneFileVersions(c.Request.Context(), callerID(c), uint(userID), retention)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "all files deleted"})
+	c.JSON(http.StatusOK, gin.H{"pruned": pruned})
+}
+
+// GetStorageUsage godoc
+// @Summary      Get storage usage of a user
+// @Description  Returns the bytes and files the user stores against their quota. Zero limits mean unlimited
+// @Tags         files
+// @Produce      json
+// @Param        id path int true "User ID"
+// @Success      200 {object} domain.StorageUsageResponse
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/user/{id}/usage [get]
+// @Security     BearerAuth
+func (fc *FileController) GetStorageUsage(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	usage, err := fc.FileUseCase.GetStorageUsage(c.Request.Context(), callerID(c), uint(userID))
+	if err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// SetStorageQuota godoc
+// @Summary      Override the storage quota of a user
+// @Description  Replaces the default quota for one user. Zero disables a limit. Only admins can override quotas
+// @Tags         files
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "User ID"
+// @Param        request body domain.StorageQuota true "Quota limits"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/user/{id}/quota [put]
+// @Security     BearerAuth
+func (fc *FileController) SetStorageQuota(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var quota domain.StorageQuota
+	if err := c.ShouldBindJSON(&quota); err != nil || quota.MaxBytes < 0 || quota.MaxFiles < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "error parsing the request"})
+		return
+	}
+
+	fc.setStorageQuota(c, uint(userID), &quota)
+}
+
+// ResetStorageQuota godoc
+// @Summary      Remove the storage quota override of a user
+// @Description  The user falls back to the default quota. Only admins can override quotas
+// @Tags         files
+// @Produce      json
+// @Param        id path int true "User ID"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/files/user/{id}/quota [delete]
+// @Security     BearerAuth
+func (fc *FileController) ResetStorageQuota(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	fc.setStorageQuota(c, uint(userID), nil)
+}
+
+func (fc *FileController) setStorageQuota(c *gin.Context, userID uint, quota *domain.StorageQuota) {
+	if err := fc.FileUseCase.SetStorageQuota(c.Request.Context(), callerID(c), userID, quota); err != nil {
+		c.JSON(fileErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "quota updated"})
+}
+
+func fileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrFileNotFound), errors.Is(err, domain.ErrFileVersionNotFound), errors.Is(err, domain.ErrFolderNotFound),
+		errors.Is(err, domain.ErrThumbnailNotFound), err.Error() == "user not found":
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrForbidden), errors.Is(err, domain.ErrFileQuarantined):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrFileExists), errors.Is(err, domain.ErrFileNotScanned):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrInvalidFilename), errors.Is(err, domain.ErrInvalidMetadataKey), errors.Is(err, domain.ErrInvalidThumbnailSize),
+		errors.Is(err, domain.ErrInvalidSearch), errors.Is(err, domain.ErrInvalidCursor), errors.Is(err, domain.ErrInvalidTag),
+		errors.Is(err, domain.ErrTooManyTags), errors.Is(err, domain.ErrInvalidExpiry), errors.Is(err, domain.ErrInvalidDigest),
+		errors.Is(err, domain.ErrDigestMismatch):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrFileExpired):
+		return http.StatusGone
+	case errors.Is(err, domain.ErrQuotaExceeded), errors.Is(err, domain.ErrTooManyFiles):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, domain.ErrContentTypeMismatch), errors.Is(err, domain.ErrContentTypeNotAllowed):
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusInternalServerError
 }
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/gin-gonic/gin"
+)
+
+type FolderController struct {
+	FolderUseCase domain.FolderUseCase
+}
+
+// CreateFolder godoc
+// @Summary      Create a folder
+// @Description  Creates a folder for the user ID inside parentId, or at the root when parentId is empty. Names are unique per parent folder. Other callers need write access to the parent
+// @Tags         folders
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "User ID"
+// @Param        request body domain.CreateFolderRequest true "Folder to create"
+// @Success      201 {object} domain.Folder
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/folders/user/{id} [post]
+// @Security     BearerAuth
+func (fc *FolderController) CreateFolder(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var request domain.CreateFolderRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "error parsing the request"})
+		return
+	}
+
+	folder, err := fc.FolderUseCase.CreateFolder(c.Request.Context(), callerID(c), uint(userID), request)
+	if err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, folder)
+}
+
+// GetRootContents godoc
+// @Summary      List the root folder of a user
+// @Description  Returns the folders and files at the root of the user's folder tree
+// @Tags         folders
+// @Produce      json
+// @Param        id path int true "User ID"
+// @Success      200 {object} domain.FolderContents
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/folders/user/{id} [get]
+// @Security     BearerAuth
+func (fc *FolderController) GetRootContents(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	contents, err := fc.FolderUseCase.GetRootContents(c.Request.Context(), callerID(c), uint(userID))
+	if err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, contents)
+}
+
+// GetFolderContents godoc
+// @Summary      List a folder
+// @Description  Returns the folder with its direct child folders and files
+// @Tags         folders
+// @Produce      json
+// @Param        id path string true "Folder ID"
+// @Success      200 {object} domain.FolderContents
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/folders/{id} [get]
+// @Security     BearerAuth
+func (fc *FolderController) GetFolderContents(c *gin.Context) {
+	contents, err := fc.FolderUseCase.GetFolderContents(c.Request.Context(), callerID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, contents)
+}
+
+// UpdateFolder godoc
+// @Summary      Rename or move a folder
+// @Description  Changes the name or parent of a folder. Omitted fields are unchanged; an empty parentId moves the folder to the root
+// @Tags         folders
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "Folder ID"
+// @Param        request body domain.FolderUpdate true "Fields to change"
+// @Success      200 {object} domain.Folder
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/folders/{id} [patch]
+// @Security     BearerAuth
+func (fc *FolderController) UpdateFolder(c *gin.Context) {
+	var update domain.FolderUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "error parsing the request"})
+		return
+	}
+
+	folder, err := fc.FolderUseCase.UpdateFolder(c.Request.Context(), callerID(c), c.Param("id"), update)
+	if err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+// DeleteFolder godoc
+// @Summary      Delete a folder
+// @Description  Deletes the folder with every folder and file below it
+// @Tags         folders
+// @Produce      json
+// @Param        id path string true "Folder ID"
+// @Success      200 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/folders/{id} [delete]
+// @Security     BearerAuth
+func (fc *FolderController) DeleteFolder(c *gin.Context) {
+	if err := fc.FolderUseCase.DeleteFolder(c.Request.Context(), callerID(c), c.Param("id")); err != nil {
+		c.JSON(folderErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "folder deleted"})
+}
+
+func folderErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrFolderNotFound), err.Error() == "user not found":
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrFolderExists):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrInvalidFolderName), errors.Is(err, domain.ErrFolderCycle):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
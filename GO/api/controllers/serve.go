@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/integrity"
+	"github.com/gin-gonic/gin"
+)
+
+// serveFileContent writes file content with its stored content type, cache
+// validators and a Content-Disposition header. Range requests (single and
+// multipart), If-None-Match, If-Modified-Since and If-Range are handled by
+// http.ServeContent. Compressed content is sent as it is stored to clients
+// accepting its encoding, unless they ask for a range of the original.
+// Repr-Digest carries the SHA-256 of the file, whatever part of it is sent.
+func serveFileContent(c *gin.Context, file *domain.UserFile, content io.ReadSeeker) {
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", contentDisposition(c.DefaultQuery("disposition", "attachment"), file.Filename))
+	c.Header("ETag", fileETag(file))
+	c.Header("X-Content-Type-Options", "nosniff")
+	// Content stored before digests were recorded has none to send
+	sum, _ := hex.DecodeString(file.Digest)
+	if len(sum) > 0 {
+		c.Header("Repr-Digest", integrity.Format(domain.DigestSHA256, sum))
+		// RFC 3230 form for clients that predate RFC 9530
+		c.Header("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum))
+	}
+
+	if file.Encoding != "" {
+		c.Header("Vary", "Accept-Encoding")
+		if encoded, ok := content.(domain.EncodedContent); ok && c.GetHeader("Range") == "" &&
+			acceptsEncoding(c.GetHeader("Accept-Encoding"), file.Encoding) {
+			stored, err := encoded.Encoded()
+			if err == nil {
+				defer stored.Close()
+				// The stored bytes are another representation, so they need their own validator
+				c.Header("ETag", strings.TrimSuffix(fileETag(file), `"`)+"-"+file.Encoding+`"`)
+				c.Header("Content-Encoding", file.Encoding)
+				c.Header("Content-Length", strconv.FormatInt(file.StoredSize, 10))
+				http.ServeContent(c.Writer, c.Request, file.Filename, file.UploadedAt, stored)
+				return
+			}
+			// The decompressed content is already open and serves just as well
+			_ = c.Error(err)
+		}
+	}
+
+	// The whole unencoded file is the representation, so its content has the same digest
+	if len(sum) > 0 && c.GetHeader("Range") == "" {
+		c.Header("Content-Digest", integrity.Format(domain.DigestSHA256, sum))
+	}
+	http.ServeContent(c.Writer, c.Request, file.Filename, file.UploadedAt, content)
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows the
+// content coding encoding. An explicit entry for the coding takes precedence
+// over "*", and a quality of 0 rules it out.
+func acceptsEncoding(header, encoding string) bool {
+	accepted, wildcard := -1.0, -1.0
+	for _, entry := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(entry, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			quality = q
+		}
+
+		switch {
+		case name == encoding, encoding == "gzip" && name == "x-gzip":
+			accepted = quality
+		case name == "*":
+			wildcard = quality
+		}
+	}
+
+	if accepted < 0 {
+		accepted = wildcard
+	}
+	return accepted > 0
+}
+
+// serveThumbnail writes a stored thumbnail. Thumbnail blobs are never
+// modified, so the blob ID serves as a strong validator.
+func serveThumbnail(c *gin.Context, thumbnail *domain.Thumbnail, content io.ReadSeeker) {
+	c.Header("Content-Type", thumbnail.ContentType)
+	c.Header("ETag", `"`+thumbnail.BlobID+`"`)
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Header("X-Content-Type-Options", "nosniff")
+
+	http.ServeContent(c.Writer, c.Request, "", thumbnail.CreatedAt, content)
+}
+
+// fileETag returns a strong validator for the file content. The SHA-256
+// digest identifies the bytes; stored blobs are never modified in place, so
+// their ID does too for content stored before digests were recorded.
+func fileETag(file *domain.UserFile) string {
+	if file.Digest != "" {
+		return `"` + file.Digest + `"`
+	}
+	if file.BlobID != "" {
+		return `"` + file.BlobID + `"`
+	}
+	return `"` + file.ID + `"`
+}
+
+// contentDisposition builds an RFC 6266 header value. Non-ASCII names get an
+// ASCII fallback in filename and the exact name in the RFC 8187 filename*
+// parameter.
+func contentDisposition(dispositionType, filename string) string {
+	if dispositionType != "inline" {
+		dispositionType = "attachment"
+	}
+
+	var fallback strings.Builder
+	for _, r := range filename {
+		if r < 0x20 || r >= 0x7f || r == '"' || r == '\\' {
+			fallback.WriteByte('_')
+			continue
+		}
+		fallback.WriteRune(r)
+	}
+
+	value := dispositionType + `; filename="` + fallback.String() + `"`
+	if fallback.String() != filename {
+		value += "; filename*=UTF-8''" + encodeExtValue(filename)
+	}
+	return value
+}
+
+// encodeExtValue percent-encodes every byte that is not an RFC 8187 attr-char.
+func encodeExtValue(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ('0' <= ch && ch <= '9') ||
+			strings.IndexByte("!#$&+-.^_`|~", ch) >= 0 {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
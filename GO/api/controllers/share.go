@@ -0,0 +1,240 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/gin-gonic/gin"
+)
+
+type ShareController struct {
+	ShareUseCase domain.ShareUseCase
+	// LinkPath is the path share links are served under, used to build their URLs
+	LinkPath string
+}
+
+// CreateShare godoc
+// @Summary      Create a share link for a file
+// @Description  Creates a link anyone with the token can download the file from. The token is only returned here. Expiry, download limit and password are optional. Needs write access to the file
+// @Tags         shares
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "File ID"
+// @Param        request body domain.CreateShareRequest true "Link limits"
+// @Success      201 {object} domain.CreateShareResponse
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/shares/file/{id} [post]
+// @Security     BearerAuth
+func (sc *ShareController) CreateShare(c *gin.Context) {
+	var request domain.CreateShareRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "error parsing the request"})
+		return
+	}
+
+	response, err := sc.ShareUseCase.CreateShare(c.Request.Context(), callerID(c), c.Param("id"), request)
+	if err != nil {
+		c.JSON(shareErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	response.URL = sc.LinkPath + "/" + response.Token
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetFileShares godoc
+// @Summary      List share links of a file
+// @Tags         shares
+// @Produce      json
+// @Param        id path string true "File ID"
+// @Success      200 {array} domain.ShareLink
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/shares/file/{id} [get]
+// @Security     BearerAuth
+func (sc *ShareController) GetFileShares(c *gin.Context) {
+	links, err := sc.ShareUseCase.GetFileShares(c.Request.Context(), callerID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(shareErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+// GetUserShares godoc
+// @Summary      List share links of a user
+// @Description  Returns the share links of every file of the user ID
+// @Tags         shares
+// @Produce      json
+// @Param        id path int true "User ID"
+// @Success      200 {array} domain.ShareLink
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/shares/user/{id} [get]
+// @Security     BearerAuth
+func (sc *ShareController) GetUserShares(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	links, err := sc.ShareUseCase.GetUserShares(c.Request.Context(), callerID(c), uint(userID))
+	if err != nil {
+		c.JSON(shareErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+// RevokeShare godoc
+// @Summary      Revoke a share link
+// @Tags         shares
+// @Produce      json
+// @Param        id path string true "Share link ID"
+// @Success      200 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/shares/{id} [delete]
+// @Security     BearerAuth
+func (sc *ShareController) RevokeShare(c *gin.Context) {
+	if err := sc.ShareUseCase.RevokeShare(c.Request.Context(), callerID(c), c.Param("id")); err != nil {
+		c.JSON(shareErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "share link revoked"})
+}
+
+// DownloadShare godoc
+// @Summary      Download a shared file
+// @Description  Downloads the file behind a share link. Password protected links need the password in X-Share-Password. A GET that sends the whole file, or a range starting at its first byte, counts towards the download limit; HEAD, 304 responses and later ranges of a resumed download do not
+// @Tags         shares
+// @Produce      application/octet-stream
+// @Param        token path string true "Share token"
+// @Param        X-Share-Password header string false "Link password"
+// @Param        disposition query string false "Content-Disposition type" Enums(attachment, inline) default(attachment)
+// @Success      200 {file} file
+// @Success      206 {file} file
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      410 {object} map[string]string
+// @Router       /s/{token} [get]
+func (sc *ShareController) DownloadShare(c *gin.Context) {
+	token := c.Param("token")
+	file, content, err := sc.ShareUseCase.OpenShare(c.Request.Context(), token, c.GetHeader("X-Share-Password"))
+	if err != nil {
+		c.JSON(shareErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	defer content.Close()
+
+	// Whether the content is sent is only known once the response status is chosen
+	if c.Request.Method == http.MethodGet {
+		c.Writer = &shareDownloadWriter{
+			ResponseWriter: c.Writer,
+			fromStart:      rangeFromStart(c.GetHeader("Range")),
+			count: func() error {
+				return sc.ShareUseCase.CountShareDownload(c.Request.Context(), token)
+			},
+		}
+	}
+
+	// Links are handed around; keep shared content out of intermediate caches
+	c.Header("Cache-Control", "private, no-store")
+	serveFileContent(c, file, content)
+}
+
+// shareDownloadWriter counts a share download when the response transfers
+// the file from its start: a full 200 or a 206 for a range beginning at the
+// first byte. Revalidations (304), unsatisfiable ranges (416) and the later
+// ranges of a resumed download are not counted. When counting fails the
+// content is replaced by the error.
+type shareDownloadWriter struct {
+	gin.ResponseWriter
+	fromStart bool
+	count     func() error
+	checked   bool
+	err       error
+}
+
+func (w *shareDownloadWriter) WriteHeader(status int) {
+	if w.checked {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.checked = true
+
+	if status == http.StatusOK || status == http.StatusPartialContent && w.fromStart {
+		if w.err = w.count(); w.err != nil {
+			header := w.Header()
+			for _, name := range []string{"Content-Length", "Content-Range", "Content-Encoding", "Content-Disposition",
+				"ETag", "Repr-Digest", "Digest", "Content-Digest"} {
+				header.Del(name)
+			}
+			header.Set("Content-Type", "application/json; charset=utf-8")
+			w.ResponseWriter.WriteHeader(shareErrorStatus(w.err))
+			body, _ := json.Marshal(gin.H{"error": w.err.Error()})
+			_, _ = w.ResponseWriter.Write(body)
+			return
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *shareDownloadWriter) Write(data []byte) (int, error) {
+	if w.err != nil {
+		// Stops the copy of content that is no longer sent
+		return 0, w.err
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *shareDownloadWriter) WriteString(s string) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// rangeFromStart reports whether a Range header is absent or asks for a range
+// beginning at the first byte, as a fresh download does.
+func rangeFromStart(header string) bool {
+	if header == "" {
+		return true
+	}
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	return ok && strings.HasPrefix(strings.TrimSpace(spec), "0-")
+}
+
+func shareErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrShareNotFound), errors.Is(err, domain.ErrFileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrShareExpired), errors.Is(err, domain.ErrShareLimitReached):
+		return http.StatusGone
+	case errors.Is(err, domain.ErrSharePasswordWrong):
+		return http.StatusUnauthorized
+	case errors.Is(err, domain.ErrForbidden), errors.Is(err, domain.ErrFileQuarantined):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrFileNotScanned):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrInvalidShare):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
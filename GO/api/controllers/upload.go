@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/OgiDac/CompanyTask/api/middleware"
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/integrity"
+	"github.com/gin-gonic/gin"
+)
+
+type UploadController struct {
+	UploadUseCase domain.UploadUseCase
+	// BasePath is the path uploads are served under, used to build Location headers
+	BasePath string
+}
+
+// Options godoc
+// @Summary      Describe resumable upload support
+// @Description  Returns the tus protocol version and extensions supported by the server
+// @Tags         uploads
+// @Success      204
+// @Router       /public/api/uploads [options]
+func (uc *UploadController) Options(c *gin.Context) {
+	c.Header("Tus-Version", middleware.TusVersion)
+	c.Header("Tus-Extension", "creation,expiration,termination")
+	c.Status(http.StatusNoContent)
+}
+
+// CreateUpload godoc
+// @Summary      Start a resumable upload
+// @Description  Creates a tus upload for the user ID. The file is stored once all bytes have been sent with PATCH requests. A Repr-Digest of the whole file is checked then, and a file that doesn't match it is not stored
+// @Tags         uploads
+// @Param        id path int true "User ID"
+// @Param        Tus-Resumable header string true "tus protocol version" default(1.0.0)
+// @Param        Upload-Length header int true "Total size of the file in bytes"
+// @Param        Upload-Metadata header string false "Comma separated key and base64 value pairs: filename, filetype and folderId"
+// @Param        Repr-Digest header string false "RFC 9530 digest of the whole file, e.g. sha-256=:base64:"
+// @Success      201
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      413 {object} map[string]string
+// @Failure      415 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /private/api/uploads/user/{id} [post]
+// @Security     BearerAuth
+func (uc *UploadController) CreateUpload(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Upload-Length"})
+		return
+	}
+
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Upload-Metadata"})
+		return
+	}
+
+	var digests []domain.ContentDigest
+	if value := c.GetHeader("Repr-Digest"); value != "" {
+		if digests, err = integrity.ParseDigestField(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Repr-Digest"})
+			return
+		}
+	}
+
+	upload, err := uc.UploadUseCase.CreateUpload(c.Request.Context(), callerID(c), uint(userID), length, metadata["folderId"], metadata["filename"], metadata["filetype"], digests)
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Location", uc.BasePath+"/"+upload.ID)
+	c.Header("Upload-Expires", upload.ExpiresAt.Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// GetUploadOffset godoc
+// @Summary      Get the offset of a resumable upload
+// @Description  Returns how many bytes of the upload the server has received in the Upload-Offset header. An upload that was received in full but couldn't be stored returns 409; an empty PATCH at its length retries storing it
+// @Tags         uploads
+// @Param        id path string true "Upload ID"
+// @Param        Tus-Resumable header string true "tus protocol version" default(1.0.0)
+// @Success      200
+// @Failure      403 {object} map[string]string
+// @Failure      404
+// @Failure      409
+// @Failure      410
+// @Router       /private/api/uploads/{id} [head]
+// @Security     BearerAuth
+func (uc *UploadController) GetUploadOffset(c *gin.Context) {
+	upload, err := uc.UploadUseCase.GetUpload(c.Request.Context(), callerID(c), c.Param("id"))
+	if err == nil && upload.Stalled() {
+		// Reporting the full offset would tell the client the file was stored
+		err = domain.ErrUploadNotStored
+	}
+	if err != nil {
+		c.Status(uploadErrorStatus(err))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.Format(http.TimeFormat))
+	c.Status(http.StatusOK)
+}
+
+// WriteUploadChunk godoc
+// @Summary      Send a chunk of a resumable upload
+// @Description  Appends the request body at Upload-Offset. The file is stored when the last byte arrives. An upload whose file is rejected is deleted
+// @Tags         uploads
+// @Accept       application/offset+octet-stream
+// @Param        id path string true "Upload ID"
+// @Param        Tus-Resumable header string true "tus protocol version" default(1.0.0)
+// @Param        Upload-Offset header int true "Offset the chunk starts at"
+// @Success      204
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      410 {object} map[string]string
+// @Failure      413 {object} map[string]string
+// @Failure      415 {object} map[string]string
+// @Router       /private/api/uploads/{id} [patch]
+// @Security     BearerAuth
+func (uc *UploadController) WriteUploadChunk(c *gin.Context) {
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "content type must be application/offset+octet-stream"})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid Upload-Offset"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	upload, err := uc.UploadUseCase.GetUpload(ctx, callerID(c), c.Param("id"))
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if c.Request.ContentLength > 0 && offset+c.Request.ContentLength > upload.Length {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": domain.ErrUploadTooLarge.Error()})
+		return
+	}
+
+	upload, err = uc.UploadUseCase.WriteChunk(ctx, callerID(c), upload.ID, offset, c.Request.Body)
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.Format(http.TimeFormat))
+	c.Status(http.StatusNoContent)
+}
+
+// TerminateUpload godoc
+// @Summary      Cancel a resumable upload
+// @Description  Deletes the upload and every chunk received so far
+// @Tags         uploads
+// @Param        id path string true "Upload ID"
+// @Param        Tus-Resumable header string true "tus protocol version" default(1.0.0)
+// @Success      204
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Router       /private/api/uploads/{id} [delete]
+// @Security     BearerAuth
+func (uc *UploadController) TerminateUpload(c *gin.Context) {
+	if err := uc.UploadUseCase.TerminateUpload(c.Request.Context(), callerID(c), c.Param("id")); err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func uploadErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrUploadNotFound), errors.Is(err, domain.ErrFolderNotFound), err.Error() == "user not found":
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrUploadExpired):
+		return http.StatusGone
+	case errors.Is(err, domain.ErrUploadOffsetMismatch), errors.Is(err, domain.ErrUploadNotStored):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrUploadTooLarge), errors.Is(err, domain.ErrQuotaExceeded):
+		return http.StatusRequestEntityTooLarge
+	case err.Error() == "invalid upload length", errors.Is(err, domain.ErrInvalidFilename), errors.Is(err, domain.ErrInvalidDigest),
+		errors.Is(err, domain.ErrDigestMismatch):
+		return http.StatusBadRequest
+	case errors.Is(err, domain.ErrContentTypeMismatch), errors.Is(err, domain.ErrContentTypeNotAllowed):
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusInternalServerError
+}
+
+// parseUploadMetadata decodes the tus Upload-Metadata header, a comma
+// separated list of keys each optionally followed by a base64 encoded value.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 1:
+			metadata[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			metadata[fields[0]] = string(value)
+		default:
+			return nil, errors.New("malformed metadata pair")
+		}
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/OgiDac/CompanyTask/davfs"
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/webdav"
+)
+
+// DavMethods are the methods of WebDAV class 1 and 2 the controller serves.
+var DavMethods = []string{
+	http.MethodOptions, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete,
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK",
+}
+
+// WebDAVController serves the caller's own folders and files over WebDAV,
+// so they can be mounted as a network drive.
+type WebDAVController struct {
+	FileUseCase   domain.FileUseCase
+	FolderUseCase domain.FolderUseCase
+	// Prefix is the path the tree is served under
+	Prefix string
+
+	// Every user sees their own tree under the same paths, so each gets
+	// their own locks. Locks are kept in memory by this instance.
+	locks sync.Map
+}
+
+// ServeWebDAV handles one WebDAV request. It is not documented in Swagger,
+// which can't describe WebDAV methods; any WebDAV client can browse it.
+func (wc *WebDAVController) ServeWebDAV(c *gin.Context) {
+	userID := callerID(c)
+	fs := davfs.New(wc.FileUseCase, wc.FolderUseCase, userID)
+	handler := &webdav.Handler{
+		Prefix:     wc.Prefix,
+		FileSystem: fs,
+		LockSystem: wc.lockSystem(userID),
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				_ = c.Error(err)
+			}
+		},
+	}
+
+	// A body that breaks off cancels the request, so the file isn't stored cut short
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	request := c.Request.WithContext(ctx)
+	if request.Body != nil {
+		request.Body = &davBody{ReadCloser: request.Body, cancel: cancel}
+	}
+
+	handler.ServeHTTP(&davResponseWriter{ResponseWriter: c.Writer, fs: fs}, request)
+}
+
+func (wc *WebDAVController) lockSystem(userID uint) webdav.LockSystem {
+	if locks, ok := wc.locks.Load(userID); ok {
+		return locks.(webdav.LockSystem)
+	}
+	locks, _ := wc.locks.LoadOrStore(userID, webdav.NewMemLS())
+	return locks.(webdav.LockSystem)
+}
+
+// davBody cancels the request when reading its body fails.
+type davBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *davBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		b.cancel()
+	}
+	return n, err
+}
+
+// davResponseWriter replaces the generic statuses the webdav package answers
+// failed file system calls with by the status of the error behind them, so
+// clients see 507 when the quota is full rather than 405.
+type davResponseWriter struct {
+	http.ResponseWriter
+	fs       *davfs.FileSystem
+	replaced bool
+}
+
+func (w *davResponseWriter) WriteHeader(status int) {
+	if status >= http.StatusBadRequest {
+		if err := w.fs.Err(); err != nil {
+			if replacement := davErrorStatus(err); replacement != http.StatusInternalServerError && replacement != status {
+				w.replaced = true
+				w.Header().Del("Content-Length")
+				w.ResponseWriter.WriteHeader(replacement)
+				_, _ = w.ResponseWriter.Write([]byte(http.StatusText(replacement)))
+				return
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *davResponseWriter) Write(p []byte) (int, error) {
+	// The body belonged to the status that was replaced
+	if w.replaced {
+		return len(p), nil
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func davErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrQuotaExceeded), errors.Is(err, domain.ErrTooManyFiles):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, domain.ErrInvalidFolderName), errors.Is(err, domain.ErrFolderCycle):
+		return http.StatusBadRequest
+	}
+	return fileErrorStatus(err)
+}
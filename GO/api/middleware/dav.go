@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// davCredentialTTL is how long verified Basic credentials are accepted
+// without checking the password again. It bounds how long a changed password
+// keeps working for WebDAV clients that are already signed in.
+const davCredentialTTL = 5 * time.Minute
+
+// davCredentialLimit bounds how many verified credentials are remembered.
+const davCredentialLimit = 1024
+
+// DavAuthMiddleware authenticates WebDAV clients, most of which can't log in
+// for a token first. They send the account's email and password with HTTP
+// Basic, checked the same way as a login, or an access token as a bearer
+// token. Failures ask for Basic credentials so clients prompt for them.
+// Clients send Basic credentials with every request, so verified ones are
+// remembered for a few minutes instead of running bcrypt each time.
+func DavAuthMiddleware(secret string, users domain.UserUseCase) gin.HandlerFunc {
+	verified := newCredentialCache(davCredentialTTL, davCredentialLimit)
+
+	return func(c *gin.Context) {
+		if email, password, ok := c.Request.BasicAuth(); ok {
+			if userID, ok := verified.get(email, password); ok {
+				c.Set("user_id", userID)
+				c.Next()
+				return
+			}
+			user, err := users.Authenticate(c.Request.Context(), domain.LoginRequest{Email: email, Password: password})
+			if err == nil {
+				verified.put(email, password, int(user.ID))
+				c.Set("user_id", int(user.ID))
+				c.Next()
+				return
+			}
+		} else if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			userID, err := utils.ExtractIDFromToken(token, secret)
+			if err == nil {
+				c.Set("user_id", userID)
+				c.Next()
+				return
+			}
+		}
+
+		c.Header("WWW-Authenticate", `Basic realm="files", charset="UTF-8"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		c.Abort()
+	}
+}
+
+// credentialCache remembers credentials that passed the password check.
+// Entries are keyed by an HMAC of the email and password under a key that
+// only lives in this process, so passwords are never kept in memory.
+type credentialCache struct {
+	key     []byte
+	ttl     time.Duration
+	limit   int
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]verifiedCredential
+}
+
+type verifiedCredential struct {
+	userID  int
+	expires time.Time
+}
+
+func newCredentialCache(ttl time.Duration, limit int) *credentialCache {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("Failed to create WebDAV credential key: %v", err)
+	}
+	return &credentialCache{key: key, ttl: ttl, limit: limit, entries: map[[sha256.Size]byte]verifiedCredential{}}
+}
+
+func (c *credentialCache) get(email, password string) (int, bool) {
+	id := c.id(email, password)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[id]
+	if !ok || time.Now().After(entry.expires) {
+		return 0, false
+	}
+	return entry.userID, true
+}
+
+func (c *credentialCache) put(email, password string, userID int) {
+	id := c.id(email, password)
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.limit {
+		for key, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, key)
+			}
+		}
+	}
+	// When full of live entries the credentials are simply checked again next time
+	if len(c.entries) < c.limit {
+		c.entries[id] = verifiedCredential{userID: userID, expires: now.Add(c.ttl)}
+	}
+}
+
+func (c *credentialCache) id(email, password string) [sha256.Size]byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(email))
+	mac.Write([]byte{0})
+	mac.Write([]byte(password))
+	var id [sha256.Size]byte
+	copy(id[:], mac.Sum(nil))
+	return id
+}
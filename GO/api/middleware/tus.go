@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const TusVersion = "1.0.0"
+
+// TusResumableMiddleware enforces the Tus-Resumable header required by the
+// tus protocol on every request except OPTIONS.
+func TusResumableMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", TusVersion)
+
+		if c.Request.Method != http.MethodOptions && c.GetHeader("Tus-Resumable") != TusVersion {
+			c.Header("Tus-Version", TusVersion)
+			c.AbortWithStatus(http.StatusPreconditionFailed)
+			return
+		}
+
+		c.Next()
+	}
+}
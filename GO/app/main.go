@@ -11,10 +11,12 @@ import (
 	"github.com/OgiDac/CompanyTask/config"
 	_ "github.com/OgiDac/CompanyTask/docs"
 	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/repository"
 	"github.com/OgiDac/CompanyTask/router"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // @title           CompanyTask API
@@ -34,6 +36,10 @@ func main() {
 	db := app.DB
 	db.AutoMigrate(&domain.User{})
 
+	if app.MongoDB != nil {
+		migrateFiles(app.MongoDB)
+	}
+
 	r := gin.Default()
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -41,12 +47,13 @@ func main() {
 
 	router.Setup(app.Env, timeout, app.DB, app.MongoDB, app.RabbitChannel, r)
 
+	// Uploads, downloads and archives are streamed and may take as long as
+	// the transfer needs, so only the request headers are bounded
 	srv := &http.Server{
-		Addr:         app.Env.ServerAddress,
-		Handler:      r,
-		WriteTimeout: 15 * time.Second,
-		ReadTimeout:  15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:              app.Env.ServerAddress,
+		Handler:           r,
+		ReadHeaderTimeout: 15 * time.Second,
+		IdleTimeout:       60 * time.Second,
 	}
 
 	go func() {
@@ -69,3 +76,32 @@ func main() {
 	fmt.Println("shutting down")
 	os.Exit(0)
 }
+
+// migrateFiles brings file documents written by older versions of the service
+// up to date. Each step is idempotent and they run in order.
+func migrateFiles(db *mongo.Database) {
+	migrations := []struct {
+		name string
+		run  func(context.Context, *mongo.Database) (int, error)
+	}{
+		{"moved inline files to GridFS", repository.MigrateInlineFiles},
+		{"recorded files as versioned", repository.MigrateFileVersions},
+		{"deduplicated stored blobs", repository.MigrateBlobDigests},
+		{"moved blob data keys to blob_keys", repository.MigrateBlobKeys},
+		{"recorded storage usage", repository.MigrateStorageUsage},
+		{"requested image thumbnails", repository.MigrateThumbnails},
+		{"queued files for malware scanning", repository.MigrateScanStatus},
+		{"renamed files with duplicate names", repository.MigrateDuplicateNames},
+	}
+
+	for _, m := range migrations {
+		migrated, err := m.run(context.Background(), db)
+		if err != nil {
+			fmt.Println("Error migrating files ("+m.name+"):", err)
+			return
+		}
+		if migrated > 0 {
+			fmt.Println("Migrated files ("+m.name+"):", migrated)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/OgiDac/CompanyTask/config"
+	"github.com/OgiDac/CompanyTask/domain"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Names of the backends, as recorded with every blob. Blobs recorded without
+// a backend were stored before backends existed and are in GridFS.
+const (
+	GridFS = "gridfs"
+	Local  = "local"
+	S3     = "s3"
+)
+
+var errInvalidID = errors.New("invalid blob id")
+
+// FromEnv returns the backend FILE_STORAGE selects for new blobs, GridFS by
+// default, and every configured backend existing blobs can be read from.
+// GridFS is always readable; the local filesystem is when FILE_STORAGE_DIR is
+// set and S3 when FILE_S3_BUCKET is.
+func FromEnv(env *config.Env, db *mongo.Database) (domain.BlobStore, []domain.BlobStore, error) {
+	stores := []domain.BlobStore{NewGridFS(db)}
+
+	if env.FileStorageDir != "" {
+		store, err := NewLocal(env.FileStorageDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		stores = append(stores, store)
+	}
+
+	if env.FileS3Bucket != "" {
+		store, err := NewS3(S3Config{
+			Endpoint:  env.FileS3Endpoint,
+			Region:    env.FileS3Region,
+			Bucket:    env.FileS3Bucket,
+			AccessKey: env.FileS3AccessKey,
+			SecretKey: env.FileS3SecretKey,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		stores = append(stores, store)
+	}
+
+	name := env.FileStorage
+	if name == "" {
+		name = GridFS
+	}
+	for _, store := range stores {
+		if store.Name() == name {
+			return store, stores, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("FILE_STORAGE %q is not a configured backend", name)
+}
+
+// validName reports whether name is safe to use as a path segment or object
+// key part.
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,287 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/config"
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func put(t *testing.T, store domain.BlobStore, bucket, id string, content []byte) {
+	w, err := store.Create(context.Background(), bucket, id)
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+}
+
+// testBlobStore checks the behaviour every backend must share.
+func testBlobStore(t *testing.T, store domain.BlobStore) {
+	ctx := context.Background()
+	content := make([]byte, 3<<20+17)
+	_, err := rand.Read(content)
+	require.NoError(t, err)
+
+	id := primitive.NewObjectID().Hex()
+	put(t, store, "user_files", id, content)
+
+	r, err := store.Open(ctx, "user_files", id)
+	require.NoError(t, err)
+	stored, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, content, stored)
+
+	// Skipping lands on the same bytes reading would
+	for _, offset := range []int64{10, 2 << 20} {
+		r, err := store.Open(ctx, "user_files", id)
+		require.NoError(t, err)
+		skipper, ok := r.(interface{ Skip(int64) (int64, error) })
+		require.True(t, ok)
+		skipped, err := skipper.Skip(offset)
+		require.NoError(t, err)
+		require.Equal(t, offset, skipped)
+		rest, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, content[offset:], rest)
+		require.NoError(t, r.Close())
+	}
+
+	// Buckets are separate
+	_, err = store.Open(ctx, "file_thumbnails", id)
+	require.ErrorIs(t, err, domain.ErrBlobNotFound)
+
+	// Empty blobs are blobs too
+	empty := primitive.NewObjectID().Hex()
+	put(t, store, "file_uploads", empty, nil)
+	r, err = store.Open(ctx, "file_uploads", empty)
+	require.NoError(t, err)
+	stored, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.Empty(t, stored)
+	require.NoError(t, r.Close())
+
+	// Aborted blobs are never stored
+	aborted := primitive.NewObjectID().Hex()
+	w, err := store.Create(ctx, "user_files", aborted)
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Abort())
+	_, err = store.Open(ctx, "user_files", aborted)
+	require.ErrorIs(t, err, domain.ErrBlobNotFound)
+
+	require.NoError(t, store.Delete(ctx, "user_files", id))
+	_, err = store.Open(ctx, "user_files", id)
+	require.ErrorIs(t, err, domain.ErrBlobNotFound)
+	require.NoError(t, store.Delete(ctx, "user_files", id))
+
+	_, err = store.Open(ctx, "user_files", "../../etc/passwd")
+	require.ErrorIs(t, err, errInvalidID)
+}
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocal(t.TempDir())
+	require.NoError(t, err)
+
+	testBlobStore(t, store)
+}
+
+// fakeS3 keeps objects in memory and understands the requests s3Store sends,
+// like a local MinIO would.
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	nextID  int
+}
+
+func startFakeS3(t *testing.T) (*fakeS3, *s3Store) {
+	fake := &fakeS3{t: t, objects: map[string][]byte{}, uploads: map[string]map[int][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3(S3Config{Endpoint: server.URL, Bucket: "files", AccessKey: "access", SecretKey: "secret"})
+	require.NoError(t, err)
+	s3 := store.(*s3Store)
+	// Small parts exercise multipart uploads without megabytes of test data
+	s3.partSize = 1 << 20
+	return fake, s3
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access/") || r.Header.Get("X-Amz-Date") == "" {
+		f.fail(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/files/")
+	if !ok {
+		f.fail(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	query := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		id := "upload-" + strconv.Itoa(f.nextID)
+		f.uploads[id] = map[int][]byte{}
+		_, _ = w.Write([]byte("<InitiateMultipartUploadResult><UploadId>" + id + "</UploadId></InitiateMultipartUploadResult>"))
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[number] = body
+		w.Header().Set("ETag", `"etag-`+strconv.Itoa(number)+`"`)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		var complete struct {
+			Parts []completedPart `xml:"Part"`
+		}
+		require.NoError(f.t, xml.Unmarshal(body, &complete))
+		var object []byte
+		for i, part := range complete.Parts {
+			require.Equal(f.t, i+1, part.PartNumber)
+			require.Equal(f.t, `"etag-`+strconv.Itoa(i+1)+`"`, part.ETag)
+			object = append(object, parts[part.PartNumber]...)
+		}
+		f.objects[key] = object
+		delete(f.uploads, query.Get("uploadId"))
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+	case r.Method == http.MethodGet:
+		object, ok := f.objects[key]
+		if !ok {
+			f.fail(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if rng := r.Header.Get("Range"); rng != "" {
+			start, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			if start >= len(object) {
+				f.fail(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+				return
+			}
+			object = object[start:]
+			w.WriteHeader(http.StatusPartialContent)
+		}
+		_, _ = w.Write(object)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		f.fail(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (f *fakeS3) fail(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte("<Error><Code>" + code + "</Code><Message>" + code + "</Message></Error>"))
+}
+
+func TestS3Store(t *testing.T) {
+	fake, store := startFakeS3(t)
+
+	testBlobStore(t, store)
+
+	// Aborted multipart uploads are cleaned up
+	require.Empty(t, fake.uploads)
+}
+
+func TestS3Store_SmallBlobInOneRequest(t *testing.T) {
+	fake, store := startFakeS3(t)
+
+	id := primitive.NewObjectID().Hex()
+	put(t, store, "file_thumbnails", id, []byte("thumbnail"))
+
+	require.Equal(t, []byte("thumbnail"), fake.objects["file_thumbnails/"+id])
+	require.Zero(t, fake.nextID)
+}
+
+func TestS3Store_Error(t *testing.T) {
+	_, store := startFakeS3(t)
+	store.config.AccessKey = "someone-else"
+
+	_, err := store.Open(context.Background(), "user_files", primitive.NewObjectID().Hex())
+	require.ErrorContains(t, err, "AccessDenied")
+	require.NotErrorIs(t, err, domain.ErrBlobNotFound)
+}
+
+func TestS3Store_Sign(t *testing.T) {
+	// Signing is deterministic for a given time, key and request
+	_, store := startFakeS3(t)
+	sign := func() string {
+		req := httptest.NewRequest(http.MethodGet, "http://minio:9000/files/user_files/abc?partNumber=1&uploadId=a%2Fb", nil)
+		req.URL.RawQuery = canonicalQuery(req.URL.Query())
+		store.sign(req, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+		return req.Header.Get("Authorization")
+	}
+
+	auth := sign()
+	require.Equal(t, auth, sign())
+	require.Contains(t, auth, "Credential=access/20260102/us-east-1/s3/aws4_request")
+	require.Contains(t, auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date")
+	require.Equal(t, "partNumber=1&uploadId=a%2Fb", canonicalQuery(map[string][]string{"uploadId": {"a/b"}, "partNumber": {"1"}}))
+}
+
+func TestFromEnv(t *testing.T) {
+	current, stores, err := FromEnv(&config.Env{FileStorage: Local, FileStorageDir: t.TempDir()}, nil)
+	require.NoError(t, err)
+	require.Equal(t, Local, current.Name())
+	require.Len(t, stores, 2)
+
+	current, _, err = FromEnv(&config.Env{}, nil)
+	require.NoError(t, err)
+	require.Equal(t, GridFS, current.Name())
+
+	// Selecting a backend that isn't configured fails at startup
+	_, _, err = FromEnv(&config.Env{FileStorage: S3}, nil)
+	require.Error(t, err)
+}
+
+func TestLocalStore_PartialBlobIsInvisible(t *testing.T) {
+	store, err := NewLocal(t.TempDir())
+	require.NoError(t, err)
+
+	id := primitive.NewObjectID().Hex()
+	w, err := store.Create(context.Background(), "user_files", id)
+	require.NoError(t, err)
+	_, err = w.Write(bytes.Repeat([]byte("x"), 100))
+	require.NoError(t, err)
+
+	_, err = store.Open(context.Background(), "user_files", id)
+	require.ErrorIs(t, err, domain.ErrBlobNotFound)
+	require.NoError(t, w.Close())
+
+	r, err := store.Open(context.Background(), "user_files", id)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+}
@@ -0,0 +1,98 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// gridFSStore keeps blobs in MongoDB GridFS, one GridFS bucket per bucket.
+// Blob IDs are ObjectIDs in hex.
+type gridFSStore struct {
+	db      *mongo.Database
+	mu      sync.Mutex
+	buckets map[string]*gridfs.Bucket
+}
+
+func NewGridFS(db *mongo.Database) domain.BlobStore {
+	return &gridFSStore{db: db, buckets: map[string]*gridfs.Bucket{}}
+}
+
+func (s *gridFSStore) Name() string {
+	return GridFS
+}
+
+func (s *gridFSStore) Create(ctx context.Context, bucket, id string) (domain.BlobWriter, error) {
+	blobID, b, err := s.bucket(bucket, id)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := b.OpenUploadStreamWithID(blobID, id)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetWriteDeadline(deadline)
+	}
+	return stream, nil
+}
+
+func (s *gridFSStore) Open(ctx context.Context, bucket, id string) (io.ReadCloser, error) {
+	blobID, b, err := s.bucket(bucket, id)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := b.OpenDownloadStream(blobID)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, domain.ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetReadDeadline(deadline)
+	}
+	return stream, nil
+}
+
+func (s *gridFSStore) Delete(ctx context.Context, bucket, id string) error {
+	blobID, b, err := s.bucket(bucket, id)
+	if err != nil {
+		return err
+	}
+
+	if err := b.DeleteContext(ctx, blobID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+		return err
+	}
+	return nil
+}
+
+// bucket returns the GridFS bucket with the given name and the blob ID as an ObjectID.
+func (s *gridFSStore) bucket(name, id string) (primitive.ObjectID, *gridfs.Bucket, error) {
+	blobID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return primitive.NilObjectID, nil, errInvalidID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b, ok := s.buckets[name]; ok {
+		return blobID, b, nil
+	}
+	b, err := gridfs.NewBucket(s.db, options.GridFSBucket().SetName(name))
+	if err != nil {
+		return primitive.NilObjectID, nil, err
+	}
+	s.buckets[name] = b
+	return blobID, b, nil
+}
@@ -0,0 +1,140 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/OgiDac/CompanyTask/domain"
+)
+
+// localStore keeps blobs as files below a directory, in one directory per
+// bucket. Files are spread over subdirectories by the end of their ID, so
+// no single directory grows too large.
+type localStore struct {
+	dir string
+}
+
+// NewLocal stores blobs below dir, creating it when it doesn't exist. It
+// suits development and single node deployments; every instance of the
+// service must see the same directory.
+func NewLocal(dir string) (domain.BlobStore, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &localStore{dir: dir}, nil
+}
+
+func (s *localStore) Name() string {
+	return Local
+}
+
+// Create writes the blob to a temporary file next to its final path and
+// renames it into place on Close, so readers never see a partial blob.
+func (s *localStore) Create(ctx context.Context, bucket, id string) (domain.BlobWriter, error) {
+	path, err := s.path(bucket, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+id+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &localWriter{File: file, path: path}, nil
+}
+
+func (s *localStore) Open(ctx context.Context, bucket, id string) (io.ReadCloser, error) {
+	path, err := s.path(bucket, id)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, domain.ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &localBlob{File: file}, nil
+}
+
+func (s *localStore) Delete(ctx context.Context, bucket, id string) error {
+	path, err := s.path(bucket, id)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *localStore) path(bucket, id string) (string, error) {
+	if !validName(bucket) || !validName(id) || len(id) < 2 {
+		return "", errInvalidID
+	}
+	return filepath.Join(s.dir, bucket, id[len(id)-2:], id), nil
+}
+
+type localWriter struct {
+	*os.File
+	path string
+}
+
+func (w *localWriter) Close() error {
+	if err := w.File.Sync(); err != nil {
+		_ = w.Abort()
+		return err
+	}
+	if err := w.File.Close(); err != nil {
+		_ = os.Remove(w.File.Name())
+		return err
+	}
+	if err := os.Rename(w.File.Name(), w.path); err != nil {
+		_ = os.Remove(w.File.Name())
+		return err
+	}
+	return nil
+}
+
+func (w *localWriter) Abort() error {
+	_ = w.File.Close()
+	return os.Remove(w.File.Name())
+}
+
+// localBlob skips content by seeking instead of reading it.
+type localBlob struct {
+	*os.File
+}
+
+// Skip skips up to n bytes and reports how many were skipped, which is less
+// than n only at the end of the blob.
+func (b *localBlob) Skip(n int64) (int64, error) {
+	pos, err := b.File.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	info, err := b.File.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	skip := max(min(n, info.Size()-pos), 0)
+	if _, err := b.File.Seek(skip, io.SeekCurrent); err != nil {
+		return 0, err
+	}
+	return skip, nil
+}
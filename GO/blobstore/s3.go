@@ -0,0 +1,447 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+)
+
+// defaultPartSize is how much of a blob is buffered before it is sent as one
+// part of a multipart upload. Smaller blobs are sent in a single request.
+const defaultPartSize = 8 << 20
+
+// skipThreshold is from how many bytes on a skip requests the rest of the
+// object from the new offset instead of reading and discarding.
+const skipThreshold = 1 << 20
+
+// S3Config configures an S3 compatible object store, like AWS S3 or MinIO.
+type S3Config struct {
+	// Endpoint is the base URL, like https://s3.eu-central-1.amazonaws.com
+	// or http://minio:9000. Objects are addressed path style.
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// s3Store keeps blobs as objects named bucket/id in one S3 bucket. Requests
+// are signed with AWS Signature Version 4.
+type s3Store struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+	partSize int
+}
+
+func NewS3(config S3Config) (domain.BlobStore, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	return &s3Store{config: config, endpoint: endpoint, client: &http.Client{}, partSize: defaultPartSize}, nil
+}
+
+func (s *s3Store) Name() string {
+	return S3
+}
+
+func (s *s3Store) Create(ctx context.Context, bucket, id string) (domain.BlobWriter, error) {
+	key, err := s.key(bucket, id)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Writer{ctx: ctx, store: s, key: key}, nil
+}
+
+func (s *s3Store) Open(ctx context.Context, bucket, id string) (io.ReadCloser, error) {
+	key, err := s.key(bucket, id)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := s.get(ctx, key, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Object{ctx: ctx, store: s, key: key, body: body}, nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, bucket, id string) error {
+	key, err := s.key(bucket, id)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3Store) key(bucket, id string) (string, error) {
+	if !validName(bucket) || !validName(id) {
+		return "", errInvalidID
+	}
+	return bucket + "/" + id, nil
+}
+
+// get requests an object from offset on. It returns io.EOF when offset is
+// at or past its end.
+func (s *s3Store) get(ctx context.Context, key string, offset int64) (io.ReadCloser, error) {
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, key, nil, header, nil)
+	if err != nil {
+		var s3Err *s3Error
+		if errors.As(err, &s3Err) {
+			switch s3Err.status {
+			case http.StatusNotFound:
+				return nil, domain.ErrBlobNotFound
+			case http.StatusRequestedRangeNotSatisfiable:
+				return nil, io.EOF
+			}
+		}
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// do sends a signed request for key and returns the response when it
+// succeeded. Failed requests return an *s3Error.
+func (s *s3Store) do(ctx context.Context, method, key string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	target := *s.endpoint
+	target.Path = strings.TrimSuffix(target.Path, "/") + "/" + s.config.Bucket + "/" + key
+	target.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.ContentLength = int64(len(body))
+	if body == nil {
+		req.Body = http.NoBody
+	}
+	s.sign(req, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, readS3Error(method, key, resp)
+	}
+	return resp, nil
+}
+
+// sign adds an AWS Signature Version 4 to req. The payload is not signed,
+// so content can be sent without hashing it first.
+func (s *s3Store) sign(req *http.Request, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:UNSIGNED-PAYLOAD\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), date)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.config.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by key with every character but the
+// unreserved ones escaped, as signatures require.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, uriEncode(key)+"="+uriEncode(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' || strings.IndexByte("-._~", ch) >= 0 {
+			b.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", ch)
+	}
+	return b.String()
+}
+
+// s3Error is an error response of the object store.
+type s3Error struct {
+	status  int
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+	op      string
+}
+
+func (e *s3Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3 %s: status %d", e.op, e.status)
+	}
+	return fmt.Sprintf("s3 %s: %s: %s", e.op, e.Code, e.Message)
+}
+
+func readS3Error(method, key string, resp *http.Response) error {
+	s3Err := &s3Error{status: resp.StatusCode, op: method + " " + key}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	_ = xml.Unmarshal(body, s3Err)
+	return s3Err
+}
+
+// s3Object reads an object. Large skips request the rest of the object
+// from the new offset instead of downloading what is skipped.
+type s3Object struct {
+	ctx    context.Context
+	store  *s3Store
+	key    string
+	body   io.ReadCloser
+	offset int64
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+// Skip skips up to n bytes and reports how many were skipped, which is less
+// than n only at the end of the object.
+func (o *s3Object) Skip(n int64) (int64, error) {
+	if n < skipThreshold {
+		skipped, err := io.CopyN(io.Discard, o.body, n)
+		o.offset += skipped
+		if err == io.EOF {
+			err = nil
+		}
+		return skipped, err
+	}
+
+	body, err := o.store.get(o.ctx, o.key, o.offset+n)
+	if err == io.EOF {
+		// The object ends before the new offset; reads return EOF from here
+		_ = o.body.Close()
+		o.body = http.NoBody
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	_ = o.body.Close()
+	o.body = body
+	o.offset += n
+	return n, nil
+}
+
+func (o *s3Object) Close() error {
+	return o.body.Close()
+}
+
+// s3Writer buffers a blob and sends it in one request when it is small, or
+// as a multipart upload part by part when it is not.
+type s3Writer struct {
+	ctx      context.Context
+	store    *s3Store
+	key      string
+	buf      []byte
+	uploadID string
+	parts    []completedPart
+	done     bool
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	if w.done {
+		return 0, errors.New("write to closed blob")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// A full buffer is only sent once more data follows, so the last part is never empty
+		if len(w.buf) == w.store.partSize {
+			if err := w.uploadPart(); err != nil {
+				return written, err
+			}
+		}
+		if w.buf == nil {
+			w.buf = make([]byte, 0, w.store.partSize)
+		}
+		n := copy(w.buf[len(w.buf):w.store.partSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *s3Writer) Close() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+
+	if w.uploadID == "" {
+		resp, err := w.store.do(w.ctx, http.MethodPut, w.key, nil, nil, w.buf)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	if err := w.uploadPart(); err != nil {
+		_ = w.abortUpload()
+		return err
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: w.parts})
+	if err != nil {
+		_ = w.abortUpload()
+		return err
+	}
+	resp, err := w.store.do(w.ctx, http.MethodPost, w.key, url.Values{"uploadId": {w.uploadID}}, nil, body)
+	if err != nil {
+		_ = w.abortUpload()
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Completing can fail after the status was sent, which is reported in the body
+	result, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(result, []byte("<Error>")) {
+		s3Err := &s3Error{status: resp.StatusCode, op: "complete " + w.key}
+		_ = xml.Unmarshal(result, s3Err)
+		return s3Err
+	}
+	return nil
+}
+
+func (w *s3Writer) Abort() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	w.buf = nil
+	return w.abortUpload()
+}
+
+// uploadPart sends the buffer as the next part, starting the multipart
+// upload with the first one.
+func (w *s3Writer) uploadPart() error {
+	if w.uploadID == "" {
+		resp, err := w.store.do(w.ctx, http.MethodPost, w.key, url.Values{"uploads": {""}}, nil, nil)
+		if err != nil {
+			return err
+		}
+		var result struct {
+			UploadID string `xml:"UploadId"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		w.uploadID = result.UploadID
+	}
+
+	number := len(w.parts) + 1
+	resp, err := w.store.do(w.ctx, http.MethodPut, w.key, url.Values{
+		"partNumber": {strconv.Itoa(number)},
+		"uploadId":   {w.uploadID},
+	}, nil, w.buf)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	w.parts = append(w.parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+	w.buf = w.buf[:0]
+	return nil
+}
+
+func (w *s3Writer) abortUpload() error {
+	if w.uploadID == "" {
+		return nil
+	}
+	resp, err := w.store.do(context.WithoutCancel(w.ctx), http.MethodDelete, w.key, url.Values{"uploadId": {w.uploadID}}, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
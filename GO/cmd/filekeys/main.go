@@ -0,0 +1,80 @@
+// Command filekeys manages the encryption of stored files.
+//
+//	filekeys encrypt  encrypts content stored before encryption was enabled
+//	filekeys rotate   rewraps data keys wrapped by previous master keys
+//
+// It reads the same environment as the service.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/OgiDac/CompanyTask/blobstore"
+	"github.com/OgiDac/CompanyTask/config"
+	"github.com/OgiDac/CompanyTask/encryption"
+	"github.com/OgiDac/CompanyTask/repository"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func main() {
+	commands := map[string]struct {
+		done string
+		run  func(context.Context, *mongo.Database, *repository.BlobStorage) (int, error)
+	}{
+		"encrypt": {"Encrypted blobs:", repository.EncryptStoredBlobs},
+		"rotate":  {"Rotated data keys:", repository.RotateBlobKeys},
+	}
+
+	if len(os.Args) != 2 {
+		log.Fatalf("usage: %s encrypt|rotate", os.Args[0])
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		log.Fatalf("unknown command %q, expected encrypt or rotate", os.Args[1])
+	}
+
+	env := config.NewEnv()
+	keys, err := encryption.LoadKeyring(env.FileMasterKey, env.FileMasterKeyFile, env.FilePreviousMasterKeys)
+	if err != nil {
+		log.Fatalf("Failed to load the file master key: %v", err)
+	}
+	if keys == nil {
+		log.Fatal("FILE_MASTER_KEY or FILE_MASTER_KEY_FILE must be set")
+	}
+
+	db := config.NewMongoConnection(env)
+	if db == nil {
+		os.Exit(1)
+	}
+
+	// log.Fatal would skip closing the connection, so failures exit after it
+	status := 0
+	if err := execute(env, db, keys, command.done, command.run); err != nil {
+		log.Print(err)
+		status = 1
+	}
+	config.CloseMongoConnection(db)
+	os.Exit(status)
+}
+
+// execute runs a command against the blob storage the service uses and
+// prints how many blobs it changed.
+func execute(env *config.Env, db *mongo.Database, keys *encryption.Keyring, done string,
+	run func(context.Context, *mongo.Database, *repository.BlobStorage) (int, error)) error {
+	// Encrypted copies are written to the backend the service writes to
+	current, stores, err := blobstore.FromEnv(env, db)
+	if err != nil {
+		return fmt.Errorf("Failed to set up file storage: %w", err)
+	}
+	storage := repository.NewBlobStorage(db, current, stores, keys)
+
+	n, err := run(context.Background(), db, storage)
+	fmt.Println(done, n)
+	if err != nil {
+		return fmt.Errorf("Failed: %w", err)
+	}
+	return nil
+}
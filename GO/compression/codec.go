@@ -0,0 +1,52 @@
+package compression
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codecs are named like their HTTP content codings, so stored content can be
+// sent as it is with Content-Encoding.
+const (
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+var ErrUnknownCodec = errors.New("unknown compression codec")
+
+// Supported reports whether codec names a known codec.
+func Supported(codec string) bool {
+	return codec == Gzip || codec == Zstd
+}
+
+// NewWriter compresses everything written to it with codec into w. Close
+// must be called to flush the compressed stream; it does not close w.
+func NewWriter(w io.Writer, codec string) (io.WriteCloser, error) {
+	switch codec {
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		// Uploads are compressed while they stream in, so one goroutine per upload is enough
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	}
+	return nil, ErrUnknownCodec
+}
+
+// NewReader decompresses content compressed with codec. Close releases the
+// decoder; it does not close r.
+func NewReader(r io.Reader, codec string) (io.ReadCloser, error) {
+	switch codec {
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zstd:
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return nil, ErrUnknownCodec
+}
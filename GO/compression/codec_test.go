@@ -0,0 +1,59 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	plaintext := []byte(strings.Repeat("id,name,amount\n1,widget,9.99\n", 5000))
+
+	for _, codec := range []string{Gzip, Zstd} {
+		var compressed bytes.Buffer
+		w, err := NewWriter(&compressed, codec)
+		require.NoError(t, err)
+		_, err = w.Write(plaintext)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.Less(t, compressed.Len(), len(plaintext)/10, codec)
+
+		r, err := NewReader(&compressed, codec)
+		require.NoError(t, err)
+		decompressed, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, plaintext, decompressed, codec)
+	}
+}
+
+func TestCodecs_Corrupted(t *testing.T) {
+	for _, codec := range []string{Gzip, Zstd} {
+		var compressed bytes.Buffer
+		w, err := NewWriter(&compressed, codec)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(strings.Repeat("log line\n", 1000)))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		// Drop the end of the stream, where both codecs keep their checksum
+		truncated := compressed.Bytes()[:compressed.Len()-4]
+		r, err := NewReader(bytes.NewReader(truncated), codec)
+		if err == nil {
+			_, err = io.ReadAll(r)
+		}
+		require.Error(t, err, codec)
+	}
+}
+
+func TestCodecs_Unknown(t *testing.T) {
+	require.False(t, Supported("br"))
+
+	_, err := NewWriter(io.Discard, "br")
+	require.ErrorIs(t, err, ErrUnknownCodec)
+	_, err = NewReader(strings.NewReader(""), "br")
+	require.ErrorIs(t, err, ErrUnknownCodec)
+}
@@ -18,6 +18,31 @@ type Env struct {
 	RefreshTokenSecret     string `mapstructure:"REFRESH_TOKEN_SECRET"`
 	MongoURL               string `mapstructure:"MONGO_URL"`
 	MongoDBName            string `mapstructure:"MONGO_DB_NAME"`
+	UploadExpiryHour       int    `mapstructure:"UPLOAD_EXPIRY_HOUR"`
+	FileMaxVersions        int    `mapstructure:"FILE_MAX_VERSIONS"`
+	FileVersionMaxAgeDays  int    `mapstructure:"FILE_VERSION_MAX_AGE_DAYS"`
+	FileQuotaBytes         int64  `mapstructure:"FILE_QUOTA_BYTES"`
+	FileQuotaFiles         int    `mapstructure:"FILE_QUOTA_FILES"`
+	FileQuotaAdmins        string `mapstructure:"FILE_QUOTA_ADMINS"`
+	FileAllowedTypes       string `mapstructure:"FILE_ALLOWED_TYPES"`
+	FileDeniedTypes        string `mapstructure:"FILE_DENIED_TYPES"`
+	FileExtensionTypes     string `mapstructure:"FILE_EXTENSION_TYPES"`
+	ClamdAddress           string `mapstructure:"CLAMD_ADDRESS"`
+	ClamdTimeout           int    `mapstructure:"CLAMD_TIMEOUT"`
+	FileUploadMaxParts     int    `mapstructure:"FILE_UPLOAD_MAX_PARTS"`
+	FileMasterKey          string `mapstructure:"FILE_MASTER_KEY"`
+	FileMasterKeyFile      string `mapstructure:"FILE_MASTER_KEY_FILE"`
+	FilePreviousMasterKeys string `mapstructure:"FILE_PREVIOUS_MASTER_KEYS"`
+	FileCompressionTypes   string `mapstructure:"FILE_COMPRESSION_TYPES"`
+	FileStorage            string `mapstructure:"FILE_STORAGE"`
+	FileStorageDir         string `mapstructure:"FILE_STORAGE_DIR"`
+	FileS3Endpoint         string `mapstructure:"FILE_S3_ENDPOINT"`
+	FileS3Region           string `mapstructure:"FILE_S3_REGION"`
+	FileS3Bucket           string `mapstructure:"FILE_S3_BUCKET"`
+	FileS3AccessKey        string `mapstructure:"FILE_S3_ACCESS_KEY"`
+	FileS3SecretKey        string `mapstructure:"FILE_S3_SECRET_KEY"`
+	FileTrashRetentionDays int    `mapstructure:"FILE_TRASH_RETENTION_DAYS"`
+	FileScrubPeriodDays    int    `mapstructure:"FILE_SCRUB_PERIOD_DAYS"`
 }
 
 func NewEnv() *Env {
@@ -36,6 +61,31 @@ func NewEnv() *Env {
 	viper.BindEnv("REFRESH_TOKEN_SECRET")
 	viper.BindEnv("MONGO_URL")
 	viper.BindEnv("MONGO_DB_NAME")
+	viper.BindEnv("UPLOAD_EXPIRY_HOUR")
+	viper.BindEnv("FILE_MAX_VERSIONS")
+	viper.BindEnv("FILE_VERSION_MAX_AGE_DAYS")
+	viper.BindEnv("FILE_QUOTA_BYTES")
+	viper.BindEnv("FILE_QUOTA_FILES")
+	viper.BindEnv("FILE_QUOTA_ADMINS")
+	viper.BindEnv("FILE_ALLOWED_TYPES")
+	viper.BindEnv("FILE_DENIED_TYPES")
+	viper.BindEnv("FILE_EXTENSION_TYPES")
+	viper.BindEnv("CLAMD_ADDRESS")
+	viper.BindEnv("CLAMD_TIMEOUT")
+	viper.BindEnv("FILE_UPLOAD_MAX_PARTS")
+	viper.BindEnv("FILE_MASTER_KEY")
+	viper.BindEnv("FILE_MASTER_KEY_FILE")
+	viper.BindEnv("FILE_PREVIOUS_MASTER_KEYS")
+	viper.BindEnv("FILE_COMPRESSION_TYPES")
+	viper.BindEnv("FILE_STORAGE")
+	viper.BindEnv("FILE_STORAGE_DIR")
+	viper.BindEnv("FILE_S3_ENDPOINT")
+	viper.BindEnv("FILE_S3_REGION")
+	viper.BindEnv("FILE_S3_BUCKET")
+	viper.BindEnv("FILE_S3_ACCESS_KEY")
+	viper.BindEnv("FILE_S3_SECRET_KEY")
+	viper.BindEnv("FILE_TRASH_RETENTION_DAYS")
+	viper.BindEnv("FILE_SCRUB_PERIOD_DAYS")
 
 	if err := viper.ReadInConfig(); err != nil {
 		fmt.Println("No .env file found, relying on environment variables")
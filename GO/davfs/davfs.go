@@ -0,0 +1,529 @@
+// Package davfs presents a user's folders and files as a webdav.FileSystem,
+// so the webdav package can serve them to clients that mount them as a
+// network drive.
+package davfs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"golang.org/x/net/webdav"
+)
+
+var (
+	errIsDirectory  = errors.New("is a directory")
+	errNotDirectory = errors.New("not a directory")
+	errWriteOnly    = errors.New("file is open for writing")
+	errReadOnly     = errors.New("file is open for reading")
+)
+
+// FileSystem is the tree of one user's own files, acting as that user, so
+// shared files of others never show up in it. Every operation goes through
+// the use cases, with their access checks, quota and scanning.
+//
+// A FileSystem is meant to serve a single request. It remembers what it
+// looked up, so the entries of a listing aren't looked up again one by one.
+// Where a folder and a file in the same folder have the same name, the
+// folder is shown.
+type FileSystem struct {
+	files   domain.FileUseCase
+	folders domain.FolderUseCase
+	userID  uint
+
+	mu      sync.Mutex
+	entries map[string]*fileInfo
+	failure error
+}
+
+var _ webdav.FileSystem = (*FileSystem)(nil)
+
+func New(files domain.FileUseCase, folders domain.FolderUseCase, userID uint) *FileSystem {
+	return &FileSystem{
+		files:   files,
+		folders: folders,
+		userID:  userID,
+		entries: map[string]*fileInfo{},
+	}
+}
+
+// Err returns the last error a use case failed with, other than a missing or
+// already existing entry. The webdav package answers most failures with a
+// generic status; this one tells what actually went wrong.
+func (fs *FileSystem) Err() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.failure
+}
+
+func (fs *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	info, err := fs.lookup(ctx, clean(name))
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (fs *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	p := clean(name)
+	if _, err := fs.lookup(ctx, p); err == nil {
+		return os.ErrExist
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	parentID, err := fs.folderID(ctx, path.Dir(p))
+	if err != nil {
+		return err
+	}
+
+	folder, err := fs.folders.CreateFolder(ctx, fs.userID, fs.userID, domain.CreateFolderRequest{Name: path.Base(p), ParentID: parentID})
+	if err != nil {
+		return fs.fail(err)
+	}
+
+	fs.remember(p, folderInfo(folder))
+	return nil
+}
+
+// OpenFile opens folders for listing and files for reading. Creating or
+// truncating a file opens it for writing instead: what is written is
+// streamed into a new file, or a new version of the existing one.
+func (fs *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	p := clean(name)
+	info, err := fs.lookup(ctx, p)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	exists := err == nil
+
+	switch {
+	case exists && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, os.ErrExist
+	case exists && info.IsDir():
+		if flag&os.O_TRUNC != 0 {
+			return nil, errIsDirectory
+		}
+		return &dirFile{fs: fs, ctx: ctx, path: p, info: info}, nil
+	case exists && flag&os.O_TRUNC == 0:
+		return &readFile{fs: fs, ctx: ctx, info: info}, nil
+	case !exists && flag&os.O_CREATE == 0:
+		return nil, os.ErrNotExist
+	}
+
+	parentID, err := fs.folderID(ctx, path.Dir(p))
+	if err != nil {
+		return nil, err
+	}
+	return fs.create(ctx, p, parentID), nil
+}
+
+// RemoveAll moves a file to the trash, or deletes a folder with everything
+// below it.
+func (fs *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	p := clean(name)
+	if p == "/" {
+		return os.ErrPermission
+	}
+	info, err := fs.lookup(ctx, p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		err = fs.folders.DeleteFolder(ctx, fs.userID, info.id)
+	} else {
+		err = fs.files.DeleteFile(ctx, fs.userID, info.id)
+	}
+	if err != nil {
+		return fs.fail(err)
+	}
+
+	fs.forget(p)
+	return nil
+}
+
+// Rename moves and renames files and folders. The new parent has to exist.
+func (fs *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	from, to := clean(oldName), clean(newName)
+	if from == "/" || to == "/" {
+		return os.ErrPermission
+	}
+	info, err := fs.lookup(ctx, from)
+	if err != nil {
+		return err
+	}
+	parentID, err := fs.folderID(ctx, path.Dir(to))
+	if err != nil {
+		return err
+	}
+	name := path.Base(to)
+
+	if info.IsDir() {
+		_, err = fs.folders.UpdateFolder(ctx, fs.userID, info.id, domain.FolderUpdate{Name: &name, ParentID: &parentID})
+	} else {
+		_, err = fs.files.UpdateFile(ctx, fs.userID, info.id, domain.FileUpdate{FolderID: &parentID, Filename: &name})
+	}
+	if err != nil {
+		return fs.fail(err)
+	}
+
+	fs.forget(from)
+	fs.forget(to)
+	return nil
+}
+
+// lookup finds the folder or file at p, which must be clean.
+func (fs *FileSystem) lookup(ctx context.Context, p string) (*fileInfo, error) {
+	if p == "/" {
+		return &fileInfo{name: "/", dir: true}, nil
+	}
+
+	fs.mu.Lock()
+	info, ok := fs.entries[p]
+	fs.mu.Unlock()
+	if ok {
+		if info == nil {
+			return nil, os.ErrNotExist
+		}
+		return info, nil
+	}
+
+	folder, err := fs.folders.ResolveFolder(ctx, fs.userID, fs.userID, p)
+	if err == nil {
+		info = folderInfo(folder)
+	} else if errors.Is(err, domain.ErrFolderNotFound) {
+		var file *domain.UserFile
+		file, err = fs.files.ResolvePath(ctx, fs.userID, fs.userID, p)
+		if err == nil {
+			info = userFileInfo(file)
+		}
+	}
+	if errors.Is(err, domain.ErrFileNotFound) {
+		fs.remember(p, nil)
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, fs.fail(err)
+	}
+
+	fs.remember(p, info)
+	return info, nil
+}
+
+// folderID returns the ID of the folder at p, empty for the root. A missing
+// folder, or a file in its place, doesn't exist.
+func (fs *FileSystem) folderID(ctx context.Context, p string) (string, error) {
+	info, err := fs.lookup(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", os.ErrNotExist
+	}
+	return info.id, nil
+}
+
+// contents lists the folder at p and remembers its entries.
+func (fs *FileSystem) contents(ctx context.Context, p string, folderID string) ([]os.FileInfo, error) {
+	var contents *domain.FolderContents
+	var err error
+	if folderID == "" {
+		contents, err = fs.folders.GetRootContents(ctx, fs.userID, fs.userID)
+	} else {
+		contents, err = fs.folders.GetFolderContents(ctx, fs.userID, folderID)
+	}
+	if err != nil {
+		return nil, fs.fail(err)
+	}
+
+	infos := make([]os.FileInfo, 0, len(contents.Folders)+len(contents.Files))
+	folders := map[string]bool{}
+	for _, folder := range contents.Folders {
+		info := folderInfo(folder)
+		folders[info.name] = true
+		fs.remember(path.Join(p, info.name), info)
+		infos = append(infos, info)
+	}
+	for _, file := range contents.Files {
+		info := metaInfo(file)
+		if folders[info.name] {
+			continue
+		}
+		fs.remember(path.Join(p, info.name), info)
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// create starts uploading the file at p and returns the handle its content
+// is written to.
+func (fs *FileSystem) create(ctx context.Context, p string, folderID string) *uploadFile {
+	reader, writer := io.Pipe()
+	upload := &uploadFile{fs: fs, ctx: ctx, path: p, writer: writer, done: make(chan struct{})}
+
+	go func() {
+		defer close(upload.done)
+		upload.meta, upload.err = fs.files.UploadFile(ctx, fs.userID, fs.userID, folderID, path.Base(p), "", nil, nil, nil, reader)
+		// Writes after a failure fail with it instead of blocking
+		reader.CloseWithError(upload.err)
+	}()
+
+	return upload
+}
+
+// fail records err and translates the errors the webdav package knows.
+func (fs *FileSystem) fail(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrFileNotFound), errors.Is(err, domain.ErrFolderNotFound):
+		return os.ErrNotExist
+	case errors.Is(err, domain.ErrFileExists), errors.Is(err, domain.ErrFolderExists):
+		return os.ErrExist
+	}
+
+	fs.mu.Lock()
+	fs.failure = err
+	fs.mu.Unlock()
+
+	if errors.Is(err, domain.ErrForbidden) {
+		return os.ErrPermission
+	}
+	return err
+}
+
+// remember caches the entry at p; nil records that there is none.
+func (fs *FileSystem) remember(p string, info *fileInfo) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.entries[p] = info
+}
+
+// forget drops what is known about p and everything below it.
+func (fs *FileSystem) forget(p string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for cached := range fs.entries {
+		if cached == p || strings.HasPrefix(cached, p+"/") {
+			delete(fs.entries, cached)
+		}
+	}
+}
+
+func clean(name string) string {
+	return path.Clean("/" + name)
+}
+
+// fileInfo describes a folder or file. Folders have no size, content type
+// or digest; the root has no ID either.
+type fileInfo struct {
+	id          string
+	name        string
+	dir         bool
+	size        int64
+	modTime     time.Time
+	contentType string
+	digest      string
+}
+
+var (
+	_ webdav.ContentTyper = (*fileInfo)(nil)
+	_ webdav.ETager       = (*fileInfo)(nil)
+)
+
+func folderInfo(folder *domain.Folder) *fileInfo {
+	return &fileInfo{id: folder.ID, name: folder.Name, dir: true, modTime: folder.CreatedAt}
+}
+
+func metaInfo(file *domain.UserFileMeta) *fileInfo {
+	return &fileInfo{
+		id:          file.ID,
+		name:        file.Filename,
+		size:        file.Size,
+		modTime:     file.UploadedAt,
+		contentType: file.ContentType,
+		digest:      file.Digest,
+	}
+}
+
+func userFileInfo(file *domain.UserFile) *fileInfo {
+	return &fileInfo{
+		id:          file.ID,
+		name:        file.Filename,
+		size:        file.Size,
+		modTime:     file.UploadedAt,
+		contentType: file.ContentType,
+		digest:      file.Digest,
+	}
+}
+
+func (i *fileInfo) Name() string       { return i.name }
+func (i *fileInfo) Size() int64        { return i.size }
+func (i *fileInfo) ModTime() time.Time { return i.modTime }
+func (i *fileInfo) IsDir() bool        { return i.dir }
+func (i *fileInfo) Sys() interface{}   { return nil }
+
+func (i *fileInfo) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+// ContentType saves the webdav package from opening files to sniff it.
+func (i *fileInfo) ContentType(ctx context.Context) (string, error) {
+	if i.contentType == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return i.contentType, nil
+}
+
+// ETag is the digest of the content, like the ETag of downloads.
+func (i *fileInfo) ETag(ctx context.Context) (string, error) {
+	if i.digest == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + i.digest + `"`, nil
+}
+
+// dirFile is an open folder. Its entries are listed on the first Readdir.
+type dirFile struct {
+	fs      *FileSystem
+	ctx     context.Context
+	path    string
+	info    *fileInfo
+	entries []os.FileInfo
+	listed  bool
+}
+
+func (d *dirFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.listed {
+		entries, err := d.fs.contents(d.ctx, d.path, d.info.id)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.listed = entries, true
+	}
+
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n := min(count, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+func (d *dirFile) Stat() (os.FileInfo, error)                   { return d.info, nil }
+func (d *dirFile) Read(p []byte) (int, error)                   { return 0, errIsDirectory }
+func (d *dirFile) Seek(offset int64, whence int) (int64, error) { return 0, errIsDirectory }
+func (d *dirFile) Write(p []byte) (int, error)                  { return 0, errIsDirectory }
+func (d *dirFile) Close() error                                 { return nil }
+
+// readFile is a file open for reading. Its content is only opened once it is
+// read, as the webdav package also opens files just to look at them.
+type readFile struct {
+	fs      *FileSystem
+	ctx     context.Context
+	info    *fileInfo
+	content io.ReadSeekCloser
+}
+
+func (f *readFile) open() error {
+	if f.content != nil {
+		return nil
+	}
+	_, content, err := f.fs.files.DownloadFile(f.ctx, f.fs.userID, f.info.id)
+	if err != nil {
+		return f.fs.fail(err)
+	}
+	f.content = content
+	return nil
+}
+
+func (f *readFile) Read(p []byte) (int, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	return f.content.Read(p)
+}
+
+func (f *readFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	return f.content.Seek(offset, whence)
+}
+
+func (f *readFile) Close() error {
+	if f.content == nil {
+		return nil
+	}
+	return f.content.Close()
+}
+
+func (f *readFile) Stat() (os.FileInfo, error)               { return f.info, nil }
+func (f *readFile) Readdir(count int) ([]os.FileInfo, error) { return nil, errNotDirectory }
+func (f *readFile) Write(p []byte) (int, error)              { return 0, errReadOnly }
+
+// uploadFile is a file open for writing. What is written is streamed to
+// UploadFile as it arrives; the upload finishes on Stat or Close, which
+// report how it went. Content of a request canceled meanwhile, such as one
+// whose body broke off, is not stored.
+type uploadFile struct {
+	fs     *FileSystem
+	ctx    context.Context
+	path   string
+	writer *io.PipeWriter
+	done   chan struct{}
+	once   sync.Once
+
+	meta *domain.UserFileMeta
+	err  error
+}
+
+func (f *uploadFile) Write(p []byte) (int, error) {
+	return f.writer.Write(p)
+}
+
+func (f *uploadFile) finish() error {
+	f.once.Do(func() {
+		_ = f.writer.CloseWithError(f.ctx.Err())
+		<-f.done
+		if f.err != nil {
+			f.err = f.fs.fail(f.err)
+			return
+		}
+		f.fs.forget(f.path)
+		f.fs.remember(f.path, metaInfo(f.meta))
+	})
+	return f.err
+}
+
+func (f *uploadFile) Stat() (os.FileInfo, error) {
+	if err := f.finish(); err != nil {
+		return nil, err
+	}
+	return metaInfo(f.meta), nil
+}
+
+func (f *uploadFile) Close() error {
+	return f.finish()
+}
+
+func (f *uploadFile) Read(p []byte) (int, error)                   { return 0, errWriteOnly }
+func (f *uploadFile) Seek(offset int64, whence int) (int64, error) { return 0, errWriteOnly }
+func (f *uploadFile) Readdir(count int) ([]os.FileInfo, error)     { return nil, errWriteOnly }
@@ -0,0 +1,186 @@
+package davfs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+// serve sends one request through the webdav package to the tree of user 1.
+func serve(files *mocks.FileUseCase, folders *mocks.FolderUseCase, request *http.Request) (*httptest.ResponseRecorder, *FileSystem) {
+	fs := New(files, folders, 1)
+	handler := &webdav.Handler{Prefix: "/webdav", FileSystem: fs, LockSystem: webdav.NewMemLS()}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder, fs
+}
+
+func TestPropfind_ListsFolderOnce(t *testing.T) {
+	files := new(mocks.FileUseCase)
+	folders := new(mocks.FolderUseCase)
+
+	uploadedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	folders.On("GetRootContents", mock.Anything, uint(1), uint(1)).Return(&domain.FolderContents{
+		Folders: []*domain.Folder{{ID: "docs", UserID: 1, Name: "docs"}, {ID: "dup", UserID: 1, Name: "same"}},
+		Files: []*domain.UserFileMeta{
+			{ID: "abc123", Filename: "report.pdf", Size: 42, ContentType: "application/pdf", Digest: "d1g3st", UploadedAt: uploadedAt},
+			{ID: "def456", Filename: "same", Size: 1},
+		},
+	}, nil)
+
+	request := httptest.NewRequest("PROPFIND", "/webdav/", nil)
+	request.Header.Set("Depth", "1")
+	recorder, _ := serve(files, folders, request)
+
+	require.Equal(t, http.StatusMultiStatus, recorder.Code)
+	body := recorder.Body.String()
+	require.Contains(t, body, "<D:href>/webdav/docs/</D:href>")
+	require.Contains(t, body, "<D:href>/webdav/report.pdf</D:href>")
+	require.Contains(t, body, "<D:getcontentlength>42</D:getcontentlength>")
+	require.Contains(t, body, "<D:getcontenttype>application/pdf</D:getcontenttype>")
+	require.Contains(t, body, `<D:getetag>"d1g3st"</D:getetag>`)
+	// The folder hides the file of the same name
+	require.Equal(t, 1, strings.Count(body, "<D:href>/webdav/same"))
+	// Entries come from the listing rather than a lookup each
+	files.AssertNotCalled(t, "ResolvePath", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	folders.AssertNotCalled(t, "ResolveFolder", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGet_ServesContent(t *testing.T) {
+	files := new(mocks.FileUseCase)
+	folders := new(mocks.FolderUseCase)
+
+	file := &domain.UserFile{ID: "abc123", UserID: 1, Filename: "notes.txt", Size: 5, Digest: "d1g3st"}
+	folders.On("ResolveFolder", mock.Anything, uint(1), uint(1), "/notes.txt").Return(nil, domain.ErrFolderNotFound)
+	files.On("ResolvePath", mock.Anything, uint(1), uint(1), "/notes.txt").Return(file, nil)
+	content := mocks.NewContent("hello")
+	files.On("DownloadFile", mock.Anything, uint(1), "abc123").Return(file, content, nil)
+
+	recorder, _ := serve(files, folders, httptest.NewRequest(http.MethodGet, "/webdav/notes.txt", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "hello", recorder.Body.String())
+	require.Equal(t, `"d1g3st"`, recorder.Header().Get("ETag"))
+	require.True(t, content.Closed)
+}
+
+func TestPut_UploadsIntoFolder(t *testing.T) {
+	files := new(mocks.FileUseCase)
+	folders := new(mocks.FolderUseCase)
+
+	folders.On("ResolveFolder", mock.Anything, uint(1), uint(1), "/docs/plan.txt").Return(nil, domain.ErrFolderNotFound)
+	files.On("ResolvePath", mock.Anything, uint(1), uint(1), "/docs/plan.txt").Return(nil, domain.ErrFileNotFound)
+	folders.On("ResolveFolder", mock.Anything, uint(1), uint(1), "/docs").Return(&domain.Folder{ID: "docs", UserID: 1, Name: "docs"}, nil)
+	files.On("UploadFile", mock.Anything, uint(1), uint(1), "docs", "plan.txt", "", []string(nil), (*time.Time)(nil), []domain.ContentDigest(nil), mock.Anything).
+		Run(func(args mock.Arguments) {
+			// The body is streamed through as it arrives
+			data, err := io.ReadAll(args.Get(9).(io.Reader))
+			require.NoError(t, err)
+			require.Equal(t, "step one", string(data))
+		}).
+		Return(&domain.UserFileMeta{ID: "abc123", Filename: "plan.txt", Size: 8, Digest: "d1g3st"}, nil)
+
+	recorder, _ := serve(files, folders, httptest.NewRequest(http.MethodPut, "/webdav/docs/plan.txt", strings.NewReader("step one")))
+
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	require.Equal(t, `"d1g3st"`, recorder.Header().Get("ETag"))
+	files.AssertExpectations(t)
+}
+
+func TestPut_MissingFolder(t *testing.T) {
+	files := new(mocks.FileUseCase)
+	folders := new(mocks.FolderUseCase)
+
+	folders.On("ResolveFolder", mock.Anything, uint(1), uint(1), mock.Anything).Return(nil, domain.ErrFolderNotFound)
+	files.On("ResolvePath", mock.Anything, uint(1), uint(1), mock.Anything).Return(nil, domain.ErrFileNotFound)
+
+	recorder, _ := serve(files, folders, httptest.NewRequest(http.MethodPut, "/webdav/missing/plan.txt", strings.NewReader("step one")))
+
+	require.Equal(t, http.StatusConflict, recorder.Code)
+	files.AssertNotCalled(t, "UploadFile", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPut_RecordsUploadError(t *testing.T) {
+	files := new(mocks.FileUseCase)
+	folders := new(mocks.FolderUseCase)
+
+	folders.On("ResolveFolder", mock.Anything, uint(1), uint(1), "/big.bin").Return(nil, domain.ErrFolderNotFound)
+	files.On("ResolvePath", mock.Anything, uint(1), uint(1), "/big.bin").Return(nil, domain.ErrFileNotFound)
+	files.On("UploadFile", mock.Anything, uint(1), uint(1), "", "big.bin", "", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, domain.ErrQuotaExceeded)
+
+	recorder, fs := serve(files, folders, httptest.NewRequest(http.MethodPut, "/webdav/big.bin", strings.NewReader("too much")))
+
+	// The webdav package only knows the write failed; the file system knows why
+	require.GreaterOrEqual(t, recorder.Code, http.StatusBadRequest)
+	require.ErrorIs(t, fs.Err(), domain.ErrQuotaExceeded)
+}
+
+func TestMkcol_CreatesFolder(t *testing.T) {
+	files := new(mocks.FileUseCase)
+	folders := new(mocks.FolderUseCase)
+
+	folders.On("ResolveFolder", mock.Anything, uint(1), uint(1), "/docs/2026").Return(nil, domain.ErrFolderNotFound)
+	files.On("ResolvePath", mock.Anything, uint(1), uint(1), "/docs/2026").Return(nil, domain.ErrFileNotFound)
+	folders.On("ResolveFolder", mock.Anything, uint(1), uint(1), "/docs").Return(&domain.Folder{ID: "docs", UserID: 1, Name: "docs"}, nil)
+	folders.On("CreateFolder", mock.Anything, uint(1), uint(1), domain.CreateFolderRequest{Name: "2026", ParentID: "docs"}).
+		Return(&domain.Folder{ID: "2026", UserID: 1, ParentID: "docs", Name: "2026"}, nil)
+
+	recorder, _ := serve(files, folders, httptest.NewRequest("MKCOL", "/webdav/docs/2026", nil))
+
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	folders.AssertExpectations(t)
+}
+
+func TestMove_RenamesFileIntoFolder(t *testing.T) {
+	files := new(mocks.FileUseCase)
+	folders := new(mocks.FolderUseCase)
+
+	file := &domain.UserFile{ID: "abc123", UserID: 1, Filename: "draft.txt"}
+	folders.On("ResolveFolder", mock.Anything, uint(1), uint(1), "/draft.txt").Return(nil, domain.ErrFolderNotFound)
+	files.On("ResolvePath", mock.Anything, uint(1), uint(1), "/draft.txt").Return(file, nil)
+	folders.On("ResolveFolder", mock.Anything, uint(1), uint(1), "/docs/final.txt").Return(nil, domain.ErrFolderNotFound)
+	files.On("ResolvePath", mock.Anything, uint(1), uint(1), "/docs/final.txt").Return(nil, domain.ErrFileNotFound)
+	folders.On("ResolveFolder", mock.Anything, uint(1), uint(1), "/docs").Return(&domain.Folder{ID: "docs", UserID: 1, Name: "docs"}, nil)
+	files.On("UpdateFile", mock.Anything, uint(1), "abc123", mock.MatchedBy(func(update domain.FileUpdate) bool {
+		return update.FolderID != nil && *update.FolderID == "docs" && update.Filename != nil && *update.Filename == "final.txt"
+	})).Return(file, nil)
+
+	request := httptest.NewRequest("MOVE", "/webdav/draft.txt", nil)
+	request.Header.Set("Destination", "/webdav/docs/final.txt")
+	recorder, _ := serve(files, folders, request)
+
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	files.AssertExpectations(t)
+}
+
+func TestDelete_Folder(t *testing.T) {
+	files := new(mocks.FileUseCase)
+	folders := new(mocks.FolderUseCase)
+
+	folders.On("ResolveFolder", mock.Anything, uint(1), uint(1), "/docs").Return(&domain.Folder{ID: "docs", UserID: 1, Name: "docs"}, nil)
+	folders.On("DeleteFolder", mock.Anything, uint(1), "docs").Return(nil)
+
+	recorder, _ := serve(files, folders, httptest.NewRequest(http.MethodDelete, "/webdav/docs", nil))
+
+	require.Equal(t, http.StatusNoContent, recorder.Code)
+	folders.AssertExpectations(t)
+}
+
+func TestRemoveAll_KeepsRoot(t *testing.T) {
+	fs := New(new(mocks.FileUseCase), new(mocks.FolderUseCase), 1)
+
+	require.ErrorIs(t, fs.RemoveAll(context.Background(), "/"), os.ErrPermission)
+}
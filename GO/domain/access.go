@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrForbidden     = errors.New("forbidden")
+	ErrGrantNotFound = errors.New("grant not found")
+	ErrInvalidGrant  = errors.New("invalid grant")
+)
+
+type Permission string
+
+const (
+	PermissionRead  Permission = "read"
+	PermissionWrite Permission = "write"
+)
+
+// Allows reports whether p is enough for required; write implies read.
+func (p Permission) Allows(required Permission) bool {
+	return p == PermissionWrite || p == required
+}
+
+type ResourceType string
+
+const (
+	ResourceFile   ResourceType = "file"
+	ResourceFolder ResourceType = "folder"
+)
+
+type Resource struct {
+	Type ResourceType `bson:"type" json:"type"`
+	ID   string       `bson:"id" json:"id"`
+}
+
+// Grant gives a user other than the owner access to a file or folder. A
+// grant on a folder applies to everything below it.
+type Grant struct {
+	ID         string     `bson:"_id,omitempty" json:"id"`
+	Resource   Resource   `bson:"resource" json:"resource"`
+	OwnerID    uint       `bson:"ownerId" json:"ownerId"`
+	UserID     uint       `bson:"userId" json:"userId"`
+	Permission Permission `bson:"permission" json:"permission"`
+	CreatedAt  time.Time  `bson:"createdAt" json:"createdAt"`
+}
+
+type GrantRequest struct {
+	UserID     uint       `json:"userId" binding:"required"`
+	Permission Permission `json:"permission" binding:"required"`
+}
+
+// SharedWithMe lists the files and folders other users granted the caller
+// access to, with the permission of each grant.
+type SharedWithMe struct {
+	Files   []*SharedFile   `json:"files"`
+	Folders []*SharedFolder `json:"folders"`
+}
+
+type SharedFile struct {
+	*UserFileMeta
+	OwnerID    uint       `json:"ownerId"`
+	Permission Permission `json:"permission"`
+}
+
+type SharedFolder struct {
+	*Folder
+	Permission Permission `json:"permission"`
+}
+
+type AccessUseCase interface {
+	GrantAccess(ctx context.Context, callerID uint, resource Resource, request GrantRequest) (*Grant, error)
+	GetGrants(ctx context.Context, callerID uint, resource Resource) ([]*Grant, error)
+	RevokeAccess(ctx context.Context, callerID uint, resource Resource, userID uint) error
+	GetSharedWithMe(ctx context.Context, callerID uint) (*SharedWithMe, error)
+}
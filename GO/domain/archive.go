@@ -0,0 +1,26 @@
+package domain
+
+// ArchiveFilter narrows an archive of a user's files. An empty filter
+// includes every file.
+type ArchiveFilter struct {
+	// FolderID limits the archive to a folder and everything below it
+	FolderID string
+	// FileIDs limits the archive to the listed files
+	FileIDs []string
+}
+
+// ArchiveEntry is one file in an archive with its unique path inside it.
+type ArchiveEntry struct {
+	Path string
+	File *UserFile
+}
+
+// Archive lists the files of an archive before it is streamed.
+type Archive struct {
+	// Name is the suggested filename of the archive
+	Name    string
+	Entries []ArchiveEntry
+	// Skipped counts files left out because they can't be downloaded until
+	// they pass the malware scan
+	Skipped int
+}
@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps the bytes of stored content. Blobs are written once and
+// never modified; everything describing them stays in MongoDB. Buckets keep
+// different kinds of blobs, like file contents and thumbnails, apart.
+type BlobStore interface {
+	// Name identifies the backend. It is recorded with every blob, so blobs
+	// are read from the backend they were written to.
+	Name() string
+	// Create starts writing a new blob. It is stored once the writer is
+	// closed; until then it can't be opened.
+	Create(ctx context.Context, bucket, id string) (BlobWriter, error)
+	// Open reads a blob and returns ErrBlobNotFound when it doesn't exist.
+	// The reader may implement Skip(n int64) (int64, error) to skip content
+	// without reading it.
+	Open(ctx context.Context, bucket, id string) (io.ReadCloser, error)
+	// Delete removes a blob. Deleting a blob that doesn't exist succeeds.
+	Delete(ctx context.Context, bucket, id string) error
+}
+
+// BlobWriter writes a new blob. Abort discards it instead of storing it.
+type BlobWriter interface {
+	io.WriteCloser
+	Abort() error
+}
@@ -1,23 +1,258 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
 
+var (
+	ErrFileNotFound          = errors.New("file not found")
+	ErrFileVersionNotFound   = errors.New("file version not found")
+	ErrFileExists            = errors.New("file already exists")
+	ErrInvalidFilename       = errors.New("invalid filename")
+	ErrInvalidMetadataKey    = errors.New("invalid metadata key")
+	ErrContentTypeMismatch   = errors.New("content type does not match file content")
+	ErrContentTypeNotAllowed = errors.New("content type not allowed")
+	ErrTooManyFiles          = errors.New("too many files in one upload")
+	ErrInvalidExpiry         = errors.New("invalid expiry")
+	ErrFileExpired           = errors.New("file has expired")
+)
+
+// UserFile is a logical file identified by user, folder and filename. Its top level
+// content fields always describe the current version; Versions holds the
+// full history ordered by version number.
 type UserFile struct {
-	ID          string `bson:"_id,omitempty" json:"id"`
-	UserID      uint   `bson:"userId" json:"userId"`
-	Filename    string `bson:"filename" json:"filename"`
-	ContentType string `bson:"contentType" json:"contentType"`
-	Data        []byte `bson:"data" json:"-"`
+	ID          string            `bson:"_id,omitempty" json:"id"`
+	UserID      uint              `bson:"userId" json:"userId"`
+	FolderID    string            `bson:"folderId,omitempty" json:"folderId,omitempty"`
+	Filename    string            `bson:"filename" json:"filename"`
+	ContentType string            `bson:"contentType" json:"contentType"`
+	Size        int64             `bson:"size" json:"size"`
+	BlobID      string            `bson:"blobId,omitempty" json:"-"`
+	Store       string            `bson:"store,omitempty" json:"-"`
+	Digest      string            `bson:"digest,omitempty" json:"digest"`
+	Encoding    string            `bson:"encoding,omitempty" json:"encoding,omitempty"`
+	StoredSize  int64             `bson:"storedSize,omitempty" json:"storedSize,omitempty"`
+	UploadedAt  time.Time         `bson:"uploadedAt" json:"uploadedAt"`
+	Version     int               `bson:"version" json:"version"`
+	ScanStatus  ScanStatus        `bson:"scanStatus,omitempty" json:"scanStatus"`
+	Corrupt     bool              `bson:"corrupt,omitempty" json:"corrupt,omitempty"`
+	Versions    []FileVersion     `bson:"versions" json:"versions"`
+	Description string            `bson:"description,omitempty" json:"description,omitempty"`
+	Metadata    map[string]string `bson:"metadata,omitempty" json:"metadata,omitempty"`
+	Tags        []string          `bson:"tags,omitempty" json:"tags,omitempty"`
+	// ExpiresAt is when the file is deleted for good, skipping the trash.
+	ExpiresAt *time.Time `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	// DeletedAt is set while the file is in the trash.
+	DeletedAt *time.Time `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"`
+	// ThumbnailStatus and Thumbnails describe the thumbnails of the current
+	// version; they are reset whenever a new version becomes current.
+	ThumbnailStatus ThumbnailStatus `bson:"thumbnailStatus,omitempty" json:"thumbnailStatus,omitempty"`
+	Thumbnails      []Thumbnail     `bson:"thumbnails,omitempty" json:"thumbnails,omitempty"`
+	// Data holds the content of documents written before files were moved to GridFS.
+	Data []byte `bson:"data,omitempty" json:"-"`
+}
+
+type FileVersion struct {
+	Number      int       `bson:"number" json:"number"`
+	BlobID      string    `bson:"blobId" json:"-"`
+	Store       string    `bson:"store,omitempty" json:"-"`
+	Digest      string    `bson:"digest" json:"digest"`
+	Size        int64     `bson:"size" json:"size"`
+	ContentType string    `bson:"contentType" json:"contentType"`
+	UploadedAt  time.Time `bson:"uploadedAt" json:"uploadedAt"`
+	// Only clean versions can be downloaded.
+	ScanStatus    ScanStatus `bson:"scanStatus,omitempty" json:"scanStatus"`
+	ScanSignature string     `bson:"scanSignature,omitempty" json:"scanSignature,omitempty"`
+	// Encoding is the codec the content is stored compressed with and
+	// StoredSize its compressed size; Size is always the original size.
+	Encoding   string `bson:"encoding,omitempty" json:"encoding,omitempty"`
+	StoredSize int64  `bson:"storedSize,omitempty" json:"storedSize,omitempty"`
+	// Corrupt is set when the stored content no longer matches Digest.
+	Corrupt bool `bson:"corrupt,omitempty" json:"corrupt,omitempty"`
+}
+
+// VersionRetention limits how many old versions of a file are kept. Zero
+// values disable the corresponding limit; the current version is always kept.
+type VersionRetention struct {
+	MaxVersions int `json:"maxVersions"`
+	MaxAgeDays  int `json:"maxAgeDays"`
+}
+
+// FileUpdate changes the descriptive fields of a file or moves it to another
+// folder. Nil fields are left unchanged and an empty FolderID moves the file
+// to the root. Metadata keys are merged into the existing metadata; a null
+// value removes the key.
+type FileUpdate struct {
+	FolderID    *string            `json:"folderId"`
+	Filename    *string            `json:"filename"`
+	ContentType *string            `json:"contentType"`
+	Description *string            `json:"description"`
+	Metadata    map[string]*string `json:"metadata"`
+}
+
+// FileExpiry sets when a file expires, either at ExpiresAt or TTL from now
+// as a duration like "72h". Neither keeps the file until it is deleted.
+type FileExpiry struct {
+	ExpiresAt *time.Time `json:"expiresAt"`
+	TTL       string     `json:"ttl" example:"72h"`
+}
+
+// KnownContentUpload names the file content the user already has is stored
+// as. The fields mean the same as those of a regular upload.
+type KnownContentUpload struct {
+	FolderID  string     `json:"folderId"`
+	Filename  string     `json:"filename"`
+	Tags      []string   `json:"tags"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	TTL       string     `json:"ttl" example:"72h"`
 }
 
 type UserFileMeta struct {
-	ID       string `json:"id"`
-	Filename string `json:"filename"`
+	ID          string    `json:"id"`
+	FolderID    string    `json:"folderId,omitempty"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	Version     int       `json:"version"`
+	UploadedAt  time.Time `json:"uploadedAt"`
+	Tags        []string  `json:"tags,omitempty"`
+	// ExpiresAt is set for files that are deleted automatically.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// Digest is the hex SHA-256 of the current content. Clients can compare it
+	// with local files to skip uploading content the server already has.
+	Digest     string     `json:"digest"`
+	ScanStatus ScanStatus `json:"scanStatus"`
+	// Corrupt is set when the integrity scrubber found the stored content of
+	// the current version damaged.
+	Corrupt bool `json:"corrupt,omitempty"`
+	// HasThumbnail is set once thumbnails of the current version can be
+	// fetched from the thumbnail endpoint.
+	HasThumbnail bool `json:"hasThumbnail"`
+	// DeletedAt is set for files in the trash.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// UploadResult reports the outcome of one file of a multi-file upload. Error
+// is set instead of the file fields when that file was not stored.
+type UploadResult struct {
+	ID         string     `json:"id,omitempty"`
+	Filename   string     `json:"filename"`
+	Size       int64      `json:"size,omitempty"`
+	ScanStatus ScanStatus `json:"scanStatus,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// UploadResponse lists the results of a multi-file upload in the order the
+// files were sent. Error is set when the request was rejected part way;
+// nothing after the point it was rejected at was read.
+type UploadResponse struct {
+	Uploaded int            `json:"uploaded"`
+	Failed   int            `json:"failed"`
+	Files    []UploadResult `json:"files"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// FindVersion returns the version with the given number.
+func (f *UserFile) FindVersion(number int) (FileVersion, bool) {
+	for _, v := range f.Versions {
+		if v.Number == number {
+			return v, true
+		}
+	}
+	return FileVersion{}, false
+}
+
+// AtVersion returns a copy of the file whose content fields describe version v.
+func (f *UserFile) AtVersion(v FileVersion) *UserFile {
+	file := *f
+	file.Version = v.Number
+	file.BlobID = v.BlobID
+	file.Store = v.Store
+	file.Digest = v.Digest
+	file.Size = v.Size
+	file.Encoding = v.Encoding
+	file.StoredSize = v.StoredSize
+	file.ContentType = v.ContentType
+	file.UploadedAt = v.UploadedAt
+	file.ScanStatus = v.ScanStatus
+	file.Corrupt = v.Corrupt
+	return &file
+}
+
+// EncodedContent is implemented by content stored compressed with the
+// file's Encoding. Encoded opens the stored bytes, which can be sent as they
+// are to clients that accept the encoding.
+type EncodedContent interface {
+	Encoded() (io.ReadSeekCloser, error)
 }
 
+// FileUseCase operations act on behalf of callerID, the authenticated user.
+// Owners may do anything with their files; other users need a grant.
 type FileUseCase interface {
-	UploadFile(ctx context.Context, userID uint, filename, contentType string, data []byte) error
-	GetFileByID(ctx context.Context, id string) (*UserFile, error)
-	GetFilesByUserID(ctx context.Context, userID uint) ([]*UserFileMeta, error)
-	DeleteFilesByUserID(ctx context.Context, userID uint) error
+	// UploadFile stores content as a new file or a new version of the file
+	// with the same name. tags are added to the tags the file already has;
+	// a non-nil expiresAt replaces its expiry. Content that doesn't match
+	// every one of digests is not stored.
+	UploadFile(ctx context.Context, callerID, userID uint, folderID, filename, contentType string, tags []string, expiresAt *time.Time, digests []ContentDigest, content io.Reader) (*UserFileMeta, error)
+	// CheckUpload reports whether UploadFile would accept size bytes for the
+	// name without storing anything.
+	CheckUpload(ctx context.Context, callerID, userID uint, folderID, filename string, size int64) error
+	// HasContent reports whether one of the user's files holds clean content
+	// with the hex SHA-256 digest. UploadKnownContent stores that content as
+	// a new file or version without it being sent again.
+	HasContent(ctx context.Context, callerID, userID uint, digest string) (bool, error)
+	UploadKnownContent(ctx context.Context, callerID, userID uint, folderID, filename string, tags []string, expiresAt *time.Time, digest string) (*UserFileMeta, error)
+	GetFileByID(ctx context.Context, callerID uint, id string) (*UserFile, error)
+	ResolvePath(ctx context.Context, callerID, userID uint, path string) (*UserFile, error)
+	UpdateFile(ctx context.Context, callerID uint, id string, update FileUpdate) (*UserFile, error)
+	DeleteFile(ctx context.Context, callerID uint, id string) error
+	DownloadFile(ctx context.Context, callerID uint, id string) (*UserFile, io.ReadSeekCloser, error)
+	GetFileVersions(ctx context.Context, callerID uint, id string) ([]FileVersion, error)
+	DownloadFileVersion(ctx context.Context, callerID uint, id string, version int) (*UserFile, io.ReadSeekCloser, error)
+	RestoreFileVersion(ctx context.Context, callerID uint, id string, version int) (*UserFileMeta, error)
+	PruneFileVersions(ctx context.Context, callerID, userID uint, retention VersionRetention) (int, error)
+	GetFilesByUserID(ctx context.Context, callerID, userID uint, tags TagFilter) ([]*UserFileMeta, error)
+	SearchFiles(ctx context.Context, callerID, userID uint, search FileSearch) (*FileSearchResult, error)
+	// SearchContent ranks the files the caller may read by how well their
+	// text matches query and returns at most limit of them.
+	SearchContent(ctx context.Context, callerID uint, query string, limit int) ([]*ContentSearchHit, error)
+	// GetArchive lists the files of the user that an archive selected by
+	// filter contains. WriteArchive streams it as a ZIP file.
+	GetArchive(ctx context.Context, callerID, userID uint, filter ArchiveFilter) (*Archive, error)
+	WriteArchive(ctx context.Context, archive *Archive, w io.Writer) error
+	DeleteFilesByUserID(ctx context.Context, callerID, userID uint) error
+	SetFileTags(ctx context.Context, callerID uint, id string, tags []string) (*UserFileMeta, error)
+	// GetTags lists the tags of the user's files, most used first.
+	GetTags(ctx context.Context, callerID, userID uint) ([]*TagCount, error)
+	// RenameTags renames tags on all files of the user and returns how many
+	// files changed.
+	RenameTags(ctx context.Context, callerID, userID uint, rename TagRename) (int, error)
+	// Deleted files are kept in the trash until it is emptied or they
+	// expire. GetTrash lists them, most recently deleted first.
+	GetTrash(ctx context.Context, callerID, userID uint) ([]*UserFileMeta, error)
+	RestoreFile(ctx context.Context, callerID uint, id string) (*UserFileMeta, error)
+	EmptyTrash(ctx context.Context, callerID, userID uint) (int, error)
+	// PurgeTrash permanently deletes files that have been in the trash for
+	// longer than the retention period and returns how many were deleted.
+	PurgeTrash(ctx context.Context) (int, error)
+	// SetFileExpiry changes when a file expires; nil keeps it for good.
+	SetFileExpiry(ctx context.Context, callerID uint, id string, expiresAt *time.Time) (*UserFileMeta, error)
+	// ExpireFiles permanently deletes files past their expiry and returns how
+	// many were deleted.
+	ExpireFiles(ctx context.Context) (int, error)
+	// ScrubContent verifies stored content that is due for it and returns
+	// how much was found corrupt.
+	ScrubContent(ctx context.Context) (int, error)
+	GetStorageUsage(ctx context.Context, callerID, userID uint) (*StorageUsageResponse, error)
+	SetStorageQuota(ctx context.Context, callerID, userID uint, quota *StorageQuota) error
+	GetThumbnail(ctx context.Context, callerID uint, id string, size ThumbnailSize) (*Thumbnail, io.ReadSeekCloser, error)
+	// GenerateThumbnails creates the thumbnails of image files waiting for
+	// them and returns how many files were processed.
+	GenerateThumbnails(ctx context.Context) (int, error)
+	// ScanPendingFiles retries the malware scan of versions that could not be
+	// scanned on upload and returns how many versions got a verdict.
+	ScanPendingFiles(ctx context.Context) (int, error)
 }
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrFolderNotFound    = errors.New("folder not found")
+	ErrFolderExists      = errors.New("folder already exists")
+	ErrInvalidFolderName = errors.New("invalid folder name")
+	ErrFolderCycle       = errors.New("folder can't be moved into itself")
+)
+
+// Folder groups a user's files. Folders without a parent are at the root;
+// names are unique among the folders of the same parent.
+type Folder struct {
+	ID        string    `bson:"_id,omitempty" json:"id"`
+	UserID    uint      `bson:"userId" json:"userId"`
+	ParentID  string    `bson:"parentId,omitempty" json:"parentId,omitempty"`
+	Name      string    `bson:"name" json:"name"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+}
+
+type CreateFolderRequest struct {
+	Name string `json:"name" binding:"required"`
+	// ParentID is empty to create the folder at the root
+	ParentID string `json:"parentId"`
+}
+
+// FolderUpdate renames or moves a folder. Nil fields are left unchanged; an
+// empty ParentID moves the folder to the root.
+type FolderUpdate struct {
+	Name     *string `json:"name"`
+	ParentID *string `json:"parentId"`
+}
+
+// FolderContents lists the direct children of a folder, or of the root when
+// Folder is nil.
+type FolderContents struct {
+	Folder  *Folder         `json:"folder,omitempty"`
+	Folders []*Folder       `json:"folders"`
+	Files   []*UserFileMeta `json:"files"`
+}
+
+type FolderUseCase interface {
+	CreateFolder(ctx context.Context, callerID, userID uint, request CreateFolderRequest) (*Folder, error)
+	GetFolderContents(ctx context.Context, callerID uint, id string) (*FolderContents, error)
+	GetRootContents(ctx context.Context, callerID, userID uint) (*FolderContents, error)
+	// ResolveFolder finds the folder of the user at a slash separated path
+	// such as /reports/2026. Folders the caller may not read are not found.
+	ResolveFolder(ctx context.Context, callerID, userID uint, path string) (*Folder, error)
+	UpdateFolder(ctx context.Context, callerID uint, id string, update FolderUpdate) (*Folder, error)
+	DeleteFolder(ctx context.Context, callerID uint, id string) error
+}
@@ -0,0 +1,27 @@
+package domain
+
+import "errors"
+
+var (
+	ErrInvalidDigest  = errors.New("invalid digest")
+	ErrDigestMismatch = errors.New("content does not match digest")
+	// ErrContentCorrupt is returned when stored content no longer hashes to
+	// its digest or is missing from storage.
+	ErrContentCorrupt = errors.New("stored content is corrupt")
+)
+
+// DigestAlgorithm is a hash algorithm as named in RFC 9530 digest fields.
+type DigestAlgorithm string
+
+const (
+	DigestMD5    DigestAlgorithm = "md5"
+	DigestSHA256 DigestAlgorithm = "sha-256"
+	DigestSHA512 DigestAlgorithm = "sha-512"
+)
+
+// ContentDigest is a digest a client sent along with content, checked
+// against the content as it is stored.
+type ContentDigest struct {
+	Algorithm DigestAlgorithm `bson:"algorithm" json:"algorithm"`
+	Sum       []byte          `bson:"sum" json:"sum"`
+}
@@ -0,0 +1,31 @@
+package domain
+
+import "errors"
+
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// StorageQuota limits what a user can store. Zero values disable the
+// corresponding limit.
+type StorageQuota struct {
+	MaxBytes int64 `bson:"maxBytes" json:"maxBytes"`
+	MaxFiles int   `bson:"maxFiles" json:"maxFiles"`
+}
+
+// StorageUsage is the running total of what a user stores. Bytes count every
+// stored version of every file. Quota is only set for users with an override
+// of the configured default.
+type StorageUsage struct {
+	UserID uint          `bson:"_id" json:"userId"`
+	Bytes  int64         `bson:"bytes" json:"bytes"`
+	Files  int           `bson:"files" json:"files"`
+	Quota  *StorageQuota `bson:"quota,omitempty" json:"-"`
+}
+
+type StorageUsageResponse struct {
+	UsedBytes int64 `json:"usedBytes"`
+	FileCount int   `json:"fileCount"`
+	MaxBytes  int64 `json:"maxBytes"`
+	MaxFiles  int   `json:"maxFiles"`
+	// Custom is true when the limits are a per-user override of the default
+	Custom bool `json:"custom"`
+}
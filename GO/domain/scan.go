@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrFileNotScanned  = errors.New("file has not passed the malware scan yet")
+	ErrFileQuarantined = errors.New("file is quarantined")
+)
+
+// ScanStatus is the outcome of scanning one version of a file for malware.
+type ScanStatus string
+
+const (
+	// ScanPending content is waiting for a scan, including scans that failed
+	// to reach the scanner and are retried in the background.
+	ScanPending  ScanStatus = "pending"
+	ScanClean    ScanStatus = "clean"
+	ScanInfected ScanStatus = "infected"
+	// ScanError content was rejected by the scanner, for example for being too large.
+	ScanError ScanStatus = "error"
+)
+
+// Check returns nil when content with this status may be served.
+func (s ScanStatus) Check() error {
+	switch s {
+	case ScanClean:
+		return nil
+	case ScanInfected:
+		return ErrFileQuarantined
+	}
+	return ErrFileNotScanned
+}
+
+type ScanResult struct {
+	Status ScanStatus
+	// Signature names the malware found in infected content.
+	Signature string
+}
+
+// Scanner checks content for malware. Implementations read content until EOF
+// or until they have a verdict. An error means no verdict could be reached
+// and the scan should be retried later.
+type Scanner interface {
+	Scan(ctx context.Context, content io.Reader) (*ScanResult, error)
+}
+
+// QuarantineEntry records an infected file version. The content is kept for
+// review but can't be downloaded, shared or restored.
+type QuarantineEntry struct {
+	ID         string    `bson:"_id,omitempty" json:"id"`
+	FileID     string    `bson:"fileId" json:"fileId"`
+	UserID     uint      `bson:"userId" json:"userId"`
+	Filename   string    `bson:"filename" json:"filename"`
+	Version    int       `bson:"version" json:"version"`
+	Digest     string    `bson:"digest" json:"digest"`
+	Signature  string    `bson:"signature" json:"signature"`
+	DetectedAt time.Time `bson:"detectedAt" json:"detectedAt"`
+}
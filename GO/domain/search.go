@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidSearch = errors.New("invalid search")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// FileSort is the field search results are ordered by.
+type FileSort string
+
+const (
+	FileSortName        FileSort = "name"
+	FileSortSize        FileSort = "size"
+	FileSortUploadedAt  FileSort = "uploadedAt"
+	FileSortContentType FileSort = "contentType"
+)
+
+// Valid reports whether files can be ordered by s.
+func (s FileSort) Valid() bool {
+	switch s {
+	case FileSortName, FileSortSize, FileSortUploadedAt, FileSortContentType:
+		return true
+	}
+	return false
+}
+
+// FileSearch selects a page of a user's files. Zero fields don't filter.
+type FileSearch struct {
+	// Name matches filenames containing it, ignoring case. With * or ? it is
+	// a glob that has to match the whole filename instead.
+	Name string
+	// ContentType matches exactly, or a whole family like "image/*"
+	ContentType string
+	// MinSize and MaxSize bound the size of the current version, inclusive
+	MinSize *int64
+	MaxSize *int64
+	// UploadedAfter is inclusive and UploadedBefore exclusive
+	UploadedAfter  *time.Time
+	UploadedBefore *time.Time
+	Tags           TagFilter
+	Sort           FileSort
+	Desc           bool
+	// Limit is the page size; zero returns every match
+	Limit int
+	// Cursor continues after the page that returned it. It is only valid
+	// with the same sort.
+	Cursor string
+}
+
+// FileSearchResult is one page of search results. NextCursor is empty on the
+// last page.
+type FileSearchResult struct {
+	Files      []*UserFileMeta `json:"files"`
+	NextCursor string          `json:"nextCursor,omitempty"`
+}
+
+// FileTextMatch is a file whose extracted text matched a full-text query.
+// Higher scores rank first.
+type FileTextMatch struct {
+	FileID  string
+	Version int
+	Score   float64
+}
+
+// ContentSearchHit is a file whose content matched a full-text query, with
+// the matching part of its text. Snippet is HTML with the matching words
+// wrapped in <mark>.
+type ContentSearchHit struct {
+	*UserFileMeta
+	OwnerID uint    `json:"ownerId"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
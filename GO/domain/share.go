@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrShareNotFound      = errors.New("share link not found")
+	ErrShareExpired       = errors.New("share link expired")
+	ErrShareLimitReached  = errors.New("share link download limit reached")
+	ErrSharePasswordWrong = errors.New("share link password required or wrong")
+	ErrInvalidShare       = errors.New("invalid share link limits")
+)
+
+// ShareLink lets anyone holding its token download a file without an
+// account. Only a SHA-256 hash of the token is stored; the token itself is
+// returned once when the link is created.
+type ShareLink struct {
+	ID           string     `bson:"_id,omitempty" json:"id"`
+	FileID       string     `bson:"fileId" json:"fileId"`
+	UserID       uint       `bson:"userId" json:"userId"`
+	TokenHash    string     `bson:"tokenHash" json:"-"`
+	PasswordHash string     `bson:"passwordHash,omitempty" json:"-"`
+	HasPassword  bool       `bson:"-" json:"hasPassword"`
+	ExpiresAt    *time.Time `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	MaxDownloads int        `bson:"maxDownloads,omitempty" json:"maxDownloads,omitempty"`
+	Downloads    int        `bson:"downloads" json:"downloads"`
+	CreatedAt    time.Time  `bson:"createdAt" json:"createdAt"`
+}
+
+// CreateShareRequest describes a new link. Omitted limits don't apply.
+type CreateShareRequest struct {
+	ExpiresAt    *time.Time `json:"expiresAt"`
+	MaxDownloads int        `json:"maxDownloads"`
+	Password     string     `json:"password"`
+}
+
+type CreateShareResponse struct {
+	Link  *ShareLink `json:"link"`
+	Token string     `json:"token"`
+	// URL is the path the file can be downloaded from with the token
+	URL string `json:"url"`
+}
+
+type ShareUseCase interface {
+	CreateShare(ctx context.Context, callerID uint, fileID string, request CreateShareRequest) (*CreateShareResponse, error)
+	GetFileShares(ctx context.Context, callerID uint, fileID string) ([]*ShareLink, error)
+	GetUserShares(ctx context.Context, callerID, userID uint) ([]*ShareLink, error)
+	RevokeShare(ctx context.Context, callerID uint, id string) error
+	// OpenShare checks the link's expiry, password and download limit and
+	// opens the shared file. It doesn't count a download; that is left to
+	// CountShareDownload once it is known the content is actually sent.
+	OpenShare(ctx context.Context, token, password string) (*UserFile, io.ReadSeekCloser, error)
+	// CountShareDownload uses up one download of the link, failing with
+	// ErrShareLimitReached when none are left.
+	CountShareDownload(ctx context.Context, token string) error
+}
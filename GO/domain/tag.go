@@ -0,0 +1,33 @@
+package domain
+
+import "errors"
+
+var (
+	ErrInvalidTag  = errors.New("invalid tag")
+	ErrTooManyTags = errors.New("too many tags")
+)
+
+// TagFilter matches files with any of Tags, or with all of them when All is
+// set. An empty filter matches every file.
+type TagFilter struct {
+	Tags []string
+	All  bool
+}
+
+// TagCount is a tag of a user with the number of their files carrying it.
+type TagCount struct {
+	Tag   string `bson:"_id" json:"tag"`
+	Count int    `bson:"count" json:"count"`
+}
+
+// FileTags replaces the tags of a file; an empty list removes them all.
+type FileTags struct {
+	Tags []string `json:"tags"`
+}
+
+// TagRename renames tags on all files of a user. Renaming several tags, or
+// onto a tag that is already used, merges them.
+type TagRename struct {
+	From []string `json:"from" binding:"required"`
+	To   string   `json:"to" binding:"required"`
+}
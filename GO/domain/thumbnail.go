@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"errors"
+	"mime"
+	"strings"
+	"time"
+)
+
+var (
+	ErrThumbnailNotFound    = errors.New("thumbnail not found")
+	ErrInvalidThumbnailSize = errors.New("invalid thumbnail size")
+)
+
+type ThumbnailSize string
+
+const (
+	ThumbnailSmall  ThumbnailSize = "small"
+	ThumbnailMedium ThumbnailSize = "medium"
+	ThumbnailLarge  ThumbnailSize = "large"
+)
+
+// ThumbnailSizes lists the thumbnails generated for every image with the
+// edge in pixels of the square each one fits in.
+var ThumbnailSizes = []struct {
+	Size ThumbnailSize
+	Edge int
+}{
+	{ThumbnailSmall, 128},
+	{ThumbnailMedium, 256},
+	{ThumbnailLarge, 512},
+}
+
+// ThumbnailStatus tracks thumbnail generation for the current version of a file.
+type ThumbnailStatus string
+
+const (
+	ThumbnailPending ThumbnailStatus = "pending"
+	ThumbnailReady   ThumbnailStatus = "ready"
+	ThumbnailFailed  ThumbnailStatus = "failed"
+)
+
+// ThumbnailContentTypes are the image types thumbnails are generated for.
+var ThumbnailContentTypes = []string{"image/png", "image/jpeg", "image/gif"}
+
+type Thumbnail struct {
+	Size        ThumbnailSize `bson:"size" json:"size"`
+	BlobID      string        `bson:"blobId" json:"-"`
+	Store       string        `bson:"store,omitempty" json:"-"`
+	ContentType string        `bson:"contentType" json:"contentType"`
+	Width       int           `bson:"width" json:"width"`
+	Height      int           `bson:"height" json:"height"`
+	Length      int64         `bson:"length" json:"length"`
+	CreatedAt   time.Time     `bson:"createdAt" json:"createdAt"`
+}
+
+// HasThumbnails reports whether thumbnails are generated for content of the given type.
+func HasThumbnails(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range ThumbnailContentTypes {
+		if strings.EqualFold(mediaType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// ThumbnailStatusFor returns the status a new version of the given content
+// type starts with, which is empty when no thumbnails are generated.
+func ThumbnailStatusFor(contentType string) ThumbnailStatus {
+	if HasThumbnails(contentType) {
+		return ThumbnailPending
+	}
+	return ""
+}
+
+// FindThumbnail returns the thumbnail of the current version in the given size.
+func (f *UserFile) FindThumbnail(size ThumbnailSize) (Thumbnail, bool) {
+	if f.ThumbnailStatus != ThumbnailReady {
+		return Thumbnail{}, false
+	}
+	for _, t := range f.Thumbnails {
+		if t.Size == size {
+			return t, true
+		}
+	}
+	return Thumbnail{}, false
+}
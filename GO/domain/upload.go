@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrUploadNotFound       = errors.New("upload not found")
+	ErrUploadExpired        = errors.New("upload expired")
+	ErrUploadOffsetMismatch = errors.New("upload offset mismatch")
+	ErrUploadTooLarge       = errors.New("upload exceeds declared length")
+	ErrUploadNotStored      = errors.New("upload received but not stored")
+)
+
+// FileUpload is a resumable upload in progress. Content is kept as an ordered
+// list of parts until Offset reaches Length and the file is stored. Digests
+// the client sent for the whole file are checked then.
+type FileUpload struct {
+	ID          string           `bson:"_id,omitempty" json:"id"`
+	UserID      uint             `bson:"userId" json:"userId"`
+	CreatedBy   uint             `bson:"createdBy,omitempty" json:"createdBy,omitempty"`
+	FolderID    string           `bson:"folderId,omitempty" json:"folderId,omitempty"`
+	Filename    string           `bson:"filename" json:"filename"`
+	ContentType string           `bson:"contentType" json:"contentType"`
+	Length      int64            `bson:"length" json:"length"`
+	Offset      int64            `bson:"offset" json:"offset"`
+	Parts       []FileUploadPart `bson:"parts" json:"-"`
+	FileID      string           `bson:"fileId,omitempty" json:"fileId,omitempty"`
+	Digests     []ContentDigest  `bson:"digests,omitempty" json:"-"`
+	CreatedAt   time.Time        `bson:"createdAt" json:"createdAt"`
+	ExpiresAt   time.Time        `bson:"expiresAt" json:"expiresAt"`
+}
+
+type FileUploadPart struct {
+	BlobID string `bson:"blobId"`
+	Store  string `bson:"store,omitempty"`
+	Size   int64  `bson:"size"`
+}
+
+func (u *FileUpload) Completed() bool {
+	return u.FileID != ""
+}
+
+// Stalled reports whether every byte was received but the file couldn't be
+// stored yet.
+func (u *FileUpload) Stalled() bool {
+	return u.Offset == u.Length && !u.Completed()
+}
+
+// Creator returns the user who started the upload, which is the owner for
+// uploads started before uploads recorded their creator.
+func (u *FileUpload) Creator() uint {
+	if u.CreatedBy == 0 {
+		return u.UserID
+	}
+	return u.CreatedBy
+}
+
+type UploadUseCase interface {
+	CreateUpload(ctx context.Context, callerID, userID uint, length int64, folderID, filename, contentType string, digests []ContentDigest) (*FileUpload, error)
+	GetUpload(ctx context.Context, callerID uint, id string) (*FileUpload, error)
+	WriteChunk(ctx context.Context, callerID uint, id string, offset int64, chunk io.Reader) (*FileUpload, error)
+	TerminateUpload(ctx context.Context, callerID uint, id string) error
+	PurgeExpiredUploads(ctx context.Context) (int, error)
+}
@@ -47,5 +47,8 @@ type UserUseCase interface {
 	CreateUser(c context.Context, user SignUpRequest) (accessToken string, refreshToken string, err error)
 	UpdateUser(c context.Context, user UpdateRequest) error
 	Login(ctx context.Context, request LoginRequest) (accessToken string, refreshToken string, err error)
+	// Authenticate returns the user with the request's email if the password
+	// matches, without issuing tokens.
+	Authenticate(ctx context.Context, request LoginRequest) (*User, error)
 	DeleteUser(ctx context.Context, id uint) error
 }
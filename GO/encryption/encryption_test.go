@@ -0,0 +1,158 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func encrypt(t *testing.T, dataKey, plaintext []byte) []byte {
+	var sealed bytes.Buffer
+	w, err := NewWriter(&sealed, dataKey)
+	require.NoError(t, err)
+	_, err = w.Write(plaintext)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return sealed.Bytes()
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+	return b
+}
+
+// skipReader lets Reader skip whole segments like a GridFS download stream.
+type skipReader struct {
+	*bytes.Reader
+}
+
+func (r skipReader) Skip(n int64) (int64, error) {
+	skipped := min(n, int64(r.Len()))
+	_, err := r.Seek(skipped, io.SeekCurrent)
+	return skipped, err
+}
+
+func TestStream_RoundTrip(t *testing.T) {
+	dataKey := randomBytes(t, KeySize)
+
+	for _, size := range []int{0, 1, SegmentSize, 2 * SegmentSize, 3*SegmentSize + 17} {
+		plaintext := randomBytes(t, size)
+
+		sealed := encrypt(t, dataKey, plaintext)
+		require.Equal(t, EncryptedSize(int64(size)), int64(len(sealed)))
+
+		r, err := NewReader(bytes.NewReader(sealed), dataKey)
+		require.NoError(t, err)
+		decrypted, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, plaintext, append([]byte{}, decrypted...), "size %d", size)
+	}
+}
+
+func TestStream_DetectsTampering(t *testing.T) {
+	dataKey := randomBytes(t, KeySize)
+	sealed := encrypt(t, dataKey, randomBytes(t, 2*SegmentSize+5))
+
+	sealed[SegmentSize+overhead+3] ^= 1
+
+	r, err := NewReader(bytes.NewReader(sealed), dataKey)
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.ErrorIs(t, err, ErrCorrupted)
+}
+
+func TestStream_DetectsTruncation(t *testing.T) {
+	dataKey := randomBytes(t, KeySize)
+	sealed := encrypt(t, dataKey, randomBytes(t, 2*SegmentSize+5))
+
+	// Cutting off whole segments leaves every remaining one intact
+	for _, cut := range [][]byte{sealed[:SegmentSize+overhead], sealed[:2*(SegmentSize+overhead)], sealed[:len(sealed)-1]} {
+		r, err := NewReader(bytes.NewReader(cut), dataKey)
+		require.NoError(t, err)
+		_, err = io.ReadAll(r)
+		require.ErrorIs(t, err, ErrCorrupted)
+	}
+}
+
+func TestStream_WrongKey(t *testing.T) {
+	sealed := encrypt(t, randomBytes(t, KeySize), []byte("secret"))
+
+	r, err := NewReader(bytes.NewReader(sealed), randomBytes(t, KeySize))
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.ErrorIs(t, err, ErrCorrupted)
+}
+
+func TestReader_Skip(t *testing.T) {
+	dataKey := randomBytes(t, KeySize)
+	plaintext := randomBytes(t, 4*SegmentSize+100)
+	sealed := encrypt(t, dataKey, plaintext)
+
+	for _, offset := range []int64{0, 10, SegmentSize, 2*SegmentSize + 7, 4 * SegmentSize} {
+		r, err := NewReader(skipReader{bytes.NewReader(sealed)}, dataKey)
+		require.NoError(t, err)
+
+		// Read a little first so buffered plaintext is skipped too
+		head := make([]byte, 3)
+		_, err = io.ReadFull(r, head)
+		require.NoError(t, err)
+
+		skipped, err := r.Skip(offset)
+		require.NoError(t, err)
+		require.Equal(t, offset, skipped)
+
+		rest, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, plaintext[3+offset:], rest, "offset %d", offset)
+	}
+}
+
+func TestKeyring_RotateWithPreviousKey(t *testing.T) {
+	oldKey, newKey := randomBytes(t, KeySize), randomBytes(t, KeySize)
+	context := []byte("blob-1")
+
+	old, err := NewKeyring(oldKey)
+	require.NoError(t, err)
+	dataKey, err := NewDataKey()
+	require.NoError(t, err)
+	oldID, wrapped, err := old.Wrap(dataKey, context)
+	require.NoError(t, err)
+	require.Equal(t, KeyID(oldKey), oldID)
+
+	keys, err := LoadKeyring(base64.StdEncoding.EncodeToString(newKey), "", " "+base64.StdEncoding.EncodeToString(oldKey)+",")
+	require.NoError(t, err)
+
+	newID, rewrapped, err := keys.Rewrap(oldID, wrapped, context)
+	require.NoError(t, err)
+	require.Equal(t, keys.CurrentKeyID(), newID)
+	require.NotEqual(t, oldID, newID)
+
+	unwrapped, err := keys.Unwrap(newID, rewrapped, context)
+	require.NoError(t, err)
+	require.Equal(t, dataKey, unwrapped)
+
+	// A wrapped key only unwraps for the content it belongs to
+	_, err = keys.Unwrap(newID, rewrapped, []byte("blob-2"))
+	require.Error(t, err)
+
+	// Once the previous key is dropped, keys it wrapped can't be read
+	current, err := NewKeyring(newKey)
+	require.NoError(t, err)
+	_, err = current.Unwrap(oldID, wrapped, context)
+	require.ErrorIs(t, err, ErrUnknownMasterKey)
+}
+
+func TestLoadKeyring(t *testing.T) {
+	keys, err := LoadKeyring("", "", "")
+	require.NoError(t, err)
+	require.Nil(t, keys)
+
+	_, err = LoadKeyring(base64.StdEncoding.EncodeToString([]byte("too short")), "", "")
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
@@ -0,0 +1,157 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+)
+
+// KeySize is the size of master and data keys: AES-256.
+const KeySize = 32
+
+var (
+	ErrInvalidKey       = errors.New("encryption key must be 32 bytes, base64 encoded")
+	ErrUnknownMasterKey = errors.New("content is encrypted with an unknown master key")
+)
+
+// Keyring holds the master keys that wrap data keys. New data keys are
+// wrapped with the current key; previous keys can still unwrap the data keys
+// they wrapped until those are rotated to the current key.
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// LoadKeyring builds a keyring from a base64 encoded master key, or from a
+// file holding one, and a comma separated list of previous keys. It returns
+// nil without a master key, which leaves content unencrypted.
+func LoadKeyring(key, keyFile, previous string) (*Keyring, error) {
+	if key == "" && keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		key = string(data)
+	}
+	if key == "" {
+		return nil, nil
+	}
+
+	current, err := decodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var previousKeys [][]byte
+	for _, k := range strings.Split(previous, ",") {
+		if k = strings.TrimSpace(k); k == "" {
+			continue
+		}
+		decoded, err := decodeKey(k)
+		if err != nil {
+			return nil, err
+		}
+		previousKeys = append(previousKeys, decoded)
+	}
+
+	return NewKeyring(current, previousKeys...)
+}
+
+// NewKeyring returns a keyring wrapping new data keys with current.
+func NewKeyring(current []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{keys: map[string]cipher.AEAD{}}
+
+	for _, key := range append([][]byte{current}, previous...) {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[KeyID(key)] = aead
+	}
+	k.current = KeyID(current)
+
+	return k, nil
+}
+
+// KeyID identifies a master key without revealing it, so wrapped data keys
+// can name the key that unwraps them.
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// CurrentKeyID returns the ID of the key new data keys are wrapped with.
+func (k *Keyring) CurrentKeyID() string {
+	return k.current
+}
+
+// NewDataKey returns a random data key for one piece of content.
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Wrap encrypts dataKey with the current master key. The wrapped key only
+// unwraps with the same context, which binds it to the content it protects.
+func (k *Keyring) Wrap(dataKey, context []byte) (keyID string, wrapped []byte, err error) {
+	aead := k.keys[k.current]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+
+	return k.current, aead.Seal(nonce, nonce, dataKey, context), nil
+}
+
+// Unwrap decrypts a data key wrapped by the master key with the given ID.
+func (k *Keyring) Unwrap(keyID string, wrapped, context []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownMasterKey
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped key is too short")
+	}
+
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, context)
+}
+
+// Rewrap wraps a data key wrapped by an older master key with the current
+// one. The content it protects stays as it is.
+func (k *Keyring) Rewrap(keyID string, wrapped, context []byte) (string, []byte, error) {
+	dataKey, err := k.Unwrap(keyID, wrapped, context)
+	if err != nil {
+		return "", nil, err
+	}
+	return k.Wrap(dataKey, context)
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,190 @@
+package encryption
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// SegmentSize is how much plaintext is sealed at a time. Content is split
+// into segments so it can be streamed and seeked without decrypting it all.
+const SegmentSize = 64 * 1024
+
+var ErrCorrupted = errors.New("encrypted content is corrupted or truncated")
+
+// EncryptedSize returns how large plaintext of the given size is once encrypted.
+func EncryptedSize(size int64) int64 {
+	segments := (size + SegmentSize - 1) / SegmentSize
+	if segments == 0 {
+		segments = 1
+	}
+	return size + segments*overhead
+}
+
+// overhead is the GCM tag added to every segment.
+const overhead = 16
+
+// segmentNonce derives the nonce of a segment from its index. The last
+// segment is marked, so dropping segments from the end is detected. Each
+// data key encrypts a single stream, so nonces never repeat under one key.
+func segmentNonce(index uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, index)
+	if last {
+		nonce[8] = 1
+	}
+	return nonce
+}
+
+// Writer encrypts everything written to it with a data key. Close must be
+// called to seal the last segment; it does not close the underlying writer.
+type Writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	buf    []byte
+	index  uint64
+	closed bool
+}
+
+func NewWriter(w io.Writer, dataKey []byte) (*Writer, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, buf: make([]byte, 0, SegmentSize)}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed encryption writer")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// A full segment is only sealed once more data follows, so the last one is known on Close
+		if len(w.buf) == SegmentSize {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):SegmentSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.seal(true)
+}
+
+func (w *Writer) seal(last bool) error {
+	sealed := w.aead.Seal(nil, segmentNonce(w.index, last), w.buf, nil)
+	w.index++
+	w.buf = w.buf[:0]
+	_, err := w.w.Write(sealed)
+	return err
+}
+
+// Reader decrypts content written by Writer. Reads fail with ErrCorrupted
+// when a segment was modified or the content was cut short.
+type Reader struct {
+	r    io.Reader
+	aead cipher.AEAD
+	buf  []byte
+	// out holds decrypted segments apart from buf, as a failed Open clears its output
+	out   []byte
+	plain []byte
+	index uint64
+	done  bool
+}
+
+func NewReader(r io.Reader, dataKey []byte) (*Reader, error) {
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: r, aead: aead, buf: make([]byte, SegmentSize+overhead), out: make([]byte, SegmentSize)}, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// Skip discards n bytes of plaintext. Whole segments are skipped without
+// decrypting them when the underlying reader can skip too, as GridFS
+// download streams can.
+func (r *Reader) Skip(n int64) (int64, error) {
+	skipped := int64(0)
+	if buffered := min(n, int64(len(r.plain))); buffered > 0 {
+		r.plain = r.plain[buffered:]
+		skipped += buffered
+	}
+
+	if skipper, ok := r.r.(interface{ Skip(int64) (int64, error) }); ok && !r.done {
+		// The segment the target lies in is still decrypted, so a cut off stream is detected
+		segments := (n - skipped - 1) / SegmentSize
+		if segments > 0 {
+			done, err := skipper.Skip(segments * (SegmentSize + overhead))
+			r.index += uint64(done / (SegmentSize + overhead))
+			skipped += done / (SegmentSize + overhead) * SegmentSize
+			if err != nil {
+				return skipped, err
+			}
+		}
+	}
+
+	discarded, err := io.CopyN(io.Discard, r, n-skipped)
+	return skipped + discarded, err
+}
+
+// open reads and decrypts the next segment.
+func (r *Reader) open() error {
+	n, err := io.ReadFull(r.r, r.buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// Only the last segment may be shorter than a full one
+		if n < overhead {
+			return ErrCorrupted
+		}
+		return r.decrypt(r.buf[:n], true)
+	}
+	if err != nil {
+		return err
+	}
+
+	// A full segment is last when the content ends right after it
+	if plain, err := r.aead.Open(r.out[:0], segmentNonce(r.index, false), r.buf, nil); err == nil {
+		r.plain = plain
+		r.index++
+		return nil
+	}
+	return r.decrypt(r.buf, true)
+}
+
+func (r *Reader) decrypt(sealed []byte, last bool) error {
+	plain, err := r.aead.Open(r.out[:0], segmentNonce(r.index, last), sealed, nil)
+	if err != nil {
+		return ErrCorrupted
+	}
+	r.plain = plain
+	r.index++
+	r.done = last
+	return nil
+}
@@ -0,0 +1,198 @@
+// Package fulltext extracts the searchable text of text-based content and
+// highlights matches of a query in it.
+package fulltext
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// MaxContentLength bounds how much of a file is read for its text, so large
+// files are indexed by their beginning.
+const MaxContentLength = 1 << 20
+
+// Format is how text is extracted from content.
+type Format string
+
+const (
+	Plain    Format = "plain"
+	CSV      Format = "csv"
+	JSON     Format = "json"
+	Markdown Format = "markdown"
+	HTML     Format = "html"
+)
+
+// FormatOf returns the format of content with the detected type, or "" when
+// it has no text to extract. Markdown is detected as plain text, so its
+// extension decides.
+func FormatOf(contentType, filename string) Format {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	switch mediaType {
+	case "text/csv":
+		return CSV
+	case "application/json":
+		return JSON
+	case "text/html":
+		return HTML
+	case "text/markdown":
+		return Markdown
+	case "text/plain":
+		switch strings.ToLower(path.Ext(filename)) {
+		case ".md", ".markdown":
+			return Markdown
+		}
+		return Plain
+	}
+	return ""
+}
+
+// Extract returns the text of content in format with whitespace collapsed.
+// Content cut short, as by a length limit, gives the text up to the cut.
+func Extract(format Format, content io.Reader) (string, error) {
+	var text strings.Builder
+	var err error
+	switch format {
+	case CSV:
+		err = extractCSV(content, &text)
+	case JSON:
+		err = extractJSON(content, &text)
+	case HTML:
+		err = extractHTML(content, &text)
+	case Markdown:
+		err = extractMarkdown(content, &text)
+	case Plain:
+		_, err = io.Copy(&text, content)
+	default:
+		return "", errors.New("fulltext: unknown format " + string(format))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return normalize(text.String()), nil
+}
+
+func extractCSV(content io.Reader, text *strings.Builder) error {
+	reader := csv.NewReader(content)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// Keep the rows before a malformed or truncated one
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil
+			}
+			return err
+		}
+		text.WriteString(strings.Join(record, " "))
+		text.WriteString("\n")
+	}
+}
+
+// extractJSON keeps the keys and string values of a document.
+func extractJSON(content io.Reader, text *strings.Builder) error {
+	decoder := json.NewDecoder(content)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return err
+		}
+		if s, ok := token.(string); ok {
+			text.WriteString(s)
+			text.WriteString("\n")
+		}
+	}
+}
+
+// extractHTML keeps the text of a page without its scripts and styles.
+func extractHTML(content io.Reader, text *strings.Builder) error {
+	tokenizer := html.NewTokenizer(content)
+	skip := 0
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return err
+			}
+			return nil
+		case html.StartTagToken:
+			if name, _ := tokenizer.TagName(); hiddenElement(name) {
+				skip++
+			}
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); hiddenElement(name) && skip > 0 {
+				skip--
+			}
+		case html.TextToken:
+			if skip == 0 {
+				// Tags separate words even where the page has no whitespace
+				text.Write(tokenizer.Text())
+				text.WriteString(" ")
+			}
+		}
+	}
+}
+
+func hiddenElement(name []byte) bool {
+	switch string(name) {
+	case "script", "style", "noscript", "template":
+		return true
+	}
+	return false
+}
+
+var (
+	markdownLink     = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	markdownLineMark = regexp.MustCompile(`(?m)^[ \t]*(?:#{1,6}|>+|[-*+]|\d+\.)[ \t]+`)
+	markdownEmphasis = regexp.MustCompile("[*_`~]+")
+)
+
+// extractMarkdown keeps the text of a document without its syntax. Links
+// and images keep their text.
+func extractMarkdown(content io.Reader, text *strings.Builder) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return err
+	}
+
+	s := markdownLink.ReplaceAllString(string(data), "$1")
+	s = markdownLineMark.ReplaceAllString(s, "")
+	s = markdownEmphasis.ReplaceAllString(s, "")
+	text.WriteString(s)
+	return nil
+}
+
+// normalize collapses whitespace and drops invalid UTF-8, including a
+// character cut in half at the end of truncated content.
+func normalize(text string) string {
+	if !utf8.ValidString(text) {
+		text = strings.ToValidUTF8(text, " ")
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,69 @@
+package fulltext
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatOf(t *testing.T) {
+	require.Equal(t, Plain, FormatOf("text/plain; charset=utf-8", "notes.txt"))
+	require.Equal(t, Markdown, FormatOf("text/plain; charset=utf-8", "README.md"))
+	require.Equal(t, CSV, FormatOf("text/csv", "data.csv"))
+	require.Equal(t, JSON, FormatOf("application/json", "data.json"))
+	require.Equal(t, HTML, FormatOf("text/html; charset=utf-8", "page.html"))
+	require.Equal(t, Format(""), FormatOf("image/png", "photo.png"))
+	require.Equal(t, Format(""), FormatOf("", "notes.txt"))
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		format  Format
+		content string
+		want    string
+	}{
+		{Plain, "quarterly\n\n  report\t2026", "quarterly report 2026"},
+		{CSV, "id,name\n1,\"blue widget\"\n2,gadget", "id name 1 blue widget 2 gadget"},
+		{JSON, `{"title": "Budget", "items": [{"name": "rent", "amount": 1200}]}`, "title Budget items name rent amount"},
+		{Markdown, "# Plan\n\n- **first** step\n- see [the docs](https://example.com)\n", "Plan first step see the docs"},
+		{HTML, "<html><head><style>p{}</style><script>var x=1</script></head><body><p>Hello<b>world</b></p></body></html>", "Hello world"},
+	}
+
+	for _, tt := range tests {
+		text, err := Extract(tt.format, strings.NewReader(tt.content))
+		require.NoError(t, err, tt.format)
+		require.Equal(t, tt.want, text, tt.format)
+	}
+}
+
+func TestExtract_TruncatedContent(t *testing.T) {
+	text, err := Extract(JSON, strings.NewReader(`{"title": "Budget", "items": ["re`))
+	require.NoError(t, err)
+	require.Equal(t, "title Budget items", text)
+
+	// A multi-byte character cut in half is dropped
+	text, err = Extract(Plain, strings.NewReader("café"[:4]))
+	require.NoError(t, err)
+	require.Equal(t, "caf", text)
+}
+
+func TestTerms(t *testing.T) {
+	require.Equal(t, []string{"quarterly", "report", "q3"}, Terms(`"Quarterly report" -draft Q3 report`))
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("filler ", 30) + "the Reports for <Q3> are final " + strings.Repeat("tail ", 30)
+
+	snippet := Snippet(text, "report q3", 60)
+
+	require.Contains(t, snippet, "<mark>Reports</mark> for &lt;<mark>Q3</mark>&gt;")
+	require.True(t, strings.HasPrefix(snippet, "…"))
+	require.True(t, strings.HasSuffix(snippet, "…"))
+	require.NotContains(t, snippet, "fille…")
+}
+
+func TestSnippet_NoMatch(t *testing.T) {
+	require.Equal(t, "short text", Snippet("short text", "missing", 60))
+	require.Equal(t, "a b…", Snippet("a b cdef", "missing", 5))
+}
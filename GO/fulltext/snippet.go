@@ -0,0 +1,133 @@
+package fulltext
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Terms returns the lowercased words of a query, leaving out the ones it
+// excludes with a leading minus.
+func Terms(query string) []string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, field := range strings.Fields(query) {
+		if strings.HasPrefix(field, "-") {
+			continue
+		}
+		for _, word := range strings.FieldsFunc(strings.ToLower(field), notWordRune) {
+			if !seen[word] {
+				seen[word] = true
+				terms = append(terms, word)
+			}
+		}
+	}
+	return terms
+}
+
+// Snippet returns about width characters of text around the first word
+// matching a term of query, HTML escaped, with every matching word wrapped
+// in <mark>. Words match terms they start with, so "report" highlights
+// "reports". Without a match the snippet is the beginning of text.
+func Snippet(text, query string, width int) string {
+	terms := Terms(query)
+	words := wordSpans(text)
+
+	first := -1
+	for _, w := range words {
+		if matchesTerm(text[w.start:w.end], terms) {
+			first = w.start
+			break
+		}
+	}
+
+	start, end := 0, len(text)
+	if first >= 0 {
+		start = first - width/3
+	}
+	start = max(start, 0)
+	end = min(start+width, len(text))
+	// Don't cut words in half at either end
+	start, end = wordBoundary(words, start, end)
+	for start < end && text[start] == ' ' {
+		start++
+	}
+	for end > start && text[end-1] == ' ' {
+		end--
+	}
+
+	var snippet strings.Builder
+	if start > 0 {
+		snippet.WriteString("…")
+	}
+	pos := start
+	for _, w := range words {
+		if w.start < start || w.end > end {
+			continue
+		}
+		if !matchesTerm(text[w.start:w.end], terms) {
+			continue
+		}
+		snippet.WriteString(html.EscapeString(text[pos:w.start]))
+		snippet.WriteString("<mark>")
+		snippet.WriteString(html.EscapeString(text[w.start:w.end]))
+		snippet.WriteString("</mark>")
+		pos = w.end
+	}
+	snippet.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		snippet.WriteString("…")
+	}
+
+	return snippet.String()
+}
+
+type span struct {
+	start, end int
+}
+
+func wordSpans(text string) []span {
+	var spans []span
+	start := -1
+	for i, r := range text {
+		if notWordRune(r) {
+			if start >= 0 {
+				spans = append(spans, span{start, i})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, span{start, len(text)})
+	}
+	return spans
+}
+
+// wordBoundary moves start forward and end back out of the words they cut.
+func wordBoundary(words []span, start, end int) (int, int) {
+	for _, w := range words {
+		if w.start < start && start < w.end {
+			start = w.end
+		}
+		if w.start < end && end < w.end {
+			end = w.start
+		}
+	}
+	return start, max(start, end)
+}
+
+func matchesTerm(word string, terms []string) bool {
+	word = strings.ToLower(word)
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func notWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -0,0 +1,146 @@
+// Package integrity checks content against the digests clients send with it:
+// Content-MD5 (RFC 1864) and the Content-Digest and Repr-Digest fields of
+// RFC 9530.
+package integrity
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/OgiDac/CompanyTask/domain"
+)
+
+var algorithms = map[domain.DigestAlgorithm]func() hash.Hash{
+	domain.DigestMD5:    md5.New,
+	domain.DigestSHA256: sha256.New,
+	domain.DigestSHA512: sha512.New,
+}
+
+// Header is the part of http.Header and textproto.MIMEHeader digests are
+// read from.
+type Header interface {
+	Get(key string) string
+}
+
+// FromHeader returns the digests of the Content-MD5, Content-Digest and
+// Repr-Digest headers. Uploads have no content coding, so the content is its
+// own representation and both fields describe the same bytes.
+func FromHeader(h Header) ([]domain.ContentDigest, error) {
+	var digests []domain.ContentDigest
+	if value := h.Get("Content-MD5"); value != "" {
+		digest, err := ParseContentMD5(value)
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, digest)
+	}
+	for _, field := range []string{"Content-Digest", "Repr-Digest"} {
+		if value := h.Get(field); value != "" {
+			parsed, err := ParseDigestField(value)
+			if err != nil {
+				return nil, err
+			}
+			digests = append(digests, parsed...)
+		}
+	}
+	return digests, nil
+}
+
+// ParseContentMD5 parses a Content-MD5 header, the base64 MD5 of the content.
+func ParseContentMD5(value string) (domain.ContentDigest, error) {
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(sum) != md5.Size {
+		return domain.ContentDigest{}, domain.ErrInvalidDigest
+	}
+	return domain.ContentDigest{Algorithm: domain.DigestMD5, Sum: sum}, nil
+}
+
+// ParseDigestField parses a Content-Digest or Repr-Digest field, a structured
+// field dictionary of byte sequences like "sha-256=:X48E9q...=:". Algorithms
+// that can't be checked are skipped, but a field has to name at least one
+// that can.
+func ParseDigestField(value string) ([]domain.ContentDigest, error) {
+	var digests []domain.ContentDigest
+	// Base64 has no commas, so members split cleanly
+	for _, member := range strings.Split(value, ",") {
+		member, _, _ = strings.Cut(member, ";")
+		key, item, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok || !strings.HasPrefix(item, ":") || !strings.HasSuffix(item, ":") || len(item) < 2 {
+			return nil, domain.ErrInvalidDigest
+		}
+
+		alg := domain.DigestAlgorithm(strings.ToLower(key))
+		newHash, ok := algorithms[alg]
+		if !ok {
+			continue
+		}
+		sum, err := base64.StdEncoding.DecodeString(item[1 : len(item)-1])
+		if err != nil || len(sum) != newHash().Size() {
+			return nil, domain.ErrInvalidDigest
+		}
+		digests = append(digests, domain.ContentDigest{Algorithm: alg, Sum: sum})
+	}
+
+	if len(digests) == 0 {
+		return nil, domain.ErrInvalidDigest
+	}
+	return digests, nil
+}
+
+// Format returns a digest as a Content-Digest or Repr-Digest field member.
+func Format(alg domain.DigestAlgorithm, sum []byte) string {
+	return string(alg) + "=:" + base64.StdEncoding.EncodeToString(sum) + ":"
+}
+
+// Verifier hashes content as it is read and checks it against the digests
+// sent with it.
+type Verifier struct {
+	expected []domain.ContentDigest
+	hashes   map[domain.DigestAlgorithm]hash.Hash
+}
+
+// NewVerifier returns a Verifier for expected, which may be empty.
+func NewVerifier(expected []domain.ContentDigest) (*Verifier, error) {
+	v := &Verifier{expected: expected, hashes: map[domain.DigestAlgorithm]hash.Hash{}}
+	for _, digest := range expected {
+		newHash, ok := algorithms[digest.Algorithm]
+		if !ok {
+			return nil, domain.ErrInvalidDigest
+		}
+		if _, ok := v.hashes[digest.Algorithm]; !ok {
+			v.hashes[digest.Algorithm] = newHash()
+		}
+		if len(digest.Sum) != v.hashes[digest.Algorithm].Size() {
+			return nil, domain.ErrInvalidDigest
+		}
+	}
+	return v, nil
+}
+
+// Reader returns r with everything read from it hashed.
+func (v *Verifier) Reader(r io.Reader) io.Reader {
+	if len(v.hashes) == 0 {
+		return r
+	}
+	writers := make([]io.Writer, 0, len(v.hashes))
+	for _, h := range v.hashes {
+		writers = append(writers, h)
+	}
+	return io.TeeReader(r, io.MultiWriter(writers...))
+}
+
+// Verify reports whether the content read so far matches every digest.
+func (v *Verifier) Verify() error {
+	for _, digest := range v.expected {
+		if !bytes.Equal(v.hashes[digest.Algorithm].Sum(nil), digest.Sum) {
+			return domain.ErrDigestMismatch
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package integrity
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/stretchr/testify/require"
+)
+
+const content = "hello world"
+
+func TestParseDigestField(t *testing.T) {
+	sha := sha256.Sum256([]byte(content))
+	field := "sha-256=:" + base64.StdEncoding.EncodeToString(sha[:]) + ":, unixsum=:MTIz:"
+
+	digests, err := ParseDigestField(field)
+
+	require.NoError(t, err)
+	require.Equal(t, []domain.ContentDigest{{Algorithm: domain.DigestSHA256, Sum: sha[:]}}, digests)
+}
+
+func TestParseDigestField_Invalid(t *testing.T) {
+	for _, field := range []string{
+		"sha-256=abc",
+		"sha-256=:not base64:",
+		"sha-256=:" + base64.StdEncoding.EncodeToString([]byte("short")) + ":",
+		"unixsum=:MTIz:",
+		"",
+	} {
+		_, err := ParseDigestField(field)
+		require.ErrorIs(t, err, domain.ErrInvalidDigest, field)
+	}
+}
+
+func TestFromHeader(t *testing.T) {
+	md5Sum := md5.Sum([]byte(content))
+	shaSum := sha512.Sum512([]byte(content))
+	h := http.Header{}
+	h.Set("Content-MD5", base64.StdEncoding.EncodeToString(md5Sum[:]))
+	h.Set("Repr-Digest", Format(domain.DigestSHA512, shaSum[:]))
+
+	digests, err := FromHeader(h)
+
+	require.NoError(t, err)
+	require.Equal(t, []domain.ContentDigest{
+		{Algorithm: domain.DigestMD5, Sum: md5Sum[:]},
+		{Algorithm: domain.DigestSHA512, Sum: shaSum[:]},
+	}, digests)
+}
+
+func TestFromHeader_InvalidContentMD5(t *testing.T) {
+	h := http.Header{}
+	h.Set("Content-MD5", "bm90IGFuIG1kNQ==")
+
+	_, err := FromHeader(h)
+
+	require.ErrorIs(t, err, domain.ErrInvalidDigest)
+}
+
+func TestVerifier(t *testing.T) {
+	md5Sum := md5.Sum([]byte(content))
+	shaSum := sha256.Sum256([]byte(content))
+	v, err := NewVerifier([]domain.ContentDigest{
+		{Algorithm: domain.DigestMD5, Sum: md5Sum[:]},
+		{Algorithm: domain.DigestSHA256, Sum: shaSum[:]},
+	})
+	require.NoError(t, err)
+
+	read, err := io.ReadAll(v.Reader(strings.NewReader(content)))
+
+	require.NoError(t, err)
+	require.Equal(t, content, string(read))
+	require.NoError(t, v.Verify())
+}
+
+func TestVerifier_Mismatch(t *testing.T) {
+	shaSum := sha256.Sum256([]byte(content))
+	v, err := NewVerifier([]domain.ContentDigest{{Algorithm: domain.DigestSHA256, Sum: shaSum[:]}})
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(v.Reader(strings.NewReader("hello worle")))
+
+	require.NoError(t, err)
+	require.ErrorIs(t, v.Verify(), domain.ErrDigestMismatch)
+}
+
+func TestVerifier_NoDigests(t *testing.T) {
+	v, err := NewVerifier(nil)
+	require.NoError(t, err)
+
+	r := strings.NewReader(content)
+	require.Same(t, r, v.Reader(r))
+	require.NoError(t, v.Verify())
+}
@@ -0,0 +1,18 @@
+package mocks
+
+import "strings"
+
+// Content is in-memory file content that satisfies io.ReadSeekCloser.
+type Content struct {
+	*strings.Reader
+	Closed bool
+}
+
+func NewContent(data string) *Content {
+	return &Content{Reader: strings.NewReader(data)}
+}
+
+func (c *Content) Close() error {
+	c.Closed = true
+	return nil
+}
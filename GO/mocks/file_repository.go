@@ -2,6 +2,8 @@ package mocks
 
 import (
 	"context"
+	"io"
+	"time"
 
 	"github.com/OgiDac/CompanyTask/domain"
 	"github.com/stretchr/testify/mock"
@@ -11,8 +13,22 @@ type FileRepository struct {
 	mock.Mock
 }
 
-func (m *FileRepository) SaveUserFile(ctx context.Context, file *domain.UserFile) error {
-	args := m.Called(ctx, file)
+func (m *FileRepository) StoreContent(ctx context.Context, content io.Reader, encoding string) (*domain.FileVersion, error) {
+	args := m.Called(ctx, content, encoding)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.FileVersion), args.Error(1)
+}
+
+func (m *FileRepository) ReleaseContent(ctx context.Context, version *domain.FileVersion) error {
+	args := m.Called(ctx, version)
+	return args.Error(0)
+}
+
+func (m *FileRepository) SaveUserFile(ctx context.Context, file *domain.UserFile, version domain.FileVersion) error {
+	args := m.Called(ctx, file, version)
 	return args.Error(0)
 }
 
@@ -25,8 +41,72 @@ func (m *FileRepository) GetFileByID(ctx context.Context, id string) (*domain.Us
 	return result.(*domain.UserFile), args.Error(1)
 }
 
-func (m *FileRepository) DeleteFilesByUserID(ctx context.Context, userID uint) error {
+func (m *FileRepository) GetFileByName(ctx context.Context, userID uint, folderID, filename string) (*domain.UserFile, error) {
+	args := m.Called(ctx, userID, folderID, filename)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.UserFile), args.Error(1)
+}
+
+func (m *FileRepository) GetFileByDigest(ctx context.Context, userID uint, digest string) (*domain.UserFile, error) {
+	args := m.Called(ctx, userID, digest)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.UserFile), args.Error(1)
+}
+
+func (m *FileRepository) OpenFileContent(ctx context.Context, file *domain.UserFile) (io.ReadSeekCloser, error) {
+	args := m.Called(ctx, file)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(io.ReadSeekCloser), args.Error(1)
+}
+
+func (m *FileRepository) TrashFile(ctx context.Context, file *domain.UserFile, deletedAt time.Time) error {
+	args := m.Called(ctx, file, deletedAt)
+	return args.Error(0)
+}
+
+func (m *FileRepository) TrashFilesByUserID(ctx context.Context, userID uint, deletedAt time.Time) error {
+	args := m.Called(ctx, userID, deletedAt)
+	return args.Error(0)
+}
+
+func (m *FileRepository) GetTrashedFile(ctx context.Context, id string) (*domain.UserFile, error) {
+	args := m.Called(ctx, id)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.UserFile), args.Error(1)
+}
+
+func (m *FileRepository) GetTrashedFiles(ctx context.Context, userID uint) ([]*domain.UserFile, error) {
 	args := m.Called(ctx, userID)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.UserFile), args.Error(1)
+}
+
+func (m *FileRepository) GetExpiredTrash(ctx context.Context, before time.Time, limit int) ([]*domain.UserFile, error) {
+	args := m.Called(ctx, before, limit)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.UserFile), args.Error(1)
+}
+
+func (m *FileRepository) RestoreFile(ctx context.Context, file *domain.UserFile, folderID string) error {
+	args := m.Called(ctx, file, folderID)
 	return args.Error(0)
 }
 
@@ -38,3 +118,164 @@ func (m *FileRepository) GetFilesByUserID(ctx context.Context, userID uint) ([]*
 	}
 	return result.([]*domain.UserFile), args.Error(1)
 }
+
+func (m *FileRepository) SearchFiles(ctx context.Context, userID uint, search domain.FileSearch) ([]*domain.UserFile, string, error) {
+	args := m.Called(ctx, userID, search)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return result.([]*domain.UserFile), args.String(1), args.Error(2)
+}
+
+func (m *FileRepository) SetFileExpiry(ctx context.Context, file *domain.UserFile, expiresAt *time.Time) error {
+	args := m.Called(ctx, file, expiresAt)
+	return args.Error(0)
+}
+
+func (m *FileRepository) GetExpiredFiles(ctx context.Context, before time.Time, limit int) ([]*domain.UserFile, error) {
+	args := m.Called(ctx, before, limit)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.UserFile), args.Error(1)
+}
+
+func (m *FileRepository) DeleteExpiredFile(ctx context.Context, file *domain.UserFile, now time.Time) error {
+	args := m.Called(ctx, file, now)
+	return args.Error(0)
+}
+
+func (m *FileRepository) SetFileTags(ctx context.Context, file *domain.UserFile, tags []string) error {
+	args := m.Called(ctx, file, tags)
+	return args.Error(0)
+}
+
+func (m *FileRepository) GetTagCounts(ctx context.Context, userID uint) ([]*domain.TagCount, error) {
+	args := m.Called(ctx, userID)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.TagCount), args.Error(1)
+}
+
+func (m *FileRepository) RenameTags(ctx context.Context, userID uint, from []string, to string) (int, error) {
+	args := m.Called(ctx, userID, from, to)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *FileRepository) SetFileText(ctx context.Context, file *domain.UserFile, text string) error {
+	args := m.Called(ctx, file, text)
+	return args.Error(0)
+}
+
+func (m *FileRepository) GetFileText(ctx context.Context, fileID string) (string, error) {
+	args := m.Called(ctx, fileID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *FileRepository) SearchFileText(ctx context.Context, query string, userIDs []uint, limit int) ([]*domain.FileTextMatch, error) {
+	args := m.Called(ctx, query, userIDs, limit)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.FileTextMatch), args.Error(1)
+}
+
+func (m *FileRepository) RestoreFileVersion(ctx context.Context, file *domain.UserFile, number int) error {
+	args := m.Called(ctx, file, number)
+	return args.Error(0)
+}
+
+func (m *FileRepository) DeleteFileVersions(ctx context.Context, file *domain.UserFile, numbers []int) error {
+	args := m.Called(ctx, file, numbers)
+	return args.Error(0)
+}
+
+func (m *FileRepository) UpdateFile(ctx context.Context, file *domain.UserFile, update domain.FileUpdate) error {
+	args := m.Called(ctx, file, update)
+	return args.Error(0)
+}
+
+func (m *FileRepository) DeleteFile(ctx context.Context, file *domain.UserFile) error {
+	args := m.Called(ctx, file)
+	return args.Error(0)
+}
+
+func (m *FileRepository) GetFilesInFolder(ctx context.Context, userID uint, folderID string) ([]*domain.UserFile, error) {
+	args := m.Called(ctx, userID, folderID)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.UserFile), args.Error(1)
+}
+
+func (m *FileRepository) GetPendingThumbnails(ctx context.Context, limit int) ([]*domain.UserFile, error) {
+	args := m.Called(ctx, limit)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.UserFile), args.Error(1)
+}
+
+func (m *FileRepository) StoreThumbnail(ctx context.Context, file *domain.UserFile, thumbnail *domain.Thumbnail, content io.Reader) error {
+	args := m.Called(ctx, file, thumbnail, content)
+	return args.Error(0)
+}
+
+func (m *FileRepository) SetThumbnails(ctx context.Context, file *domain.UserFile, status domain.ThumbnailStatus, thumbnails []domain.Thumbnail) error {
+	args := m.Called(ctx, file, status, thumbnails)
+	return args.Error(0)
+}
+
+func (m *FileRepository) OpenThumbnail(ctx context.Context, thumbnail domain.Thumbnail) (io.ReadSeekCloser, error) {
+	args := m.Called(ctx, thumbnail)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(io.ReadSeekCloser), args.Error(1)
+}
+
+func (m *FileRepository) DeleteThumbnails(ctx context.Context, thumbnails []domain.Thumbnail) error {
+	args := m.Called(ctx, thumbnails)
+	return args.Error(0)
+}
+
+func (m *FileRepository) GetPendingScans(ctx context.Context, limit int) ([]*domain.UserFile, error) {
+	args := m.Called(ctx, limit)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.UserFile), args.Error(1)
+}
+
+func (m *FileRepository) SetScanResult(ctx context.Context, file *domain.UserFile, number int, result domain.ScanResult) error {
+	args := m.Called(ctx, file, number, result)
+	return args.Error(0)
+}
+
+func (m *FileRepository) QuarantineVersion(ctx context.Context, entry *domain.QuarantineEntry) error {
+	args := m.Called(ctx, entry)
+	return args.Error(0)
+}
+
+func (m *FileRepository) GetUnverifiedContent(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	args := m.Called(ctx, before, limit)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]string), args.Error(1)
+}
+
+func (m *FileRepository) VerifyContent(ctx context.Context, digest string) error {
+	args := m.Called(ctx, digest)
+	return args.Error(0)
+}
@@ -0,0 +1,264 @@
+package mocks
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type FileUseCase struct {
+	mock.Mock
+}
+
+func (m *FileUseCase) UploadFile(ctx context.Context, callerID, userID uint, folderID, filename, contentType string, tags []string, expiresAt *time.Time, digests []domain.ContentDigest, content io.Reader) (*domain.UserFileMeta, error) {
+	args := m.Called(ctx, callerID, userID, folderID, filename, contentType, tags, expiresAt, digests, content)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.UserFileMeta), args.Error(1)
+}
+
+func (m *FileUseCase) CheckUpload(ctx context.Context, callerID, userID uint, folderID, filename string, size int64) error {
+	args := m.Called(ctx, callerID, userID, folderID, filename, size)
+	return args.Error(0)
+}
+
+func (m *FileUseCase) HasContent(ctx context.Context, callerID, userID uint, digest string) (bool, error) {
+	args := m.Called(ctx, callerID, userID, digest)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *FileUseCase) UploadKnownContent(ctx context.Context, callerID, userID uint, folderID, filename string, tags []string, expiresAt *time.Time, digest string) (*domain.UserFileMeta, error) {
+	args := m.Called(ctx, callerID, userID, folderID, filename, tags, expiresAt, digest)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.UserFileMeta), args.Error(1)
+}
+
+func (m *FileUseCase) GetFileByID(ctx context.Context, callerID uint, id string) (*domain.UserFile, error) {
+	args := m.Called(ctx, callerID, id)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.UserFile), args.Error(1)
+}
+
+func (m *FileUseCase) DownloadFile(ctx context.Context, callerID uint, id string) (*domain.UserFile, io.ReadSeekCloser, error) {
+	args := m.Called(ctx, callerID, id)
+	file, content := args.Get(0), args.Get(1)
+	if file == nil {
+		return nil, nil, args.Error(2)
+	}
+	return file.(*domain.UserFile), content.(io.ReadSeekCloser), args.Error(2)
+}
+
+func (m *FileUseCase) GetFilesByUserID(ctx context.Context, callerID, userID uint, tags domain.TagFilter) ([]*domain.UserFileMeta, error) {
+	args := m.Called(ctx, callerID, userID, tags)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.UserFileMeta), args.Error(1)
+}
+
+func (m *FileUseCase) SearchFiles(ctx context.Context, callerID, userID uint, search domain.FileSearch) (*domain.FileSearchResult, error) {
+	args := m.Called(ctx, callerID, userID, search)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.FileSearchResult), args.Error(1)
+}
+
+func (m *FileUseCase) SetFileExpiry(ctx context.Context, callerID uint, id string, expiresAt *time.Time) (*domain.UserFileMeta, error) {
+	args := m.Called(ctx, callerID, id, expiresAt)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.UserFileMeta), args.Error(1)
+}
+
+func (m *FileUseCase) ExpireFiles(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *FileUseCase) ScrubContent(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *FileUseCase) SetFileTags(ctx context.Context, callerID uint, id string, tags []string) (*domain.UserFileMeta, error) {
+	args := m.Called(ctx, callerID, id, tags)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.UserFileMeta), args.Error(1)
+}
+
+func (m *FileUseCase) GetTags(ctx context.Context, callerID, userID uint) ([]*domain.TagCount, error) {
+	args := m.Called(ctx, callerID, userID)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.TagCount), args.Error(1)
+}
+
+func (m *FileUseCase) RenameTags(ctx context.Context, callerID, userID uint, rename domain.TagRename) (int, error) {
+	args := m.Called(ctx, callerID, userID, rename)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *FileUseCase) SearchContent(ctx context.Context, callerID uint, query string, limit int) ([]*domain.ContentSearchHit, error) {
+	args := m.Called(ctx, callerID, query, limit)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.ContentSearchHit), args.Error(1)
+}
+
+func (m *FileUseCase) DeleteFilesByUserID(ctx context.Context, callerID, userID uint) error {
+	args := m.Called(ctx, callerID, userID)
+	return args.Error(0)
+}
+
+func (m *FileUseCase) GetTrash(ctx context.Context, callerID, userID uint) ([]*domain.UserFileMeta, error) {
+	args := m.Called(ctx, callerID, userID)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.UserFileMeta), args.Error(1)
+}
+
+func (m *FileUseCase) RestoreFile(ctx context.Context, callerID uint, id string) (*domain.UserFileMeta, error) {
+	args := m.Called(ctx, callerID, id)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.UserFileMeta), args.Error(1)
+}
+
+func (m *FileUseCase) EmptyTrash(ctx context.Context, callerID, userID uint) (int, error) {
+	args := m.Called(ctx, callerID, userID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *FileUseCase) PurgeTrash(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *FileUseCase) GetFileVersions(ctx context.Context, callerID uint, id string) ([]domain.FileVersion, error) {
+	args := m.Called(ctx, callerID, id)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]domain.FileVersion), args.Error(1)
+}
+
+func (m *FileUseCase) DownloadFileVersion(ctx context.Context, callerID uint, id string, version int) (*domain.UserFile, io.ReadSeekCloser, error) {
+	args := m.Called(ctx, callerID, id, version)
+	file, content := args.Get(0), args.Get(1)
+	if file == nil {
+		return nil, nil, args.Error(2)
+	}
+	return file.(*domain.UserFile), content.(io.ReadSeekCloser), args.Error(2)
+}
+
+func (m *FileUseCase) RestoreFileVersion(ctx context.Context, callerID uint, id string, version int) (*domain.UserFileMeta, error) {
+	args := m.Called(ctx, callerID, id, version)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.UserFileMeta), args.Error(1)
+}
+
+func (m *FileUseCase) PruneFileVersions(ctx context.Context, callerID, userID uint, retention domain.VersionRetention) (int, error) {
+	args := m.Called(ctx, callerID, userID, retention)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *FileUseCase) GetStorageUsage(ctx context.Context, callerID, userID uint) (*domain.StorageUsageResponse, error) {
+	args := m.Called(ctx, callerID, userID)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.StorageUsageResponse), args.Error(1)
+}
+
+func (m *FileUseCase) SetStorageQuota(ctx context.Context, callerID, userID uint, quota *domain.StorageQuota) error {
+	args := m.Called(ctx, callerID, userID, quota)
+	return args.Error(0)
+}
+
+func (m *FileUseCase) UpdateFile(ctx context.Context, callerID uint, id string, update domain.FileUpdate) (*domain.UserFile, error) {
+	args := m.Called(ctx, callerID, id, update)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.UserFile), args.Error(1)
+}
+
+func (m *FileUseCase) DeleteFile(ctx context.Context, callerID uint, id string) error {
+	args := m.Called(ctx, callerID, id)
+	return args.Error(0)
+}
+
+func (m *FileUseCase) ResolvePath(ctx context.Context, callerID, userID uint, path string) (*domain.UserFile, error) {
+	args := m.Called(ctx, callerID, userID, path)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.UserFile), args.Error(1)
+}
+
+func (m *FileUseCase) GetThumbnail(ctx context.Context, callerID uint, id string, size domain.ThumbnailSize) (*domain.Thumbnail, io.ReadSeekCloser, error) {
+	args := m.Called(ctx, callerID, id, size)
+	thumbnail, content := args.Get(0), args.Get(1)
+	if thumbnail == nil {
+		return nil, nil, args.Error(2)
+	}
+	return thumbnail.(*domain.Thumbnail), content.(io.ReadSeekCloser), args.Error(2)
+}
+
+func (m *FileUseCase) GenerateThumbnails(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *FileUseCase) ScanPendingFiles(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *FileUseCase) GetArchive(ctx context.Context, callerID, userID uint, filter domain.ArchiveFilter) (*domain.Archive, error) {
+	args := m.Called(ctx, callerID, userID, filter)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.Archive), args.Error(1)
+}
+
+func (m *FileUseCase) WriteArchive(ctx context.Context, archive *domain.Archive, w io.Writer) error {
+	args := m.Called(ctx, archive, w)
+	return args.Error(0)
+}
@@ -0,0 +1,63 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type FolderRepository struct {
+	mock.Mock
+}
+
+func (m *FolderRepository) CreateFolder(ctx context.Context, folder *domain.Folder) error {
+	args := m.Called(ctx, folder)
+	return args.Error(0)
+}
+
+func (m *FolderRepository) GetFolderByID(ctx context.Context, id string) (*domain.Folder, error) {
+	args := m.Called(ctx, id)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.Folder), args.Error(1)
+}
+
+func (m *FolderRepository) GetFolderByName(ctx context.Context, userID uint, parentID, name string) (*domain.Folder, error) {
+	args := m.Called(ctx, userID, parentID, name)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.Folder), args.Error(1)
+}
+
+func (m *FolderRepository) GetFoldersByParent(ctx context.Context, userID uint, parentID string) ([]*domain.Folder, error) {
+	args := m.Called(ctx, userID, parentID)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.Folder), args.Error(1)
+}
+
+func (m *FolderRepository) GetFoldersByUserID(ctx context.Context, userID uint) ([]*domain.Folder, error) {
+	args := m.Called(ctx, userID)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.Folder), args.Error(1)
+}
+
+func (m *FolderRepository) UpdateFolder(ctx context.Context, folder *domain.Folder) error {
+	args := m.Called(ctx, folder)
+	return args.Error(0)
+}
+
+func (m *FolderRepository) DeleteFolders(ctx context.Context, ids []string) error {
+	args := m.Called(ctx, ids)
+	return args.Error(0)
+}
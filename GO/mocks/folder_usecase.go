@@ -0,0 +1,62 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type FolderUseCase struct {
+	mock.Mock
+}
+
+func (m *FolderUseCase) CreateFolder(ctx context.Context, callerID, userID uint, request domain.CreateFolderRequest) (*domain.Folder, error) {
+	args := m.Called(ctx, callerID, userID, request)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.Folder), args.Error(1)
+}
+
+func (m *FolderUseCase) GetFolderContents(ctx context.Context, callerID uint, id string) (*domain.FolderContents, error) {
+	args := m.Called(ctx, callerID, id)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.FolderContents), args.Error(1)
+}
+
+func (m *FolderUseCase) GetRootContents(ctx context.Context, callerID, userID uint) (*domain.FolderContents, error) {
+	args := m.Called(ctx, callerID, userID)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.FolderContents), args.Error(1)
+}
+
+func (m *FolderUseCase) ResolveFolder(ctx context.Context, callerID, userID uint, path string) (*domain.Folder, error) {
+	args := m.Called(ctx, callerID, userID, path)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.Folder), args.Error(1)
+}
+
+func (m *FolderUseCase) UpdateFolder(ctx context.Context, callerID uint, id string, update domain.FolderUpdate) (*domain.Folder, error) {
+	args := m.Called(ctx, callerID, id, update)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.Folder), args.Error(1)
+}
+
+func (m *FolderUseCase) DeleteFolder(ctx context.Context, callerID uint, id string) error {
+	args := m.Called(ctx, callerID, id)
+	return args.Error(0)
+}
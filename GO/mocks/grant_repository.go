@@ -0,0 +1,54 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type GrantRepository struct {
+	mock.Mock
+}
+
+func (m *GrantRepository) SetGrant(ctx context.Context, grant *domain.Grant) error {
+	args := m.Called(ctx, grant)
+	return args.Error(0)
+}
+
+func (m *GrantRepository) GetGrants(ctx context.Context, resource domain.Resource) ([]*domain.Grant, error) {
+	args := m.Called(ctx, resource)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.Grant), args.Error(1)
+}
+
+func (m *GrantRepository) GetUserGrants(ctx context.Context, userID uint, resources []domain.Resource) ([]*domain.Grant, error) {
+	args := m.Called(ctx, userID, resources)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.Grant), args.Error(1)
+}
+
+func (m *GrantRepository) GetGrantsByUserID(ctx context.Context, userID uint) ([]*domain.Grant, error) {
+	args := m.Called(ctx, userID)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.Grant), args.Error(1)
+}
+
+func (m *GrantRepository) DeleteGrant(ctx context.Context, resource domain.Resource, userID uint) error {
+	args := m.Called(ctx, resource, userID)
+	return args.Error(0)
+}
+
+func (m *GrantRepository) DeleteResourceGrants(ctx context.Context, resources []domain.Resource) error {
+	args := m.Called(ctx, resources)
+	return args.Error(0)
+}
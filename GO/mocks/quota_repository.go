@@ -0,0 +1,36 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type QuotaRepository struct {
+	mock.Mock
+}
+
+func (m *QuotaRepository) GetUsage(ctx context.Context, userID uint) (*domain.StorageUsage, error) {
+	args := m.Called(ctx, userID)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.StorageUsage), args.Error(1)
+}
+
+func (m *QuotaRepository) ReserveUsage(ctx context.Context, userID uint, bytes int64, files int, quota domain.StorageQuota) error {
+	args := m.Called(ctx, userID, bytes, files, quota)
+	return args.Error(0)
+}
+
+func (m *QuotaRepository) AddUsage(ctx context.Context, userID uint, bytes int64, files int) error {
+	args := m.Called(ctx, userID, bytes, files)
+	return args.Error(0)
+}
+
+func (m *QuotaRepository) SetQuota(ctx context.Context, userID uint, quota *domain.StorageQuota) error {
+	args := m.Called(ctx, userID, quota)
+	return args.Error(0)
+}
@@ -0,0 +1,25 @@
+package mocks
+
+import (
+	"context"
+	"io"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type Scanner struct {
+	mock.Mock
+}
+
+// Scan reads all of content like a real scanner before returning the
+// configured result.
+func (m *Scanner) Scan(ctx context.Context, content io.Reader) (*domain.ScanResult, error) {
+	_, _ = io.Copy(io.Discard, content)
+	args := m.Called(ctx, content)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.ScanResult), args.Error(1)
+}
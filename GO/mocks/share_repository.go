@@ -0,0 +1,63 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type ShareRepository struct {
+	mock.Mock
+}
+
+func (m *ShareRepository) CreateShare(ctx context.Context, link *domain.ShareLink) error {
+	args := m.Called(ctx, link)
+	return args.Error(0)
+}
+
+func (m *ShareRepository) GetShareByID(ctx context.Context, id string) (*domain.ShareLink, error) {
+	args := m.Called(ctx, id)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.ShareLink), args.Error(1)
+}
+
+func (m *ShareRepository) GetShareByTokenHash(ctx context.Context, tokenHash string) (*domain.ShareLink, error) {
+	args := m.Called(ctx, tokenHash)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.ShareLink), args.Error(1)
+}
+
+func (m *ShareRepository) GetSharesByFileID(ctx context.Context, fileID string) ([]*domain.ShareLink, error) {
+	args := m.Called(ctx, fileID)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.ShareLink), args.Error(1)
+}
+
+func (m *ShareRepository) GetSharesByUserID(ctx context.Context, userID uint) ([]*domain.ShareLink, error) {
+	args := m.Called(ctx, userID)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.ShareLink), args.Error(1)
+}
+
+func (m *ShareRepository) CountDownload(ctx context.Context, link *domain.ShareLink) error {
+	args := m.Called(ctx, link)
+	return args.Error(0)
+}
+
+func (m *ShareRepository) DeleteShare(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
@@ -0,0 +1,61 @@
+package mocks
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/stretchr/testify/mock"
+)
+
+type UploadRepository struct {
+	mock.Mock
+}
+
+func (m *UploadRepository) CreateUpload(ctx context.Context, upload *domain.FileUpload) error {
+	args := m.Called(ctx, upload)
+	return args.Error(0)
+}
+
+func (m *UploadRepository) GetUploadByID(ctx context.Context, id string) (*domain.FileUpload, error) {
+	args := m.Called(ctx, id)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(*domain.FileUpload), args.Error(1)
+}
+
+func (m *UploadRepository) AppendChunk(ctx context.Context, upload *domain.FileUpload, chunk io.Reader, expiresAt time.Time) (int64, error) {
+	args := m.Called(ctx, upload, chunk, expiresAt)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *UploadRepository) OpenUploadContent(ctx context.Context, upload *domain.FileUpload) (io.ReadCloser, error) {
+	args := m.Called(ctx, upload)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.(io.ReadCloser), args.Error(1)
+}
+
+func (m *UploadRepository) CompleteUpload(ctx context.Context, upload *domain.FileUpload, fileID string) error {
+	args := m.Called(ctx, upload, fileID)
+	return args.Error(0)
+}
+
+func (m *UploadRepository) DeleteUpload(ctx context.Context, upload *domain.FileUpload) error {
+	args := m.Called(ctx, upload)
+	return args.Error(0)
+}
+
+func (m *UploadRepository) GetExpiredUploads(ctx context.Context, now time.Time) ([]*domain.FileUpload, error) {
+	args := m.Called(ctx, now)
+	result := args.Get(0)
+	if result == nil {
+		return nil, args.Error(1)
+	}
+	return result.([]*domain.FileUpload), args.Error(1)
+}
@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/blobstore"
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/encryption"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// blobRef names a stored blob. Blobs recorded without a store were written
+// before storage backends existed and are in GridFS.
+type blobRef struct {
+	store  string
+	bucket string
+	id     string
+}
+
+// blobKey is the data key a blob is encrypted with, wrapped by the master
+// key with the given ID. The wrapped key is bound to the blob ID, so it
+// can't be copied onto another blob. Blobs without a key were stored before
+// encryption was enabled and are plaintext.
+type blobKey struct {
+	BlobID      string    `bson:"_id"`
+	MasterKeyID string    `bson:"masterKeyId"`
+	DataKey     []byte    `bson:"dataKey"`
+	CreatedAt   time.Time `bson:"createdAt"`
+}
+
+// BlobStorage writes new blobs to the current backend and reads existing
+// ones from the backend they were written to. Blob contents are encrypted
+// when a keyring is configured; their data keys are kept in blob_keys, apart
+// from the bytes.
+type BlobStorage struct {
+	current domain.BlobStore
+	stores  map[string]domain.BlobStore
+	keys    *encryption.Keyring
+	keyring *mongo.Collection
+}
+
+// NewBlobStorage stores new blobs in current, encrypted with keys or in
+// plaintext when keys is nil. stores are every backend blobs can be read from.
+func NewBlobStorage(db *mongo.Database, current domain.BlobStore, stores []domain.BlobStore, keys *encryption.Keyring) *BlobStorage {
+	s := &BlobStorage{
+		current: current,
+		stores:  map[string]domain.BlobStore{},
+		keys:    keys,
+		keyring: db.Collection("blob_keys"),
+	}
+	for _, store := range append(stores, current) {
+		s.stores[store.Name()] = store
+	}
+	return s
+}
+
+func (s *BlobStorage) store(name string) (domain.BlobStore, error) {
+	if name == "" {
+		name = blobstore.GridFS
+	}
+	store, ok := s.stores[name]
+	if !ok {
+		return nil, fmt.Errorf("storage backend %q is not configured", name)
+	}
+	return store, nil
+}
+
+// blobWriter streams a new blob, encrypting it with a fresh data key when a
+// keyring is configured.
+type blobWriter struct {
+	ref       blobRef
+	blob      domain.BlobWriter
+	encrypter *encryption.Writer
+	storage   *BlobStorage
+}
+
+// create starts a new blob in bucket of the current backend. Its data key is
+// recorded first, so no encrypted blob is ever stored without one.
+func (s *BlobStorage) create(ctx context.Context, bucket string) (*blobWriter, error) {
+	ref := blobRef{store: s.current.Name(), bucket: bucket, id: primitive.NewObjectID().Hex()}
+
+	var dataKey []byte
+	if s.keys != nil {
+		var err error
+		if dataKey, err = encryption.NewDataKey(); err != nil {
+			return nil, err
+		}
+		keyID, wrapped, err := s.keys.Wrap(dataKey, []byte(ref.id))
+		if err != nil {
+			return nil, err
+		}
+		key := blobKey{BlobID: ref.id, MasterKeyID: keyID, DataKey: wrapped, CreatedAt: time.Now().UTC()}
+		if _, err := s.keyring.InsertOne(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+
+	blob, err := s.current.Create(ctx, bucket, ref.id)
+	if err != nil {
+		s.deleteKey(ref)
+		return nil, err
+	}
+
+	w := &blobWriter{ref: ref, blob: blob, storage: s}
+	if dataKey != nil {
+		if w.encrypter, err = encryption.NewWriter(blob, dataKey); err != nil {
+			_ = w.Abort()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+func (w *blobWriter) Write(p []byte) (int, error) {
+	if w.encrypter != nil {
+		return w.encrypter.Write(p)
+	}
+	return w.blob.Write(p)
+}
+
+// Close seals the content and stores the blob.
+func (w *blobWriter) Close() error {
+	if w.encrypter != nil {
+		if err := w.encrypter.Close(); err != nil {
+			_ = w.Abort()
+			return err
+		}
+	}
+	if err := w.blob.Close(); err != nil {
+		w.storage.deleteKey(w.ref)
+		return err
+	}
+	return nil
+}
+
+// Abort discards everything written so far.
+func (w *blobWriter) Abort() error {
+	err := w.blob.Abort()
+	w.storage.deleteKey(w.ref)
+	return err
+}
+
+// open reads a blob, decrypting it when it was stored encrypted.
+func (s *BlobStorage) open(ctx context.Context, ref blobRef) (io.ReadCloser, error) {
+	store, err := s.store(ref.store)
+	if err != nil {
+		return nil, err
+	}
+
+	var key blobKey
+	err = s.keyring.FindOne(ctx, bson.M{"_id": ref.id}).Decode(&key)
+	encrypted := err == nil
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	stream, err := store.Open(ctx, ref.bucket, ref.id)
+	if err != nil {
+		return nil, err
+	}
+	if !encrypted {
+		return stream, nil
+	}
+
+	if s.keys == nil {
+		_ = stream.Close()
+		return nil, encryption.ErrUnknownMasterKey
+	}
+	dataKey, err := s.keys.Unwrap(key.MasterKeyID, key.DataKey, []byte(ref.id))
+	if err != nil {
+		_ = stream.Close()
+		return nil, err
+	}
+	reader, err := encryption.NewReader(stream, dataKey)
+	if err != nil {
+		_ = stream.Close()
+		return nil, err
+	}
+
+	return &decryptedStream{Reader: reader, stream: stream}, nil
+}
+
+// delete removes a blob along with its data key. Deleting a blob that
+// doesn't exist succeeds.
+func (s *BlobStorage) delete(ctx context.Context, ref blobRef) error {
+	store, err := s.store(ref.store)
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(ctx, ref.bucket, ref.id); err != nil {
+		return err
+	}
+	_, err = s.keyring.DeleteOne(ctx, bson.M{"_id": ref.id})
+	return err
+}
+
+// encrypted reports whether a blob is stored encrypted.
+func (s *BlobStorage) encrypted(ctx context.Context, ref blobRef) (bool, error) {
+	err := s.keyring.FindOne(ctx, bson.M{"_id": ref.id}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// deleteKey drops the data key of a blob that was never stored. It is best
+// effort, as a leftover key only takes space.
+func (s *BlobStorage) deleteKey(ref blobRef) {
+	if s.keys != nil {
+		_, _ = s.keyring.DeleteOne(context.Background(), bson.M{"_id": ref.id})
+	}
+}
+
+// decryptedStream reads the plaintext of an encrypted blob.
+type decryptedStream struct {
+	*encryption.Reader
+	stream io.Closer
+}
+
+func (d *decryptedStream) Close() error {
+	return d.stream.Close()
+}
+
+// upload streams content into a new blob in bucket and returns it with the
+// plaintext size.
+func (s *BlobStorage) upload(ctx context.Context, bucket string, content io.Reader) (blobRef, int64, error) {
+	blob, err := s.create(ctx, bucket)
+	if err != nil {
+		return blobRef{}, 0, err
+	}
+
+	size, err := io.Copy(blob, content)
+	if err != nil {
+		_ = blob.Abort()
+		return blobRef{}, 0, err
+	}
+
+	if err := blob.Close(); err != nil {
+		return blobRef{}, 0, err
+	}
+
+	return blob.ref, size, nil
+}
+
+// openBlob opens a blob with the given plaintext size as a seekable stream.
+func (s *BlobStorage) openBlob(ctx context.Context, ref blobRef, size int64) (io.ReadSeekCloser, error) {
+	return openSeekable(size, func() (io.ReadCloser, error) {
+		return s.open(ctx, ref)
+	})
+}
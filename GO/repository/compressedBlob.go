@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"io"
+
+	"github.com/OgiDac/CompanyTask/compression"
+	"github.com/OgiDac/CompanyTask/domain"
+)
+
+// compressedBlob reads the content of a compressed file decompressed. Its
+// stored bytes can be read as they are through Encoded.
+type compressedBlob struct {
+	*seekableStream
+	storedSize int64
+	stored     func() (io.ReadCloser, error)
+}
+
+// openCompressedBlob opens the content of a file stored compressed with
+// file.Encoding. Seeking decompresses and discards everything before the
+// new offset.
+func openCompressedBlob(ctx context.Context, storage *BlobStorage, file *domain.UserFile) (io.ReadSeekCloser, error) {
+	ref := blobRef{store: file.Store, bucket: fileBucketName, id: file.BlobID}
+	stored := func() (io.ReadCloser, error) {
+		return storage.open(ctx, ref)
+	}
+	content, err := openSeekable(file.Size, func() (io.ReadCloser, error) {
+		stream, err := stored()
+		if err != nil {
+			return nil, err
+		}
+		decompressor, err := compression.NewReader(stream, file.Encoding)
+		if err != nil {
+			_ = stream.Close()
+			return nil, err
+		}
+		return &decompressedStream{ReadCloser: decompressor, stream: stream}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &compressedBlob{seekableStream: content, storedSize: file.StoredSize, stored: stored}, nil
+}
+
+func (b *compressedBlob) Encoded() (io.ReadSeekCloser, error) {
+	return openSeekable(b.storedSize, b.stored)
+}
+
+// decompressedStream closes the decompressor along with the blob it reads.
+type decompressedStream struct {
+	io.ReadCloser
+	stream io.Closer
+}
+
+func (d *decompressedStream) Close() error {
+	_ = d.ReadCloser.Close()
+	return d.stream.Close()
+}
@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/compression"
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/encryption"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// blobIndexes back the integrity scrubber, which visits the content verified
+// longest ago first.
+func blobIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{{
+		Keys: bson.D{{Key: "verifiedAt", Value: 1}},
+	}}
+}
+
+// Unverified returns the digests of content that isn't known to be corrupt
+// and wasn't verified since before, oldest verification first.
+func (s *contentStore) Unverified(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	filter := bson.M{
+		"corruptAt": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"verifiedAt": bson.M{"$exists": false}},
+			bson.M{"verifiedAt": bson.M{"$lt": before}},
+		},
+	}
+	opts := options.Find().
+		SetProjection(bson.M{"_id": 1}).
+		SetSort(bson.D{{Key: "verifiedAt", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cursor, err := s.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var blobs []storedBlob
+	if err := cursor.All(ctx, &blobs); err != nil {
+		return nil, err
+	}
+
+	digests := make([]string, 0, len(blobs))
+	for _, blob := range blobs {
+		digests = append(digests, blob.Digest)
+	}
+	return digests, nil
+}
+
+// Verify reads the content stored under digest back and checks it still
+// hashes to it. Damaged content is marked corrupt along with every file
+// version using it, and domain.ErrContentCorrupt returned. Content that was
+// deleted meanwhile is skipped.
+func (s *contentStore) Verify(ctx context.Context, digest string) error {
+	var blob storedBlob
+	err := s.collection.FindOne(ctx, bson.M{"_id": digest}).Decode(&blob)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	intact, err := s.check(ctx, &blob)
+	if err != nil {
+		return err
+	}
+	if !intact {
+		if err := s.markCorrupt(ctx, &blob); err != nil {
+			return err
+		}
+		return domain.ErrContentCorrupt
+	}
+
+	// A repair may have swapped the blob meanwhile; it sets its own time
+	_, err = s.collection.UpdateOne(ctx,
+		bson.M{"_id": blob.Digest, "blobId": blob.BlobID},
+		bson.M{"$set": bson.M{"verifiedAt": time.Now().UTC()}},
+	)
+	return err
+}
+
+// check hashes the stored content of blob. Errors reading it are returned
+// unless they show the content itself is damaged, so an unreachable store
+// doesn't get content marked corrupt.
+func (s *contentStore) check(ctx context.Context, blob *storedBlob) (bool, error) {
+	stream, err := s.storage.open(ctx, blob.ref())
+	if errors.Is(err, domain.ErrBlobNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer stream.Close()
+
+	raw := &readRecorder{r: stream}
+	var content io.Reader = raw
+	if blob.Encoding != "" {
+		decompressed, err := compression.NewReader(raw, blob.Encoding)
+		if err != nil {
+			return false, s.readFailure(raw)
+		}
+		defer decompressed.Close()
+		content = decompressed
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, content)
+	if err != nil {
+		return false, s.readFailure(raw)
+	}
+
+	return size == blob.Size && hex.EncodeToString(hash.Sum(nil)) == blob.Digest, nil
+}
+
+// readFailure tells apart content that can't be read right now, which is
+// returned as an error, from content that is damaged.
+func (s *contentStore) readFailure(raw *readRecorder) error {
+	if raw.err != nil && !errors.Is(raw.err, encryption.ErrCorrupted) {
+		return raw.err
+	}
+	return nil
+}
+
+// markCorrupt flags blob and the files using it as corrupt.
+func (s *contentStore) markCorrupt(ctx context.Context, blob *storedBlob) error {
+	now := time.Now().UTC()
+	result, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": blob.Digest, "blobId": blob.BlobID},
+		bson.M{"$set": bson.M{"corruptAt": now, "verifiedAt": now}},
+	)
+	if err != nil {
+		return err
+	}
+	// Replaced by a repair while it was being read
+	if result.MatchedCount == 0 {
+		return nil
+	}
+
+	_, err = s.files.UpdateMany(ctx,
+		bson.M{"versions.digest": blob.Digest},
+		bson.M{"$set": bson.M{"versions.$[v].corrupt": true}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"v.digest": blob.Digest}}}),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = s.files.UpdateMany(ctx,
+		bson.M{"digest": blob.Digest},
+		bson.M{"$set": bson.M{"corrupt": true}},
+	)
+	return err
+}
+
+// repair makes fresh, newly written content matching the digest of corrupt
+// blob take its place, in the blob and in every file version using it. The
+// damaged bytes are deleted. When another upload repaired it first, the
+// current blob is returned and fresh is left to the caller.
+func (s *contentStore) repair(ctx context.Context, corrupt, fresh *storedBlob) (*storedBlob, error) {
+	now := time.Now().UTC()
+	set := bson.M{"blobId": fresh.BlobID, "verifiedAt": now}
+	unset := bson.M{"corruptAt": ""}
+	content := bson.M{"blobId": fresh.BlobID.Hex()}
+	uncontent := bson.M{}
+	if fresh.Store != "" {
+		set["store"] = fresh.Store
+		content["store"] = fresh.Store
+	} else {
+		unset["store"] = ""
+		uncontent["store"] = ""
+	}
+	if fresh.Encoding != "" {
+		set["encoding"] = fresh.Encoding
+		set["storedSize"] = fresh.StoredSize
+		content["encoding"] = fresh.Encoding
+		content["storedSize"] = fresh.StoredSize
+	} else {
+		unset["encoding"] = ""
+		unset["storedSize"] = ""
+		uncontent["encoding"] = ""
+		uncontent["storedSize"] = ""
+	}
+
+	var repaired storedBlob
+	err := s.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": corrupt.Digest, "blobId": corrupt.BlobID, "corruptAt": bson.M{"$exists": true}},
+		bson.M{"$set": set, "$unset": unset},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&repaired)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		var current storedBlob
+		if err := s.collection.FindOne(ctx, bson.M{"_id": corrupt.Digest}).Decode(&current); err != nil {
+			return nil, err
+		}
+		return &current, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	versionSet := bson.M{}
+	versionUnset := bson.M{"versions.$[v].corrupt": ""}
+	for field, value := range content {
+		versionSet["versions.$[v]."+field] = value
+	}
+	for field := range uncontent {
+		versionUnset["versions.$[v]."+field] = ""
+	}
+	_, err = s.files.UpdateMany(ctx,
+		bson.M{"versions.digest": corrupt.Digest},
+		bson.M{"$set": versionSet, "$unset": versionUnset},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"v.digest": corrupt.Digest}}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	uncontent["corrupt"] = ""
+	_, err = s.files.UpdateMany(ctx,
+		bson.M{"digest": corrupt.Digest},
+		bson.M{"$set": content, "$unset": uncontent},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = s.storage.delete(context.Background(), corrupt.ref())
+	return &repaired, nil
+}
+
+// readRecorder keeps the error that ended reading from r, other than EOF.
+type readRecorder struct {
+	r   io.Reader
+	err error
+}
+
+func (r *readRecorder) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/compression"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// storedBlob is the content of a file stored once under its SHA-256 digest.
+// RefCount counts the file versions that point at it. Compressed content
+// records its codec and compressed size; Size is the original size.
+// VerifiedAt is when the content was last hashed and CorruptAt when it was
+// found not to match its digest.
+type storedBlob struct {
+	Digest     string             `bson:"_id"`
+	BlobID     primitive.ObjectID `bson:"blobId"`
+	Store      string             `bson:"store,omitempty"`
+	Size       int64              `bson:"size"`
+	Encoding   string             `bson:"encoding,omitempty"`
+	StoredSize int64              `bson:"storedSize,omitempty"`
+	RefCount   int                `bson:"refCount"`
+	CreatedAt  time.Time          `bson:"createdAt"`
+	VerifiedAt *time.Time         `bson:"verifiedAt,omitempty"`
+	CorruptAt  *time.Time         `bson:"corruptAt,omitempty"`
+}
+
+func (b *storedBlob) ref() blobRef {
+	return blobRef{store: b.Store, bucket: fileBucketName, id: b.BlobID.Hex()}
+}
+
+// contentStore keeps file content in blob storage deduplicated by SHA-256
+// digest. Files are updated along with the content they point at when it is
+// found corrupt or repaired.
+type contentStore struct {
+	collection *mongo.Collection
+	files      *mongo.Collection
+	storage    *BlobStorage
+}
+
+func newContentStore(db *mongo.Database, storage *BlobStorage) *contentStore {
+	return &contentStore{
+		collection: db.Collection("file_blobs"),
+		files:      db.Collection("user_files"),
+		storage:    storage,
+	}
+}
+
+// Put streams content into blob storage while hashing its plaintext and takes one
+// reference on the resulting digest. Content is compressed with encoding
+// unless it is empty. When the content is already stored the new copy is
+// dropped and the existing blob, whatever its encoding, is returned.
+func (s *contentStore) Put(ctx context.Context, content io.Reader, encoding string) (*storedBlob, error) {
+	hash := sha256.New()
+	stored, err := s.write(ctx, io.TeeReader(content, hash), encoding)
+	if err != nil {
+		return nil, err
+	}
+	stored.Digest = hex.EncodeToString(hash.Sum(nil))
+
+	blob, err := s.acquire(ctx, *stored, 1)
+	if err != nil {
+		_ = s.storage.delete(context.Background(), stored.ref())
+		return nil, err
+	}
+	if blob.BlobID != stored.BlobID && blob.CorruptAt != nil {
+		// The new copy hashes to the digest the damaged one should have, so it takes its place
+		if blob, err = s.repair(ctx, blob, stored); err != nil {
+			_ = s.storage.delete(context.Background(), stored.ref())
+			_ = s.Release(context.Background(), stored.Digest)
+			return nil, err
+		}
+	}
+	if blob.BlobID != stored.BlobID {
+		_ = s.storage.delete(context.Background(), stored.ref())
+	}
+
+	return blob, nil
+}
+
+// write stores content in a new blob, compressed with encoding when set.
+func (s *contentStore) write(ctx context.Context, content io.Reader, encoding string) (*storedBlob, error) {
+	if encoding == "" {
+		ref, size, err := s.storage.upload(ctx, fileBucketName, content)
+		if err != nil {
+			return nil, err
+		}
+		return newStoredBlob(ref, size), nil
+	}
+
+	blob, err := s.storage.create(ctx, fileBucketName)
+	if err != nil {
+		return nil, err
+	}
+	written := &countingWriter{w: blob}
+	compressor, err := compression.NewWriter(written, encoding)
+	if err != nil {
+		_ = blob.Abort()
+		return nil, err
+	}
+
+	size, err := io.Copy(compressor, content)
+	if err == nil {
+		err = compressor.Close()
+	}
+	if err != nil {
+		_ = blob.Abort()
+		return nil, err
+	}
+	if err := blob.Close(); err != nil {
+		return nil, err
+	}
+
+	stored := newStoredBlob(blob.ref, size)
+	stored.Encoding = encoding
+	stored.StoredSize = written.n
+	return stored, nil
+}
+
+func newStoredBlob(ref blobRef, size int64) *storedBlob {
+	// New blobs are always named by an ObjectID
+	blobID, _ := primitive.ObjectIDFromHex(ref.id)
+	return &storedBlob{BlobID: blobID, Store: ref.store, Size: size}
+}
+
+// Acquire takes another reference on content that is already stored.
+func (s *contentStore) Acquire(ctx context.Context, digest string) error {
+	result, err := s.collection.UpdateOne(ctx, bson.M{"_id": digest}, bson.M{"$inc": bson.M{"refCount": 1}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("blob not found")
+	}
+	return nil
+}
+
+// Release drops one reference on digest and deletes the content once the
+// last reference is gone.
+func (s *contentStore) Release(ctx context.Context, digest string) error {
+	// Content stored before deduplication has no digest; keep it rather than risk deleting shared bytes
+	if digest == "" {
+		return nil
+	}
+
+	var blob storedBlob
+	err := s.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": digest},
+		bson.M{"$inc": bson.M{"refCount": -1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&blob)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if blob.RefCount > 0 {
+		return nil
+	}
+
+	// Only the caller whose delete matches removes the bytes; a concurrent Put may have revived the digest
+	result, err := s.collection.DeleteOne(ctx, bson.M{"_id": digest, "refCount": bson.M{"$lte": 0}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return nil
+	}
+
+	return s.storage.delete(ctx, blob.ref())
+}
+
+// acquire adds refs references to the digest of stored. If the digest is
+// new, stored becomes its content.
+func (s *contentStore) acquire(ctx context.Context, stored storedBlob, refs int) (*storedBlob, error) {
+	for {
+		var existing storedBlob
+		err := s.collection.FindOneAndUpdate(ctx,
+			bson.M{"_id": stored.Digest},
+			bson.M{"$inc": bson.M{"refCount": refs}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&existing)
+		if err == nil {
+			return &existing, nil
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, err
+		}
+
+		blob := stored
+		blob.RefCount = refs
+		blob.CreatedAt = time.Now().UTC()
+		// Content is hashed as it is written
+		blob.VerifiedAt = &blob.CreatedAt
+		_, err = s.collection.InsertOne(ctx, &blob)
+		if err == nil {
+			return &blob, nil
+		}
+		// Another upload of the same content won the race; reference its blob instead
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+	}
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxRepointAttempts bounds how often references to a blob being replaced
+// are moved before giving up on deleting it.
+const maxRepointAttempts = 3
+
+// RotateBlobKeys wraps the data keys of every blob wrapped by a previous
+// master key with the current key of storage and returns how many keys were
+// rewrapped. The content itself is not touched. Once it returns without
+// error the previous keys can be removed from the configuration.
+func RotateBlobKeys(ctx context.Context, db *mongo.Database, storage *BlobStorage) (int, error) {
+	keys := storage.keys
+	if keys == nil {
+		return 0, errors.New("no master key configured")
+	}
+	keyring := db.Collection("blob_keys")
+
+	cursor, err := keyring.Find(ctx, bson.M{"masterKeyId": bson.M{"$ne": keys.CurrentKeyID()}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	rotated := 0
+	for cursor.Next(ctx) {
+		var key blobKey
+		if err := cursor.Decode(&key); err != nil {
+			return rotated, err
+		}
+
+		keyID, wrapped, err := keys.Rewrap(key.MasterKeyID, key.DataKey, []byte(key.BlobID))
+		if err != nil {
+			return rotated, err
+		}
+
+		// A concurrent rotation may have got there first; its key is just as good
+		result, err := keyring.UpdateOne(ctx,
+			bson.M{"_id": key.BlobID, "masterKeyId": key.MasterKeyID},
+			bson.M{"$set": bson.M{"masterKeyId": keyID, "dataKey": wrapped}},
+		)
+		if err != nil {
+			return rotated, err
+		}
+		rotated += int(result.ModifiedCount)
+	}
+
+	return rotated, cursor.Err()
+}
+
+// EncryptStoredBlobs encrypts file contents and thumbnails stored before
+// encryption was enabled and returns how many blobs were encrypted. Each
+// blob is copied into an encrypted blob in the current storage backend, the
+// files using it are pointed at the copy and the plaintext is deleted.
+// Unfinished uploads are left alone; they expire on their own. It is safe to
+// run repeatedly and while the service is running, though downloads of a
+// blob being replaced fail.
+func EncryptStoredBlobs(ctx context.Context, db *mongo.Database, storage *BlobStorage) (int, error) {
+	if storage.keys == nil {
+		return 0, errors.New("no master key configured")
+	}
+
+	encrypted, err := encryptContent(ctx, db, storage)
+	if err != nil {
+		return encrypted, err
+	}
+	n, err := encryptThumbnails(ctx, db, storage)
+	return encrypted + n, err
+}
+
+func encryptContent(ctx context.Context, db *mongo.Database, storage *BlobStorage) (int, error) {
+	cursor, err := db.Collection("file_blobs").Find(ctx, bson.M{})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	encrypted := 0
+	for cursor.Next(ctx) {
+		var blob storedBlob
+		if err := cursor.Decode(&blob); err != nil {
+			return encrypted, err
+		}
+
+		done, err := encryptBlob(ctx, db, storage, blob.ref(), repointContent)
+		if err != nil {
+			return encrypted, err
+		}
+		if done {
+			encrypted++
+		}
+	}
+
+	return encrypted, cursor.Err()
+}
+
+func encryptThumbnails(ctx context.Context, db *mongo.Database, storage *BlobStorage) (int, error) {
+	cursor, err := db.Collection("user_files").Find(ctx,
+		bson.M{"thumbnails.0": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"thumbnails": 1}),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	encrypted := 0
+	for cursor.Next(ctx) {
+		var file domain.UserFile
+		if err := cursor.Decode(&file); err != nil {
+			return encrypted, err
+		}
+
+		for _, thumbnail := range file.Thumbnails {
+			done, err := encryptBlob(ctx, db, storage, thumbnailRef(thumbnail), repointThumbnail)
+			if err != nil {
+				return encrypted, err
+			}
+			if done {
+				encrypted++
+			}
+		}
+	}
+
+	return encrypted, cursor.Err()
+}
+
+// encryptBlob replaces the blob at ref with an encrypted copy unless it is
+// encrypted already or gone. It reports whether the blob was replaced.
+func encryptBlob(ctx context.Context, db *mongo.Database, storage *BlobStorage, ref blobRef,
+	repoint func(ctx context.Context, db *mongo.Database, from, to blobRef) (int64, error)) (bool, error) {
+	encrypted, err := storage.encrypted(ctx, ref)
+	if err != nil || encrypted {
+		return false, err
+	}
+
+	plaintext, err := storage.open(ctx, ref)
+	if errors.Is(err, domain.ErrBlobNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	copyRef, _, err := storage.upload(ctx, ref.bucket, plaintext)
+	plaintext.Close()
+	if err != nil {
+		return false, err
+	}
+
+	if err := replaceBlob(ctx, db, storage, ref, copyRef, repoint); err != nil {
+		_ = storage.delete(context.Background(), copyRef)
+		return false, err
+	}
+	return true, nil
+}
+
+// replaceBlob points every reference to from at to and deletes from. Uploads
+// that picked up from just before it was replaced are moved on the next try.
+func replaceBlob(ctx context.Context, db *mongo.Database, storage *BlobStorage, from, to blobRef,
+	repoint func(ctx context.Context, db *mongo.Database, from, to blobRef) (int64, error)) error {
+	for attempt := 0; attempt < maxRepointAttempts; attempt++ {
+		if _, err := repoint(ctx, db, from, to); err != nil {
+			return err
+		}
+
+		// Repointing again finds nothing once no writer still holds the old ID
+		moved, err := repoint(ctx, db, from, to)
+		if err != nil {
+			return err
+		}
+		if moved == 0 {
+			return storage.delete(ctx, from)
+		}
+	}
+	return errors.New("blob is still being referenced")
+}
+
+// repointContent moves the deduplicated blob record and the file versions
+// using a content blob to its replacement.
+func repointContent(ctx context.Context, db *mongo.Database, from, to blobRef) (int64, error) {
+	fromID, err := primitive.ObjectIDFromHex(from.id)
+	if err != nil {
+		return 0, err
+	}
+	toID, err := primitive.ObjectIDFromHex(to.id)
+	if err != nil {
+		return 0, err
+	}
+	moved := int64(0)
+
+	result, err := db.Collection("file_blobs").UpdateMany(ctx,
+		bson.M{"blobId": fromID},
+		bson.M{"$set": bson.M{"blobId": toID, "store": to.store}},
+	)
+	if err != nil {
+		return moved, err
+	}
+	moved += result.ModifiedCount
+
+	files := db.Collection("user_files")
+	result, err = files.UpdateMany(ctx,
+		bson.M{"blobId": from.id},
+		bson.M{"$set": bson.M{"blobId": to.id, "store": to.store}},
+	)
+	if err != nil {
+		return moved, err
+	}
+	moved += result.ModifiedCount
+
+	result, err = files.UpdateMany(ctx,
+		bson.M{"versions.blobId": from.id},
+		bson.M{"$set": bson.M{"versions.$[v].blobId": to.id, "versions.$[v].store": to.store}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"v.blobId": from.id}}}),
+	)
+	if err != nil {
+		return moved, err
+	}
+	return moved + result.ModifiedCount, nil
+}
+
+// repointThumbnail moves the thumbnail using a blob to its replacement.
+func repointThumbnail(ctx context.Context, db *mongo.Database, from, to blobRef) (int64, error) {
+	result, err := db.Collection("user_files").UpdateMany(ctx,
+		bson.M{"thumbnails.blobId": from.id},
+		bson.M{"$set": bson.M{"thumbnails.$[t].blobId": to.id, "thumbnails.$[t].store": to.store}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"t.blobId": from.id}}}),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// notExpired matches the expiresAt field of files that have not expired yet,
+// including files that never expire. Expired files are hidden until the
+// sweeper deletes them.
+func notExpired() bson.M {
+	return bson.M{"$not": bson.M{"$lte": time.Now().UTC()}}
+}
+
+// retireExpired moves an expired file that still holds filename in folderID
+// into the trash, as if deleted when it expired, so another file can take the
+// name before the sweeper deletes it for good.
+func (r *fileRepository) retireExpired(ctx context.Context, userID uint, folderID, filename string) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{
+			"userId":    userID,
+			"folderId":  inFolder(folderID),
+			"filename":  filename,
+			"deletedAt": notTrashed,
+			"expiresAt": bson.M{"$lte": time.Now().UTC()},
+		},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"deletedAt": "$expiresAt"}}}},
+	)
+	return err
+}
+
+// SetFileExpiry changes when file expires; nil removes its expiry.
+func (r *fileRepository) SetFileExpiry(ctx context.Context, file *domain.UserFile, expiresAt *time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(file.ID)
+	if err != nil {
+		return domain.ErrFileNotFound
+	}
+
+	update := bson.M{"$unset": bson.M{"expiresAt": ""}}
+	if expiresAt != nil {
+		update = bson.M{"$set": bson.M{"expiresAt": *expiresAt}}
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objID, "deletedAt": notTrashed, "expiresAt": notExpired()}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrFileNotFound
+	}
+
+	file.ExpiresAt = expiresAt
+	return nil
+}
+
+// GetExpiredFiles returns up to limit files, in the trash or not, that
+// expired at or before the given time, earliest first.
+func (r *fileRepository) GetExpiredFiles(ctx context.Context, before time.Time, limit int) ([]*domain.UserFile, error) {
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"expiresAt": bson.M{"$lte": before}},
+		options.Find().SetSort(bson.D{{Key: "expiresAt", Value: 1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var files []*domain.UserFile
+	if err := cursor.All(ctx, &files); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// DeleteExpiredFile deletes file for good if it is still expired at now. A
+// file whose expiry was extended meanwhile is left alone.
+func (r *fileRepository) DeleteExpiredFile(ctx context.Context, file *domain.UserFile, now time.Time) error {
+	return r.deleteFile(ctx, file, bson.M{"expiresAt": bson.M{"$lte": now}})
+}
@@ -0,0 +1,413 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/blobstore"
+	"github.com/OgiDac/CompanyTask/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// legacyStorage reads and writes the GridFS blobs of files stored before
+// storage backends and encryption existed.
+func legacyStorage(db *mongo.Database) *BlobStorage {
+	return NewBlobStorage(db, blobstore.NewGridFS(db), nil, nil)
+}
+
+type inlineFile struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Filename string             `bson:"filename"`
+	Data     []byte             `bson:"data"`
+}
+
+// MigrateInlineFiles moves content still stored in the legacy `data` field of
+// user_files documents into GridFS and returns how many documents were moved.
+// It is safe to run repeatedly; already migrated documents are skipped.
+func MigrateInlineFiles(ctx context.Context, db *mongo.Database) (int, error) {
+	collection := db.Collection("user_files")
+	storage := legacyStorage(db)
+
+	cursor, err := collection.Find(ctx, bson.M{"data": bson.M{"$exists": true}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var file inlineFile
+		if err := cursor.Decode(&file); err != nil {
+			return migrated, err
+		}
+
+		ref, size, err := storage.upload(ctx, fileBucketName, bytes.NewReader(file.Data))
+		if err != nil {
+			return migrated, err
+		}
+
+		_, err = collection.UpdateByID(ctx, file.ID, bson.M{
+			"$set":   bson.M{"blobId": ref.id, "size": size},
+			"$unset": bson.M{"data": ""},
+		})
+		if err != nil {
+			_ = storage.delete(context.Background(), ref)
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, cursor.Err()
+}
+
+// MigrateFileVersions records the content of files stored before versioning
+// as their version 1 and returns how many documents were updated. Run it after
+// MigrateInlineFiles so every version points at a GridFS blob.
+func MigrateFileVersions(ctx context.Context, db *mongo.Database) (int, error) {
+	result, err := db.Collection("user_files").UpdateMany(ctx,
+		bson.M{"versions": bson.M{"$exists": false}, "data": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"version": 1,
+			"versions": bson.A{bson.M{
+				"number":      1,
+				"blobId":      "$blobId",
+				"size":        "$size",
+				"contentType": "$contentType",
+				"uploadedAt":  "$uploadedAt",
+			}},
+		}}}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.ModifiedCount), nil
+}
+
+// MigrateBlobDigests hashes content stored before deduplication, registers it
+// in file_blobs and points the versions that used it at the deduplicated blob.
+// It returns how many blobs were hashed. Run it after MigrateFileVersions.
+func MigrateBlobDigests(ctx context.Context, db *mongo.Database) (int, error) {
+	collection := db.Collection("user_files")
+	// Blobs without a digest predate encryption, so they are read as plaintext
+	store := newContentStore(db, legacyStorage(db))
+
+	cursor, err := collection.Find(ctx, bson.M{
+		"versions": bson.M{"$elemMatch": bson.M{"digest": bson.M{"$in": bson.A{nil, ""}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var file domain.UserFile
+		if err := cursor.Decode(&file); err != nil {
+			return migrated, err
+		}
+		objID, err := primitive.ObjectIDFromHex(file.ID)
+		if err != nil {
+			return migrated, err
+		}
+
+		// Versions restored from each other share a blob; each holds one reference
+		refs := map[string]int{}
+		for _, v := range file.Versions {
+			if v.Digest == "" && v.BlobID != "" {
+				refs[v.BlobID]++
+			}
+		}
+
+		for blobHex, count := range refs {
+			blobID, err := primitive.ObjectIDFromHex(blobHex)
+			if err != nil {
+				continue
+			}
+
+			ref := blobRef{bucket: fileBucketName, id: blobHex}
+			digest, size, err := hashBlob(ctx, store.storage, ref)
+			if err != nil {
+				return migrated, err
+			}
+
+			blob, err := store.acquire(ctx, storedBlob{Digest: digest, BlobID: blobID, Size: size}, count)
+			if err != nil {
+				return migrated, err
+			}
+
+			// The digest may already be stored elsewhere, in another backend or compressed
+			content := bson.M{"digest": digest, "blobId": blob.BlobID.Hex()}
+			if blob.Store != "" {
+				content["store"] = blob.Store
+			}
+			if blob.Encoding != "" {
+				content["encoding"] = blob.Encoding
+				content["storedSize"] = blob.StoredSize
+			}
+			versionContent := bson.M{}
+			for field, value := range content {
+				versionContent["versions.$[v]."+field] = value
+			}
+
+			_, err = collection.UpdateOne(ctx, bson.M{"_id": objID},
+				bson.M{"$set": versionContent},
+				options.Update().SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"v.blobId": blobHex}}}),
+			)
+			if err != nil {
+				return migrated, err
+			}
+			_, err = collection.UpdateOne(ctx, bson.M{"_id": objID, "blobId": blobHex},
+				bson.M{"$set": content},
+			)
+			if err != nil {
+				return migrated, err
+			}
+
+			if blob.BlobID != blobID {
+				if err := store.storage.delete(ctx, ref); err != nil {
+					return migrated, err
+				}
+			}
+			migrated++
+		}
+	}
+
+	return migrated, cursor.Err()
+}
+
+func hashBlob(ctx context.Context, storage *BlobStorage, ref blobRef) (string, int64, error) {
+	stream, err := storage.open(ctx, ref)
+	if err != nil {
+		return "", 0, err
+	}
+	defer stream.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, stream)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// MigrateStorageUsage records the usage of users who stored files before
+// quotas were tracked and returns how many users were recorded. Users that
+// already have a usage record are left untouched.
+func MigrateStorageUsage(ctx context.Context, db *mongo.Database) (int, error) {
+	cursor, err := db.Collection("user_files").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$userId",
+			"bytes": bson.M{"$sum": bson.M{"$sum": "$versions.size"}},
+			"files": bson.M{"$sum": 1},
+		}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	usages := db.Collection("user_storage")
+	migrated := 0
+	for cursor.Next(ctx) {
+		var usage domain.StorageUsage
+		if err := cursor.Decode(&usage); err != nil {
+			return migrated, err
+		}
+
+		result, err := usages.UpdateOne(ctx,
+			bson.M{"_id": usage.UserID},
+			bson.M{"$setOnInsert": bson.M{"bytes": usage.Bytes, "files": usage.Files}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return migrated, err
+		}
+		if result.UpsertedCount > 0 {
+			migrated++
+		}
+	}
+
+	return migrated, cursor.Err()
+}
+
+// MigrateThumbnails requests thumbnails for images stored before they were
+// generated and returns how many files were marked. The generator picks them
+// up in the background.
+func MigrateThumbnails(ctx context.Context, db *mongo.Database) (int, error) {
+	result, err := db.Collection("user_files").UpdateMany(ctx,
+		bson.M{"contentType": bson.M{"$in": domain.ThumbnailContentTypes}, "thumbnailStatus": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"thumbnailStatus": domain.ThumbnailPending}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.ModifiedCount), nil
+}
+
+// MigrateScanStatus queues versions stored before uploads were scanned for a
+// malware scan and returns how many files were queued. They can't be
+// downloaded until the background scan marks them clean.
+func MigrateScanStatus(ctx context.Context, db *mongo.Database) (int, error) {
+	collection := db.Collection("user_files")
+
+	result, err := collection.UpdateMany(ctx,
+		bson.M{"versions": bson.M{"$elemMatch": bson.M{"scanStatus": bson.M{"$exists": false}}}},
+		bson.M{"$set": bson.M{"versions.$[v].scanStatus": domain.ScanPending}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"v.scanStatus": bson.M{"$exists": false}}}}),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = collection.UpdateMany(ctx,
+		bson.M{"scanStatus": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"scanStatus": domain.ScanPending}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.ModifiedCount), nil
+}
+
+// MigrateBlobKeys moves the data keys of blobs encrypted before storage
+// backends existed from their GridFS metadata into blob_keys and returns how
+// many keys were moved.
+func MigrateBlobKeys(ctx context.Context, db *mongo.Database) (int, error) {
+	keyring := db.Collection("blob_keys")
+
+	migrated := 0
+	for _, name := range []string{fileBucketName, thumbnailBucketName, uploadBucketName} {
+		n, err := migrateBucketKeys(ctx, db.Collection(name+".files"), keyring)
+		migrated += n
+		if err != nil {
+			return migrated, err
+		}
+	}
+
+	return migrated, nil
+}
+
+func migrateBucketKeys(ctx context.Context, files, keyring *mongo.Collection) (int, error) {
+	cursor, err := files.Find(ctx, bson.M{"metadata.key": bson.M{"$exists": true}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var file struct {
+			ID         primitive.ObjectID `bson:"_id"`
+			UploadDate time.Time          `bson:"uploadDate"`
+			Metadata   struct {
+				Key blobKey `bson:"key"`
+			} `bson:"metadata"`
+		}
+		if err := cursor.Decode(&file); err != nil {
+			return migrated, err
+		}
+
+		// A key already in blob_keys may have been rotated since; it wins
+		key := file.Metadata.Key
+		_, err := keyring.UpdateOne(ctx,
+			bson.M{"_id": file.ID.Hex()},
+			bson.M{"$setOnInsert": bson.M{"masterKeyId": key.MasterKeyID, "dataKey": key.DataKey, "createdAt": file.UploadDate}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return migrated, err
+		}
+		if _, err := files.UpdateByID(ctx, file.ID, bson.M{"$unset": bson.M{"metadata.key": ""}}); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, cursor.Err()
+}
+
+// MigrateDuplicateNames renames files that share their folder and name with
+// another file, which older versions of the service stored on every upload,
+// and returns how many files were renamed. The most recently uploaded file
+// keeps the name; the others get a number, as in "report (2).pdf". Run it
+// before the unique name index is built.
+func MigrateDuplicateNames(ctx context.Context, db *mongo.Database) (int, error) {
+	collection := db.Collection("user_files")
+
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "uploadedAt", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"userId": "$userId", "folderId": "$folderId", "filename": "$filename", "deletedAt": "$deletedAt"},
+			"ids": bson.M{"$push": "$_id"},
+		}}},
+		{{Key: "$match", Value: bson.M{"ids.1": bson.M{"$exists": true}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var group struct {
+			Key struct {
+				UserID   uint   `bson:"userId"`
+				FolderID string `bson:"folderId"`
+				Filename string `bson:"filename"`
+			} `bson:"_id"`
+			IDs []primitive.ObjectID `bson:"ids"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return migrated, err
+		}
+
+		n := 2
+		for _, id := range group.IDs[1:] {
+			var name string
+			for ; ; n++ {
+				name = numberedName(group.Key.Filename, n)
+				count, err := collection.CountDocuments(ctx, bson.M{
+					"userId":   group.Key.UserID,
+					"folderId": inFolder(group.Key.FolderID),
+					"filename": name,
+				})
+				if err != nil {
+					return migrated, err
+				}
+				if count == 0 {
+					break
+				}
+			}
+
+			if _, err := collection.UpdateByID(ctx, id, bson.M{"$set": bson.M{"filename": name}}); err != nil {
+				return migrated, err
+			}
+			migrated++
+		}
+	}
+
+	return migrated, cursor.Err()
+}
+
+// numberedName inserts n before the extension of name.
+func numberedName(name string, n int) string {
+	ext := path.Ext(name)
+	if ext == name {
+		ext = ""
+	}
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext)
+}
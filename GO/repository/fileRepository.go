@@ -1,45 +1,411 @@
 package repository
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"io"
+	"log"
+	"time"
 
 	"github.com/OgiDac/CompanyTask/domain"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+const fileBucketName = "user_files"
+
+const thumbnailBucketName = "file_thumbnails"
+
+const uploadBucketName = "file_uploads"
+
+// maxVersionAttempts bounds retries when concurrent writers add versions to the same file
+const maxVersionAttempts = 5
+
+var errFileModified = errors.New("file was modified concurrently")
+
+// notTrashed matches the deletedAt field of files that are not in the trash.
+// Every lookup but the trash's own leaves trashed files out.
+var notTrashed = bson.M{"$exists": false}
+
 type FileRepository interface {
-	SaveUserFile(ctx context.Context, file *domain.UserFile) error
+	StoreContent(ctx context.Context, content io.Reader, encoding string) (*domain.FileVersion, error)
+	ReleaseContent(ctx context.Context, version *domain.FileVersion) error
+	SaveUserFile(ctx context.Context, file *domain.UserFile, version domain.FileVersion) error
+	RestoreFileVersion(ctx context.Context, file *domain.UserFile, number int) error
+	DeleteFileVersions(ctx context.Context, file *domain.UserFile, numbers []int) error
 	GetFileByID(ctx context.Context, id string) (*domain.UserFile, error)
+	GetFileByName(ctx context.Context, userID uint, folderID, filename string) (*domain.UserFile, error)
+	GetFileByDigest(ctx context.Context, userID uint, digest string) (*domain.UserFile, error)
+	GetFilesInFolder(ctx context.Context, userID uint, folderID string) ([]*domain.UserFile, error)
+	UpdateFile(ctx context.Context, file *domain.UserFile, update domain.FileUpdate) error
+	DeleteFile(ctx context.Context, file *domain.UserFile) error
+	OpenFileContent(ctx context.Context, file *domain.UserFile) (io.ReadSeekCloser, error)
 	GetFilesByUserID(ctx context.Context, userID uint) ([]*domain.UserFile, error)
-	DeleteFilesByUserID(ctx context.Context, userID uint) error
+	SearchFiles(ctx context.Context, userID uint, search domain.FileSearch) ([]*domain.UserFile, string, error)
+	SetFileTags(ctx context.Context, file *domain.UserFile, tags []string) error
+	GetTagCounts(ctx context.Context, userID uint) ([]*domain.TagCount, error)
+	RenameTags(ctx context.Context, userID uint, from []string, to string) (int, error)
+	SetFileText(ctx context.Context, file *domain.UserFile, text string) error
+	GetFileText(ctx context.Context, fileID string) (string, error)
+	SearchFileText(ctx context.Context, query string, userIDs []uint, limit int) ([]*domain.FileTextMatch, error)
+	TrashFile(ctx context.Context, file *domain.UserFile, deletedAt time.Time) error
+	TrashFilesByUserID(ctx context.Context, userID uint, deletedAt time.Time) error
+	GetTrashedFile(ctx context.Context, id string) (*domain.UserFile, error)
+	GetTrashedFiles(ctx context.Context, userID uint) ([]*domain.UserFile, error)
+	GetExpiredTrash(ctx context.Context, before time.Time, limit int) ([]*domain.UserFile, error)
+	SetFileExpiry(ctx context.Context, file *domain.UserFile, expiresAt *time.Time) error
+	GetExpiredFiles(ctx context.Context, before time.Time, limit int) ([]*domain.UserFile, error)
+	DeleteExpiredFile(ctx context.Context, file *domain.UserFile, now time.Time) error
+	RestoreFile(ctx context.Context, file *domain.UserFile, folderID string) error
+	GetPendingThumbnails(ctx context.Context, limit int) ([]*domain.UserFile, error)
+	StoreThumbnail(ctx context.Context, file *domain.UserFile, thumbnail *domain.Thumbnail, content io.Reader) error
+	SetThumbnails(ctx context.Context, file *domain.UserFile, status domain.ThumbnailStatus, thumbnails []domain.Thumbnail) error
+	OpenThumbnail(ctx context.Context, thumbnail domain.Thumbnail) (io.ReadSeekCloser, error)
+	DeleteThumbnails(ctx context.Context, thumbnails []domain.Thumbnail) error
+	GetPendingScans(ctx context.Context, limit int) ([]*domain.UserFile, error)
+	SetScanResult(ctx context.Context, file *domain.UserFile, number int, result domain.ScanResult) error
+	QuarantineVersion(ctx context.Context, entry *domain.QuarantineEntry) error
+	GetUnverifiedContent(ctx context.Context, before time.Time, limit int) ([]string, error)
+	VerifyContent(ctx context.Context, digest string) error
 }
 
 type fileRepository struct {
 	collection *mongo.Collection
+	quarantine *mongo.Collection
+	texts      *mongo.Collection
+	content    *contentStore
+	storage    *BlobStorage
 }
 
-func NewFileRepository(db *mongo.Database) FileRepository {
+// NewFileRepository keeps file metadata in MongoDB and stores content and
+// thumbnails in storage.
+func NewFileRepository(db *mongo.Database, storage *BlobStorage) FileRepository {
+	collection := db.Collection("user_files")
+
+	// Writes rely on the name index to reject duplicates, so it must exist.
+	// MigrateDuplicateNames clears the duplicates older versions left behind.
+	if _, err := collection.Indexes().CreateOne(context.Background(), fileNameIndex()); err != nil {
+		log.Fatalf("Failed to create file name index: %v", err)
+	}
+	if _, err := collection.Indexes().CreateMany(context.Background(), fileIndexes()); err != nil {
+		log.Printf("Failed to create file indexes: %v", err)
+	}
+
+	texts := db.Collection("file_texts")
+	if _, err := texts.Indexes().CreateMany(context.Background(), fileTextIndexes()); err != nil {
+		log.Printf("Failed to create file text indexes: %v", err)
+	}
+
+	if _, err := db.Collection("file_blobs").Indexes().CreateMany(context.Background(), blobIndexes()); err != nil {
+		log.Printf("Failed to create blob indexes: %v", err)
+	}
+
 	return &fileRepository{
-		collection: db.Collection("user_files"),
+		collection: collection,
+		quarantine: db.Collection("file_quarantine"),
+		texts:      texts,
+		content:    newContentStore(db, storage),
+		storage:    storage,
+	}
+}
+
+// StoreContent streams content into storage and returns it as a version
+// that is not attached to any file yet. Content already stored under the same
+// SHA-256 digest is shared instead of copied. The caller owns one reference
+// on the content until it is passed to SaveUserFile or ReleaseContent. New
+// content is compressed with encoding when it is set.
+func (f *fileRepository) StoreContent(ctx context.Context, content io.Reader, encoding string) (*domain.FileVersion, error) {
+	blob, err := f.content.Put(ctx, content, encoding)
+	if err != nil {
+		return nil, err
 	}
+
+	return &domain.FileVersion{
+		BlobID:     blob.BlobID.Hex(),
+		Store:      blob.Store,
+		Digest:     blob.Digest,
+		Size:       blob.Size,
+		Encoding:   blob.Encoding,
+		StoredSize: blob.StoredSize,
+	}, nil
+}
+
+// ReleaseContent drops the reference taken by StoreContent.
+func (f *fileRepository) ReleaseContent(ctx context.Context, version *domain.FileVersion) error {
+	return f.content.Release(ctx, version.Digest)
+}
+
+// GetUnverifiedContent returns the digests of up to limit stored contents
+// last verified before before, oldest first.
+func (f *fileRepository) GetUnverifiedContent(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	return f.content.Unverified(ctx, before, limit)
 }
 
-func (f *fileRepository) SaveUserFile(ctx context.Context, file *domain.UserFile) error {
-	_, err := f.collection.InsertOne(ctx, file)
+// VerifyContent checks the content stored under digest still hashes to it and
+// returns domain.ErrContentCorrupt, after flagging the files using it, when
+// it doesn't.
+func (f *fileRepository) VerifyContent(ctx context.Context, digest string) error {
+	return f.content.Verify(ctx, digest)
+}
+
+// SaveUserFile adds stored content as a new version of the user's file with
+// the same name in the same folder, creating the file when it does not exist yet. On return file
+// describes the stored file including its full version history.
+func (f *fileRepository) SaveUserFile(ctx context.Context, file *domain.UserFile, version domain.FileVersion) error {
+	version.ContentType = file.ContentType
+	version.UploadedAt = file.UploadedAt
+
+	var err error
+	for attempt := 0; attempt < maxVersionAttempts; attempt++ {
+		err = f.addVersion(ctx, file, version)
+		if !errors.Is(err, errFileModified) {
+			break
+		}
+	}
+
 	return err
 }
 
+func (f *fileRepository) addVersion(ctx context.Context, file *domain.UserFile, version domain.FileVersion) error {
+	var existing domain.UserFile
+	err := f.collection.FindOne(ctx, bson.M{
+		"userId":    file.UserID,
+		"folderId":  inFolder(file.FolderID),
+		"filename":  file.Filename,
+		"deletedAt": notTrashed,
+		"expiresAt": notExpired(),
+	}).Decode(&existing)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if err := f.retireExpired(ctx, file.UserID, file.FolderID, file.Filename); err != nil {
+			return err
+		}
+
+		version.Number = 1
+		created := file.AtVersion(version)
+		created.Versions = []domain.FileVersion{version}
+		created.ThumbnailStatus = domain.ThumbnailStatusFor(version.ContentType)
+		created.Thumbnails = nil
+		created.Data = nil
+
+		result, err := f.collection.InsertOne(ctx, created)
+		if mongo.IsDuplicateKeyError(err) {
+			// Another upload created the file first; add a version to it instead
+			return errFileModified
+		}
+		if err != nil {
+			return err
+		}
+		if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+			created.ID = id.Hex()
+		}
+		*file = *created
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	version.Number = existing.Version + 1
+	if err := f.pushVersion(ctx, &existing, version); err != nil {
+		return err
+	}
+
+	*file = existing
+	return nil
+}
+
+func (r *fileRepository) RestoreFileVersion(ctx context.Context, file *domain.UserFile, number int) error {
+	version, ok := file.FindVersion(number)
+	if !ok {
+		return domain.ErrFileVersionNotFound
+	}
+
+	// Restoring appends a copy, so the history stays append-only
+	version.Number = file.Version + 1
+	version.UploadedAt = time.Now().UTC()
+
+	if err := r.content.Acquire(ctx, version.Digest); err != nil {
+		return err
+	}
+	if err := r.pushVersion(ctx, file, version); err != nil {
+		_ = r.content.Release(context.Background(), version.Digest)
+		return err
+	}
+
+	return nil
+}
+
+// pushVersion appends version to file and makes it current, provided nobody
+// else changed the current version since file was read. Thumbnails of the
+// previous version are dropped and new ones requested for images.
+func (r *fileRepository) pushVersion(ctx context.Context, file *domain.UserFile, version domain.FileVersion) error {
+	objID, err := primitive.ObjectIDFromHex(file.ID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	set := bson.M{
+		"version":     version.Number,
+		"blobId":      version.BlobID,
+		"digest":      version.Digest,
+		"size":        version.Size,
+		"contentType": version.ContentType,
+		"uploadedAt":  version.UploadedAt,
+		"scanStatus":  version.ScanStatus,
+	}
+	unset := bson.M{"thumbnails": ""}
+	if version.Store != "" {
+		set["store"] = version.Store
+	} else {
+		unset["store"] = ""
+	}
+	if version.Encoding != "" {
+		set["encoding"] = version.Encoding
+		set["storedSize"] = version.StoredSize
+	} else {
+		unset["encoding"] = ""
+		unset["storedSize"] = ""
+	}
+	if version.Corrupt {
+		set["corrupt"] = true
+	} else {
+		unset["corrupt"] = ""
+	}
+	status := domain.ThumbnailStatusFor(version.ContentType)
+	if status != "" {
+		set["thumbnailStatus"] = status
+	} else {
+		unset["thumbnailStatus"] = ""
+	}
+
+	// The previous document tells which thumbnails were actually replaced
+	var previous domain.UserFile
+	err = r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": objID, "version": file.Version},
+		bson.M{"$push": bson.M{"versions": version}, "$set": set, "$unset": unset},
+		options.FindOneAndUpdate().SetProjection(bson.M{"thumbnails": 1}),
+	).Decode(&previous)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return errFileModified
+	}
+	if err != nil {
+		return err
+	}
+	r.deleteThumbnails(ctx, previous.Thumbnails)
+
+	versions := append(file.Versions, version)
+	*file = *file.AtVersion(version)
+	file.Versions = versions
+	file.ThumbnailStatus = status
+	file.Thumbnails = nil
+	return nil
+}
+
+// DeleteFileVersions removes old versions from file and releases their
+// content, which is deleted once no other version references it.
+func (r *fileRepository) DeleteFileVersions(ctx context.Context, file *domain.UserFile, numbers []int) error {
+	objID, err := primitive.ObjectIDFromHex(file.ID)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objID, "version": file.Version}, bson.M{
+		"$pull": bson.M{"versions": bson.M{"number": bson.M{"$in": numbers}}},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errFileModified
+	}
+
+	removed := map[int]bool{}
+	for _, n := range numbers {
+		removed[n] = true
+	}
+
+	var kept []domain.FileVersion
+	for _, v := range file.Versions {
+		if !removed[v.Number] {
+			kept = append(kept, v)
+			continue
+		}
+		if err := r.content.Release(ctx, v.Digest); err != nil {
+			return err
+		}
+	}
+
+	file.Versions = kept
+	return nil
+}
+
 func (r *fileRepository) GetFileByID(ctx context.Context, id string) (*domain.UserFile, error) {
+	return r.getFile(ctx, id, bson.M{"deletedAt": notTrashed, "expiresAt": notExpired()})
+}
+
+// getFile returns the file with the given ID if it matches filter.
+func (r *fileRepository) getFile(ctx context.Context, id string, filter bson.M) (*domain.UserFile, error) {
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, errors.New("invalid id")
+		return nil, domain.ErrFileNotFound
+	}
+	filter["_id"] = objID
+
+	var result domain.UserFile
+	err = r.collection.FindOne(ctx, filter).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if result.BlobID == "" {
+		result.Size = int64(len(result.Data))
+	}
+
+	return &result, nil
+}
+
+func (r *fileRepository) GetFileByName(ctx context.Context, userID uint, folderID, filename string) (*domain.UserFile, error) {
+	var result domain.UserFile
+	err := r.collection.FindOne(ctx, bson.M{
+		"userId":    userID,
+		"folderId":  inFolder(folderID),
+		"filename":  filename,
+		"deletedAt": notTrashed,
+		"expiresAt": notExpired(),
+	}).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrFileNotFound
+	}
+	if err != nil {
+		return nil, err
 	}
 
+	return &result, nil
+}
+
+// GetFileByDigest returns a file of the user with a clean, intact version
+// whose content has the hex SHA-256 digest.
+func (r *fileRepository) GetFileByDigest(ctx context.Context, userID uint, digest string) (*domain.UserFile, error) {
 	var result domain.UserFile
-	err = r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&result)
+	err := r.collection.FindOne(ctx, bson.M{
+		"userId": userID,
+		"versions": bson.M{"$elemMatch": bson.M{
+			"digest":     digest,
+			"scanStatus": domain.ScanClean,
+			"corrupt":    bson.M{"$ne": true},
+		}},
+		"deletedAt": notTrashed,
+		"expiresAt": notExpired(),
+	}).Decode(&result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrFileNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -47,8 +413,332 @@ func (r *fileRepository) GetFileByID(ctx context.Context, id string) (*domain.Us
 	return &result, nil
 }
 
+// UpdateFile applies update to the descriptive fields of file. A content type
+// change applies to the current version. On return file holds the stored
+// state after the update.
+func (r *fileRepository) UpdateFile(ctx context.Context, file *domain.UserFile, update domain.FileUpdate) error {
+	folderID, filename := file.FolderID, file.Filename
+	if update.FolderID != nil {
+		folderID = *update.FolderID
+	}
+	if update.Filename != nil {
+		filename = *update.Filename
+	}
+	if folderID != file.FolderID || filename != file.Filename {
+		if err := r.retireExpired(ctx, file.UserID, folderID, filename); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; attempt < maxVersionAttempts; attempt++ {
+		err := r.updateFile(ctx, file, update)
+		if !errors.Is(err, errFileModified) {
+			return err
+		}
+
+		// A new version was added meanwhile; the content type change must follow it
+		current, err := r.GetFileByID(ctx, file.ID)
+		if err != nil {
+			return err
+		}
+		*file = *current
+	}
+
+	return errFileModified
+}
+
+func (r *fileRepository) updateFile(ctx context.Context, file *domain.UserFile, update domain.FileUpdate) error {
+	objID, err := primitive.ObjectIDFromHex(file.ID)
+	if err != nil {
+		return domain.ErrFileNotFound
+	}
+
+	set := bson.M{}
+	unset := bson.M{}
+	opts := options.Update()
+
+	if update.FolderID != nil {
+		if *update.FolderID == "" {
+			unset["folderId"] = ""
+		} else {
+			set["folderId"] = *update.FolderID
+		}
+	}
+	if update.Filename != nil {
+		set["filename"] = *update.Filename
+	}
+	if update.ContentType != nil {
+		set["contentType"] = *update.ContentType
+		set["versions.$[current].contentType"] = *update.ContentType
+		opts.SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"current.number": file.Version}}})
+	}
+	if update.Description != nil {
+		if *update.Description == "" {
+			unset["description"] = ""
+		} else {
+			set["description"] = *update.Description
+		}
+	}
+	for key, value := range update.Metadata {
+		if value == nil {
+			unset["metadata."+key] = ""
+		} else {
+			set["metadata."+key] = *value
+		}
+	}
+
+	changes := bson.M{}
+	if len(set) > 0 {
+		changes["$set"] = set
+	}
+	if len(unset) > 0 {
+		changes["$unset"] = unset
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objID, "version": file.Version}, changes, opts)
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrFileExists
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errFileModified
+	}
+
+	updated, err := r.GetFileByID(ctx, file.ID)
+	if err != nil {
+		return err
+	}
+	*file = *updated
+	return nil
+}
+
+// DeleteFile permanently deletes a file in the trash with its whole version
+// history and releases the content of every version. On return file holds
+// the deleted document.
+func (r *fileRepository) DeleteFile(ctx context.Context, file *domain.UserFile) error {
+	// A file restored meanwhile is left alone
+	return r.deleteFile(ctx, file, bson.M{"deletedAt": bson.M{"$exists": true}})
+}
+
+// deleteFile deletes file for good if it still matches filter, and releases
+// its content.
+func (r *fileRepository) deleteFile(ctx context.Context, file *domain.UserFile, filter bson.M) error {
+	objID, err := primitive.ObjectIDFromHex(file.ID)
+	if err != nil {
+		return domain.ErrFileNotFound
+	}
+	filter["_id"] = objID
+
+	// Release what was actually deleted, including versions added since file was read
+	var deleted domain.UserFile
+	err = r.collection.FindOneAndDelete(ctx, filter).Decode(&deleted)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return domain.ErrFileNotFound
+	}
+	if err != nil {
+		return err
+	}
+	*file = deleted
+	r.deleteThumbnails(ctx, file.Thumbnails)
+	_, _ = r.texts.DeleteOne(ctx, bson.M{"_id": file.ID})
+
+	for _, v := range file.Versions {
+		if err := r.content.Release(ctx, v.Digest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *fileRepository) OpenFileContent(ctx context.Context, file *domain.UserFile) (io.ReadSeekCloser, error) {
+	// Documents that have not been migrated yet still carry their content inline
+	if file.BlobID == "" {
+		return nopSeekCloser{bytes.NewReader(file.Data)}, nil
+	}
+
+	if file.Encoding != "" {
+		return openCompressedBlob(ctx, r.storage, file)
+	}
+	return r.storage.openBlob(ctx, blobRef{store: file.Store, bucket: fileBucketName, id: file.BlobID}, file.Size)
+}
+
+// GetPendingThumbnails returns up to limit files whose current version still
+// needs thumbnails, oldest uploads first. Content is only decoded once it
+// passed the malware scan.
+func (r *fileRepository) GetPendingThumbnails(ctx context.Context, limit int) ([]*domain.UserFile, error) {
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"thumbnailStatus": domain.ThumbnailPending, "scanStatus": domain.ScanClean},
+		options.Find().SetSort(bson.D{{Key: "uploadedAt", Value: 1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var files []*domain.UserFile
+	if err := cursor.All(ctx, &files); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// StoreThumbnail stores content as a thumbnail of file and records its blob
+// and length in thumbnail. It is not attached to the file until SetThumbnails.
+func (r *fileRepository) StoreThumbnail(ctx context.Context, file *domain.UserFile, thumbnail *domain.Thumbnail, content io.Reader) error {
+	ref, size, err := r.storage.upload(ctx, thumbnailBucketName, content)
+	if err != nil {
+		return err
+	}
+
+	thumbnail.BlobID = ref.id
+	thumbnail.Store = ref.store
+	thumbnail.Length = size
+	return nil
+}
+
+// SetThumbnails finishes thumbnail generation for the current version of
+// file. When a new version became current meanwhile the thumbnails are
+// discarded, as the new version is waiting for its own.
+func (r *fileRepository) SetThumbnails(ctx context.Context, file *domain.UserFile, status domain.ThumbnailStatus, thumbnails []domain.Thumbnail) error {
+	objID, err := primitive.ObjectIDFromHex(file.ID)
+	if err != nil {
+		return domain.ErrFileNotFound
+	}
+
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": objID, "version": file.Version, "thumbnailStatus": domain.ThumbnailPending},
+		bson.M{"$set": bson.M{"thumbnailStatus": status, "thumbnails": thumbnails}},
+	)
+	if err != nil {
+		r.deleteThumbnails(context.Background(), thumbnails)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		r.deleteThumbnails(ctx, thumbnails)
+		return nil
+	}
+
+	file.ThumbnailStatus = status
+	file.Thumbnails = thumbnails
+	return nil
+}
+
+func (r *fileRepository) OpenThumbnail(ctx context.Context, thumbnail domain.Thumbnail) (io.ReadSeekCloser, error) {
+	return r.storage.openBlob(ctx, thumbnailRef(thumbnail), thumbnail.Length)
+}
+
+// GetPendingScans returns up to limit files with versions waiting for a
+// malware scan, oldest uploads first.
+func (r *fileRepository) GetPendingScans(ctx context.Context, limit int) ([]*domain.UserFile, error) {
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"versions.scanStatus": domain.ScanPending},
+		options.Find().SetSort(bson.D{{Key: "uploadedAt", Value: 1}}).SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var files []*domain.UserFile
+	if err := cursor.All(ctx, &files); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// SetScanResult records the scan result of one version of file, and of the
+// file itself while that version is current.
+func (r *fileRepository) SetScanResult(ctx context.Context, file *domain.UserFile, number int, result domain.ScanResult) error {
+	objID, err := primitive.ObjectIDFromHex(file.ID)
+	if err != nil {
+		return domain.ErrFileNotFound
+	}
+
+	set := bson.M{"versions.$[v].scanStatus": result.Status}
+	if result.Signature != "" {
+		set["versions.$[v].scanSignature"] = result.Signature
+	}
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": set},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"v.number": number}}}),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objID, "version": number}, bson.M{"$set": bson.M{"scanStatus": result.Status}})
+	if err != nil {
+		return err
+	}
+
+	for i := range file.Versions {
+		if file.Versions[i].Number == number {
+			file.Versions[i].ScanStatus = result.Status
+			file.Versions[i].ScanSignature = result.Signature
+		}
+	}
+	if file.Version == number {
+		file.ScanStatus = result.Status
+	}
+	return nil
+}
+
+func (r *fileRepository) QuarantineVersion(ctx context.Context, entry *domain.QuarantineEntry) error {
+	result, err := r.quarantine.InsertOne(ctx, entry)
+	if err != nil {
+		return err
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		entry.ID = id.Hex()
+	}
+	return nil
+}
+
+// DeleteThumbnails removes stored thumbnails that are not attached to a file.
+func (r *fileRepository) DeleteThumbnails(ctx context.Context, thumbnails []domain.Thumbnail) error {
+	for _, t := range thumbnails {
+		if t.BlobID == "" {
+			continue
+		}
+		if err := r.storage.delete(ctx, thumbnailRef(t)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func thumbnailRef(thumbnail domain.Thumbnail) blobRef {
+	return blobRef{store: thumbnail.Store, bucket: thumbnailBucketName, id: thumbnail.BlobID}
+}
+
+// deleteThumbnails drops thumbnails that were just detached from a file. It is
+// best effort, as a leftover blob only takes space.
+func (r *fileRepository) deleteThumbnails(ctx context.Context, thumbnails []domain.Thumbnail) {
+	_ = r.DeleteThumbnails(ctx, thumbnails)
+}
+
+// openSeekable opens a stream of the given size as a seekable stream. It is
+// opened eagerly so a missing blob is reported before any response is written.
+func openSeekable(size int64, open func() (io.ReadCloser, error)) (*seekableStream, error) {
+	content := newSeekableStream(size, open)
+
+	var err error
+	if content.stream, err = open(); err != nil {
+		return nil, err
+	}
+
+	return content, nil
+}
+
 func (r *fileRepository) GetFilesByUserID(ctx context.Context, userID uint) ([]*domain.UserFile, error) {
-	cursor, err := r.collection.Find(ctx, bson.M{"userId": userID})
+	cursor, err := r.collection.Find(ctx, bson.M{"userId": userID, "deletedAt": notTrashed, "expiresAt": notExpired()})
 	if err != nil {
 		return nil, err
 	}
@@ -66,7 +756,20 @@ func (r *fileRepository) GetFilesByUserID(ctx context.Context, userID uint) ([]*
 	return files, nil
 }
 
-func (r *fileRepository) DeleteFilesByUserID(ctx context.Context, userID uint) error {
-	_, err := r.collection.DeleteMany(ctx, bson.M{"userId": userID})
-	return err
+func (r *fileRepository) GetFilesInFolder(ctx context.Context, userID uint, folderID string) ([]*domain.UserFile, error) {
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"userId": userID, "folderId": inFolder(folderID), "deletedAt": notTrashed, "expiresAt": notExpired()},
+		options.Find().SetSort(bson.D{{Key: "filename", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var files []*domain.UserFile
+	if err := cursor.All(ctx, &files); err != nil {
+		return nil, err
+	}
+
+	return files, nil
 }
@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// sortFields maps each search order to the document field it sorts by.
+var sortFields = map[domain.FileSort]string{
+	domain.FileSortName:        "filename",
+	domain.FileSortSize:        "size",
+	domain.FileSortUploadedAt:  "uploadedAt",
+	domain.FileSortContentType: "contentType",
+}
+
+// fileMetaProjection leaves out the version history and legacy inline
+// content, which listings never show.
+var fileMetaProjection = bson.M{"versions": 0, "data": 0}
+
+// fileNameIndex keeps names unique per folder among files outside the trash,
+// which have no deletedAt. Trashed files keep the time they were deleted, so
+// they don't collide with each other or with the file that took their name.
+func fileNameIndex() mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "folderId", Value: 1}, {Key: "filename", Value: 1}, {Key: "deletedAt", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+}
+
+// fileIndexes back tag filters, the expiry sweep and every search order.
+// Searches are scoped to a user and page on _id within equal values.
+func fileIndexes() []mongo.IndexModel {
+	indexes := []mongo.IndexModel{{
+		Keys: bson.D{{Key: "userId", Value: 1}, {Key: "tags", Value: 1}},
+	}, {
+		Keys: bson.D{{Key: "userId", Value: 1}, {Key: "versions.digest", Value: 1}},
+	}, {
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	}}
+	for _, field := range []string{"filename", "size", "uploadedAt", "contentType"} {
+		indexes = append(indexes, mongo.IndexModel{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: field, Value: 1}, {Key: "_id", Value: 1}},
+		})
+	}
+	return indexes
+}
+
+// SearchFiles returns the user's files matching search without their version
+// history, and the cursor of the next page when there is one.
+func (r *fileRepository) SearchFiles(ctx context.Context, userID uint, search domain.FileSearch) ([]*domain.UserFile, string, error) {
+	field, ok := sortFields[search.Sort]
+	if !ok {
+		return nil, "", domain.ErrInvalidSearch
+	}
+
+	filter := searchFilter(userID, search)
+	if search.Cursor != "" {
+		after, err := decodeSearchCursor(search)
+		if err != nil {
+			return nil, "", err
+		}
+		// Equal values continue after the last _id of the previous page
+		op := "$gt"
+		if search.Desc {
+			op = "$lt"
+		}
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.M{field: bson.M{op: after.value}},
+			bson.M{field: after.value, "_id": bson.M{op: after.id}},
+		}})
+	}
+
+	order := 1
+	if search.Desc {
+		order = -1
+	}
+	opts := options.Find().
+		SetProjection(fileMetaProjection).
+		SetSort(bson.D{{Key: field, Value: order}, {Key: "_id", Value: order}})
+	if search.Limit > 0 {
+		// One more than the page tells whether another page follows
+		opts.SetLimit(int64(search.Limit) + 1)
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cursor.Close(ctx)
+
+	var files []*domain.UserFile
+	if err := cursor.All(ctx, &files); err != nil {
+		return nil, "", err
+	}
+
+	if search.Limit <= 0 || len(files) <= search.Limit {
+		return files, "", nil
+	}
+	files = files[:search.Limit]
+	next, err := encodeSearchCursor(search, files[len(files)-1])
+	if err != nil {
+		return nil, "", err
+	}
+
+	return files, next, nil
+}
+
+func searchFilter(userID uint, search domain.FileSearch) bson.D {
+	filter := bson.D{{Key: "userId", Value: userID}, {Key: "deletedAt", Value: notTrashed}, {Key: "expiresAt", Value: notExpired()}}
+
+	if search.Name != "" {
+		filter = append(filter, bson.E{Key: "filename", Value: nameRegex(search.Name)})
+	}
+
+	if family, ok := strings.CutSuffix(search.ContentType, "/*"); ok {
+		filter = append(filter, bson.E{Key: "contentType", Value: primitive.Regex{Pattern: "^" + regexp.QuoteMeta(family+"/")}})
+	} else if search.ContentType != "" {
+		filter = append(filter, bson.E{Key: "contentType", Value: search.ContentType})
+	}
+
+	size := bson.M{}
+	if search.MinSize != nil {
+		size["$gte"] = *search.MinSize
+	}
+	if search.MaxSize != nil {
+		size["$lte"] = *search.MaxSize
+	}
+	if len(size) > 0 {
+		filter = append(filter, bson.E{Key: "size", Value: size})
+	}
+
+	uploaded := bson.M{}
+	if search.UploadedAfter != nil {
+		uploaded["$gte"] = *search.UploadedAfter
+	}
+	if search.UploadedBefore != nil {
+		uploaded["$lt"] = *search.UploadedBefore
+	}
+	if len(uploaded) > 0 {
+		filter = append(filter, bson.E{Key: "uploadedAt", Value: uploaded})
+	}
+
+	if len(search.Tags.Tags) > 0 {
+		op := "$in"
+		if search.Tags.All {
+			op = "$all"
+		}
+		filter = append(filter, bson.E{Key: "tags", Value: bson.M{op: search.Tags.Tags}})
+	}
+
+	return filter
+}
+
+// nameRegex matches filenames containing name, or matching it as a glob when
+// it has wildcards. Both ignore case.
+func nameRegex(name string) primitive.Regex {
+	if !strings.ContainsAny(name, "*?") {
+		return primitive.Regex{Pattern: regexp.QuoteMeta(name), Options: "i"}
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for _, r := range name {
+		switch r {
+		case '*':
+			pattern.WriteString(".*")
+		case '?':
+			pattern.WriteString(".")
+		default:
+			pattern.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	pattern.WriteString("$")
+
+	return primitive.Regex{Pattern: pattern.String(), Options: "is"}
+}
+
+// searchCursor is the position after the last file of a page. It records the
+// order it was made for, so it can't be reused with another one.
+type searchCursor struct {
+	Sort  domain.FileSort `json:"s"`
+	Desc  bool            `json:"d,omitempty"`
+	Value json.RawMessage `json:"v"`
+	ID    string          `json:"id"`
+}
+
+type cursorPosition struct {
+	value interface{}
+	id    primitive.ObjectID
+}
+
+func encodeSearchCursor(search domain.FileSearch, last *domain.UserFile) (string, error) {
+	var value interface{}
+	switch search.Sort {
+	case domain.FileSortName:
+		value = last.Filename
+	case domain.FileSortSize:
+		value = last.Size
+	case domain.FileSortUploadedAt:
+		value = last.UploadedAt
+	case domain.FileSortContentType:
+		value = last.ContentType
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	cursor, err := json.Marshal(searchCursor{Sort: search.Sort, Desc: search.Desc, Value: raw, ID: last.ID})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(cursor), nil
+}
+
+func decodeSearchCursor(search domain.FileSearch) (*cursorPosition, error) {
+	data, err := base64.RawURLEncoding.DecodeString(search.Cursor)
+	if err != nil {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	var cursor searchCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != search.Sort || cursor.Desc != search.Desc {
+		return nil, domain.ErrInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(cursor.ID)
+	if err != nil {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	var value interface{}
+	switch search.Sort {
+	case domain.FileSortSize:
+		var size int64
+		err = json.Unmarshal(cursor.Value, &size)
+		value = size
+	case domain.FileSortUploadedAt:
+		var uploadedAt time.Time
+		err = json.Unmarshal(cursor.Value, &uploadedAt)
+		value = uploadedAt
+	default:
+		var s string
+		err = json.Unmarshal(cursor.Value, &s)
+		value = s
+	}
+	if err != nil {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	return &cursorPosition{value: value, id: id}, nil
+}
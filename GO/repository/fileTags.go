@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SetFileTags replaces the tags of file.
+func (r *fileRepository) SetFileTags(ctx context.Context, file *domain.UserFile, tags []string) error {
+	objID, err := primitive.ObjectIDFromHex(file.ID)
+	if err != nil {
+		return domain.ErrFileNotFound
+	}
+
+	update := bson.M{"$set": bson.M{"tags": tags}}
+	if len(tags) == 0 {
+		update = bson.M{"$unset": bson.M{"tags": ""}}
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objID, "deletedAt": notTrashed}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrFileNotFound
+	}
+
+	file.Tags = tags
+	return nil
+}
+
+// GetTagCounts counts the files of the user carrying each tag, most used
+// first. Files in the trash don't count.
+func (r *fileRepository) GetTagCounts(ctx context.Context, userID uint) ([]*domain.TagCount, error) {
+	cursor, err := r.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"userId": userID, "deletedAt": notTrashed, "tags": bson.M{"$exists": true}}}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	counts := []*domain.TagCount{}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// RenameTags replaces the tags in from with to on every file of the user,
+// including those in the trash, and returns how many files changed.
+func (r *fileRepository) RenameTags(ctx context.Context, userID uint, from []string, to string) (int, error) {
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"userId": userID, "tags": bson.M{"$in": from}},
+		// Tags stay sorted like SetFileTags stores them
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"tags": bson.M{"$sortArray": bson.M{
+				"input": bson.M{"$setUnion": bson.A{
+					bson.M{"$setDifference": bson.A{"$tags", from}},
+					bson.A{to},
+				}},
+				"sortBy": 1,
+			}},
+		}}}},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(result.ModifiedCount), nil
+}
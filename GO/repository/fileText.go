@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// fileText is the searchable text of the current version of a file, kept
+// apart from user_files so file lookups never load it.
+type fileText struct {
+	FileID  string  `bson:"_id"`
+	UserID  uint    `bson:"userId"`
+	Version int     `bson:"version"`
+	Text    string  `bson:"text"`
+	Score   float64 `bson:"score,omitempty"`
+}
+
+// fileTextIndexes leaves userId out of the text index, as a compound text
+// index only serves queries for a single user and searches span the owners
+// who shared files with the caller.
+func fileTextIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{{
+		Keys: bson.D{{Key: "text", Value: "text"}},
+	}}
+}
+
+// SetFileText replaces the searchable text of file with the text of its
+// current version. Empty text removes the file from full-text search.
+func (r *fileRepository) SetFileText(ctx context.Context, file *domain.UserFile, text string) error {
+	if text == "" {
+		_, err := r.texts.DeleteOne(ctx, bson.M{"_id": file.ID})
+		return err
+	}
+
+	_, err := r.texts.ReplaceOne(ctx,
+		bson.M{"_id": file.ID},
+		fileText{FileID: file.ID, UserID: file.UserID, Version: file.Version, Text: text},
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// GetFileText returns the searchable text of a file.
+func (r *fileRepository) GetFileText(ctx context.Context, fileID string) (string, error) {
+	var text fileText
+	err := r.texts.FindOne(ctx, bson.M{"_id": fileID}).Decode(&text)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", domain.ErrFileNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return text.Text, nil
+}
+
+// SearchFileText returns up to limit files of the given users whose text
+// matches query, best match first. query uses the MongoDB text search syntax:
+// words match any of them, "quoted phrases" must appear and -words must not.
+func (r *fileRepository) SearchFileText(ctx context.Context, query string, userIDs []uint, limit int) ([]*domain.FileTextMatch, error) {
+	score := bson.M{"$meta": "textScore"}
+	cursor, err := r.texts.Find(ctx,
+		bson.M{"userId": bson.M{"$in": userIDs}, "$text": bson.M{"$search": query}},
+		options.Find().
+			SetProjection(bson.M{"text": 0, "score": score}).
+			SetSort(bson.D{{Key: "score", Value: score}}).
+			SetLimit(int64(limit)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var texts []fileText
+	if err := cursor.All(ctx, &texts); err != nil {
+		return nil, err
+	}
+
+	matches := make([]*domain.FileTextMatch, 0, len(texts))
+	for _, t := range texts {
+		matches = append(matches, &domain.FileTextMatch{FileID: t.FileID, Version: t.Version, Score: t.Score})
+	}
+
+	return matches, nil
+}
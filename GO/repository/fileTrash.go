@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TrashFile moves file to the trash. Its content, thumbnails and grants are
+// kept until it is deleted for good.
+func (r *fileRepository) TrashFile(ctx context.Context, file *domain.UserFile, deletedAt time.Time) error {
+	objID, err := primitive.ObjectIDFromHex(file.ID)
+	if err != nil {
+		return domain.ErrFileNotFound
+	}
+
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": objID, "deletedAt": notTrashed},
+		bson.M{"$set": bson.M{"deletedAt": deletedAt}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrFileNotFound
+	}
+
+	file.DeletedAt = &deletedAt
+	return nil
+}
+
+// TrashFilesByUserID moves every file of the user to the trash.
+func (r *fileRepository) TrashFilesByUserID(ctx context.Context, userID uint, deletedAt time.Time) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"userId": userID, "deletedAt": notTrashed},
+		bson.M{"$set": bson.M{"deletedAt": deletedAt}},
+	)
+	return err
+}
+
+// GetTrashedFile returns the file with the given ID if it is in the trash.
+func (r *fileRepository) GetTrashedFile(ctx context.Context, id string) (*domain.UserFile, error) {
+	return r.getFile(ctx, id, bson.M{"deletedAt": bson.M{"$exists": true}})
+}
+
+// GetTrashedFiles returns the files of the user in the trash, most recently
+// deleted first.
+func (r *fileRepository) GetTrashedFiles(ctx context.Context, userID uint) ([]*domain.UserFile, error) {
+	return r.findTrash(ctx,
+		bson.M{"userId": userID, "deletedAt": bson.M{"$exists": true}},
+		options.Find().SetSort(bson.D{{Key: "deletedAt", Value: -1}, {Key: "filename", Value: 1}}),
+	)
+}
+
+// GetExpiredTrash returns up to limit files that were moved to the trash
+// before the given time, longest in the trash first.
+func (r *fileRepository) GetExpiredTrash(ctx context.Context, before time.Time, limit int) ([]*domain.UserFile, error) {
+	return r.findTrash(ctx,
+		bson.M{"deletedAt": bson.M{"$lt": before}},
+		options.Find().SetSort(bson.D{{Key: "deletedAt", Value: 1}}).SetLimit(int64(limit)),
+	)
+}
+
+// RestoreFile takes file out of the trash into folderID, empty for the root.
+// A file with the same name that was stored there meanwhile wins and
+// ErrFileExists is returned. Files that have expired stay in the trash.
+func (r *fileRepository) RestoreFile(ctx context.Context, file *domain.UserFile, folderID string) error {
+	objID, err := primitive.ObjectIDFromHex(file.ID)
+	if err != nil {
+		return domain.ErrFileNotFound
+	}
+
+	if err := r.retireExpired(ctx, file.UserID, folderID, file.Filename); err != nil {
+		return err
+	}
+
+	unset := bson.M{"deletedAt": ""}
+	update := bson.M{"$unset": unset}
+	if folderID == "" {
+		unset["folderId"] = ""
+	} else {
+		update["$set"] = bson.M{"folderId": folderID}
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objID, "deletedAt": bson.M{"$exists": true}, "expiresAt": notExpired()}, update)
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrFileExists
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		// The file may have expired since it was read
+		return domain.ErrFileNotFound
+	}
+
+	file.FolderID = folderID
+	file.DeletedAt = nil
+	return nil
+}
+
+func (r *fileRepository) findTrash(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]*domain.UserFile, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var files []*domain.UserFile
+	if err := cursor.All(ctx, &files); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
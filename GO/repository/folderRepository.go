@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type FolderRepository interface {
+	CreateFolder(ctx context.Context, folder *domain.Folder) error
+	GetFolderByID(ctx context.Context, id string) (*domain.Folder, error)
+	GetFolderByName(ctx context.Context, userID uint, parentID, name string) (*domain.Folder, error)
+	GetFoldersByParent(ctx context.Context, userID uint, parentID string) ([]*domain.Folder, error)
+	GetFoldersByUserID(ctx context.Context, userID uint) ([]*domain.Folder, error)
+	UpdateFolder(ctx context.Context, folder *domain.Folder) error
+	DeleteFolders(ctx context.Context, ids []string) error
+}
+
+type folderRepository struct {
+	collection *mongo.Collection
+}
+
+func NewFolderRepository(db *mongo.Database) FolderRepository {
+	collection := db.Collection("user_folders")
+
+	// Root folders have no parentId, which the index treats as null, so they are unique too
+	_, err := collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "parentId", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create folder index: %v", err)
+	}
+
+	return &folderRepository{
+		collection: collection,
+	}
+}
+
+// inFolder matches documents directly inside folderID. Documents at the root
+// have no folder field at all.
+func inFolder(folderID string) interface{} {
+	if folderID == "" {
+		return nil
+	}
+	return folderID
+}
+
+func (r *folderRepository) CreateFolder(ctx context.Context, folder *domain.Folder) error {
+	result, err := r.collection.InsertOne(ctx, folder)
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrFolderExists
+	}
+	if err != nil {
+		return err
+	}
+
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		folder.ID = id.Hex()
+	}
+	return nil
+}
+
+func (r *folderRepository) GetFolderByID(ctx context.Context, id string) (*domain.Folder, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrFolderNotFound
+	}
+
+	return r.findFolder(ctx, bson.M{"_id": objID})
+}
+
+func (r *folderRepository) GetFolderByName(ctx context.Context, userID uint, parentID, name string) (*domain.Folder, error) {
+	return r.findFolder(ctx, bson.M{"userId": userID, "parentId": inFolder(parentID), "name": name})
+}
+
+func (r *folderRepository) GetFoldersByParent(ctx context.Context, userID uint, parentID string) ([]*domain.Folder, error) {
+	return r.findFolders(ctx, bson.M{"userId": userID, "parentId": inFolder(parentID)})
+}
+
+func (r *folderRepository) GetFoldersByUserID(ctx context.Context, userID uint) ([]*domain.Folder, error) {
+	return r.findFolders(ctx, bson.M{"userId": userID})
+}
+
+// UpdateFolder saves the name and parent of folder.
+func (r *folderRepository) UpdateFolder(ctx context.Context, folder *domain.Folder) error {
+	objID, err := primitive.ObjectIDFromHex(folder.ID)
+	if err != nil {
+		return domain.ErrFolderNotFound
+	}
+
+	update := bson.M{"$set": bson.M{"name": folder.Name, "parentId": folder.ParentID}}
+	if folder.ParentID == "" {
+		update = bson.M{"$set": bson.M{"name": folder.Name}, "$unset": bson.M{"parentId": ""}}
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrFolderExists
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrFolderNotFound
+	}
+
+	return nil
+}
+
+func (r *folderRepository) DeleteFolders(ctx context.Context, ids []string) error {
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return domain.ErrFolderNotFound
+		}
+		objIDs = append(objIDs, objID)
+	}
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": objIDs}})
+	return err
+}
+
+func (r *folderRepository) findFolder(ctx context.Context, filter bson.M) (*domain.Folder, error) {
+	var folder domain.Folder
+	err := r.collection.FindOne(ctx, filter).Decode(&folder)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrFolderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &folder, nil
+}
+
+func (r *folderRepository) findFolders(ctx context.Context, filter bson.M) ([]*domain.Folder, error) {
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var folders []*domain.Folder
+	if err := cursor.All(ctx, &folders); err != nil {
+		return nil, err
+	}
+
+	return folders, nil
+}
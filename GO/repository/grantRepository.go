@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"log"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type GrantRepository interface {
+	SetGrant(ctx context.Context, grant *domain.Grant) error
+	GetGrants(ctx context.Context, resource domain.Resource) ([]*domain.Grant, error)
+	GetUserGrants(ctx context.Context, userID uint, resources []domain.Resource) ([]*domain.Grant, error)
+	GetGrantsByUserID(ctx context.Context, userID uint) ([]*domain.Grant, error)
+	DeleteGrant(ctx context.Context, resource domain.Resource, userID uint) error
+	DeleteResourceGrants(ctx context.Context, resources []domain.Resource) error
+}
+
+type grantRepository struct {
+	collection *mongo.Collection
+}
+
+func NewGrantRepository(db *mongo.Database) GrantRepository {
+	collection := db.Collection("file_grants")
+
+	_, err := collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "resource.type", Value: 1}, {Key: "resource.id", Value: 1}, {Key: "userId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create grant index: %v", err)
+	}
+
+	return &grantRepository{
+		collection: collection,
+	}
+}
+
+// SetGrant creates the grant or changes the permission of an existing grant
+// for the same resource and user. On return grant holds the stored grant.
+func (r *grantRepository) SetGrant(ctx context.Context, grant *domain.Grant) error {
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"resource.type": grant.Resource.Type, "resource.id": grant.Resource.ID, "userId": grant.UserID},
+		bson.M{
+			"$set":         bson.M{"permission": grant.Permission, "ownerId": grant.OwnerID},
+			"$setOnInsert": bson.M{"resource": grant.Resource, "createdAt": grant.CreatedAt},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(grant)
+	return err
+}
+
+func (r *grantRepository) GetGrants(ctx context.Context, resource domain.Resource) ([]*domain.Grant, error) {
+	return r.findGrants(ctx, bson.M{"resource.type": resource.Type, "resource.id": resource.ID})
+}
+
+// GetUserGrants returns the grants the user holds on any of resources.
+func (r *grantRepository) GetUserGrants(ctx context.Context, userID uint, resources []domain.Resource) ([]*domain.Grant, error) {
+	if len(resources) == 0 {
+		return nil, nil
+	}
+
+	return r.findGrants(ctx, bson.M{"userId": userID, "$or": resourceFilters(resources)})
+}
+
+func (r *grantRepository) GetGrantsByUserID(ctx context.Context, userID uint) ([]*domain.Grant, error) {
+	return r.findGrants(ctx, bson.M{"userId": userID})
+}
+
+func (r *grantRepository) DeleteGrant(ctx context.Context, resource domain.Resource, userID uint) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"resource.type": resource.Type, "resource.id": resource.ID, "userId": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return domain.ErrGrantNotFound
+	}
+
+	return nil
+}
+
+// DeleteResourceGrants removes every grant on resources, used once they are deleted.
+func (r *grantRepository) DeleteResourceGrants(ctx context.Context, resources []domain.Resource) error {
+	if len(resources) == 0 {
+		return nil
+	}
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"$or": resourceFilters(resources)})
+	return err
+}
+
+func (r *grantRepository) findGrants(ctx context.Context, filter bson.M) ([]*domain.Grant, error) {
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var grants []*domain.Grant
+	if err := cursor.All(ctx, &grants); err != nil {
+		return nil, err
+	}
+
+	return grants, nil
+}
+
+func resourceFilters(resources []domain.Resource) bson.A {
+	filters := bson.A{}
+	for _, resource := range resources {
+		filters = append(filters, bson.M{"resource.type": resource.Type, "resource.id": resource.ID})
+	}
+	return filters
+}
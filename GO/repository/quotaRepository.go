@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type QuotaRepository interface {
+	GetUsage(ctx context.Context, userID uint) (*domain.StorageUsage, error)
+	ReserveUsage(ctx context.Context, userID uint, bytes int64, files int, quota domain.StorageQuota) error
+	AddUsage(ctx context.Context, userID uint, bytes int64, files int) error
+	SetQuota(ctx context.Context, userID uint, quota *domain.StorageQuota) error
+}
+
+type quotaRepository struct {
+	collection *mongo.Collection
+}
+
+func NewQuotaRepository(db *mongo.Database) QuotaRepository {
+	return &quotaRepository{
+		collection: db.Collection("user_storage"),
+	}
+}
+
+// GetUsage returns the user's usage, which is zero when nothing was stored yet.
+func (r *quotaRepository) GetUsage(ctx context.Context, userID uint) (*domain.StorageUsage, error) {
+	var usage domain.StorageUsage
+	err := r.collection.FindOne(ctx, bson.M{"_id": userID}).Decode(&usage)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &domain.StorageUsage{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+// ReserveUsage adds bytes and files to the user's usage in a single update
+// that only matches while the new totals stay within quota, so concurrent
+// uploads can't overshoot it together.
+func (r *quotaRepository) ReserveUsage(ctx context.Context, userID uint, bytes int64, files int, quota domain.StorageQuota) error {
+	if err := r.ensureUsage(ctx, userID); err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": userID}
+	if quota.MaxBytes > 0 {
+		filter["bytes"] = bson.M{"$lte": quota.MaxBytes - bytes}
+	}
+	if quota.MaxFiles > 0 && files > 0 {
+		filter["files"] = bson.M{"$lte": quota.MaxFiles - files}
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"bytes": bytes, "files": files}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrQuotaExceeded
+	}
+
+	return nil
+}
+
+// AddUsage changes the user's usage without checking the quota. Negative
+// values release usage.
+func (r *quotaRepository) AddUsage(ctx context.Context, userID uint, bytes int64, files int) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$inc": bson.M{"bytes": bytes, "files": files}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// SetQuota overrides the default quota for the user; nil removes the override.
+func (r *quotaRepository) SetQuota(ctx context.Context, userID uint, quota *domain.StorageQuota) error {
+	update := bson.M{"$setOnInsert": bson.M{"bytes": 0, "files": 0}}
+	if quota != nil {
+		update["$set"] = bson.M{"quota": quota}
+	} else {
+		update["$unset"] = bson.M{"quota": ""}
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": userID}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *quotaRepository) ensureUsage(ctx context.Context, userID uint) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$setOnInsert": bson.M{"bytes": 0, "files": 0}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
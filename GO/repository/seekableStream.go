@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"errors"
+	"io"
+)
+
+// seekableStream adapts a forward-only content stream to io.ReadSeekCloser.
+// Seeking only records the new offset; the next Read skips ahead on the open
+// stream or reopens it when the offset moved backwards.
+type seekableStream struct {
+	open   func() (io.ReadCloser, error)
+	size   int64
+	offset int64
+	pos    int64
+	stream io.ReadCloser
+}
+
+func newSeekableStream(size int64, open func() (io.ReadCloser, error)) *seekableStream {
+	return &seekableStream{open: open, size: size}
+}
+
+func (s *seekableStream) Read(p []byte) (int, error) {
+	if s.offset >= s.size {
+		return 0, io.EOF
+	}
+
+	if s.stream != nil && s.offset < s.pos {
+		_ = s.stream.Close()
+		s.stream = nil
+	}
+	if s.stream == nil {
+		stream, err := s.open()
+		if err != nil {
+			return 0, err
+		}
+		s.stream = stream
+		s.pos = 0
+	}
+	if s.offset > s.pos {
+		if err := s.skip(s.offset - s.pos); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := s.stream.Read(p)
+	s.pos += int64(n)
+	s.offset += int64(n)
+	return n, err
+}
+
+func (s *seekableStream) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	s.offset = offset
+	return offset, nil
+}
+
+func (s *seekableStream) Close() error {
+	if s.stream == nil {
+		return nil
+	}
+	err := s.stream.Close()
+	s.stream = nil
+	return err
+}
+
+func (s *seekableStream) skip(n int64) error {
+	// GridFS streams can skip whole chunks without copying them
+	if skipper, ok := s.stream.(interface{ Skip(int64) (int64, error) }); ok {
+		skipped, err := skipper.Skip(n)
+		s.pos += skipped
+		return err
+	}
+
+	skipped, err := io.CopyN(io.Discard, s.stream, n)
+	s.pos += skipped
+	return err
+}
+
+type nopSeekCloser struct {
+	io.ReadSeeker
+}
+
+func (nopSeekCloser) Close() error {
+	return nil
+}
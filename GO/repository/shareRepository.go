@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ShareRepository interface {
+	CreateShare(ctx context.Context, link *domain.ShareLink) error
+	GetShareByID(ctx context.Context, id string) (*domain.ShareLink, error)
+	GetShareByTokenHash(ctx context.Context, tokenHash string) (*domain.ShareLink, error)
+	GetSharesByFileID(ctx context.Context, fileID string) ([]*domain.ShareLink, error)
+	GetSharesByUserID(ctx context.Context, userID uint) ([]*domain.ShareLink, error)
+	CountDownload(ctx context.Context, link *domain.ShareLink) error
+	DeleteShare(ctx context.Context, id string) error
+}
+
+type shareRepository struct {
+	collection *mongo.Collection
+}
+
+func NewShareRepository(db *mongo.Database) ShareRepository {
+	collection := db.Collection("file_shares")
+
+	_, err := collection.Indexes().CreateOne(context.Background(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create share index: %v", err)
+	}
+
+	return &shareRepository{
+		collection: collection,
+	}
+}
+
+func (r *shareRepository) CreateShare(ctx context.Context, link *domain.ShareLink) error {
+	result, err := r.collection.InsertOne(ctx, link)
+	if err != nil {
+		return err
+	}
+
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		link.ID = id.Hex()
+	}
+	link.HasPassword = link.PasswordHash != ""
+	return nil
+}
+
+func (r *shareRepository) GetShareByID(ctx context.Context, id string) (*domain.ShareLink, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrShareNotFound
+	}
+
+	return r.findShare(ctx, bson.M{"_id": objID})
+}
+
+func (r *shareRepository) GetShareByTokenHash(ctx context.Context, tokenHash string) (*domain.ShareLink, error) {
+	return r.findShare(ctx, bson.M{"tokenHash": tokenHash})
+}
+
+func (r *shareRepository) GetSharesByFileID(ctx context.Context, fileID string) ([]*domain.ShareLink, error) {
+	return r.findShares(ctx, bson.M{"fileId": fileID})
+}
+
+func (r *shareRepository) GetSharesByUserID(ctx context.Context, userID uint) ([]*domain.ShareLink, error) {
+	return r.findShares(ctx, bson.M{"userId": userID})
+}
+
+// CountDownload records one download of link, unless its download limit
+// has been reached in the meantime.
+func (r *shareRepository) CountDownload(ctx context.Context, link *domain.ShareLink) error {
+	objID, err := primitive.ObjectIDFromHex(link.ID)
+	if err != nil {
+		return domain.ErrShareNotFound
+	}
+
+	filter := bson.M{"_id": objID}
+	if link.MaxDownloads > 0 {
+		filter["downloads"] = bson.M{"$lt": link.MaxDownloads}
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"downloads": 1}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrShareLimitReached
+	}
+
+	link.Downloads++
+	return nil
+}
+
+func (r *shareRepository) DeleteShare(ctx context.Context, id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return domain.ErrShareNotFound
+	}
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return domain.ErrShareNotFound
+	}
+
+	return nil
+}
+
+func (r *shareRepository) findShare(ctx context.Context, filter bson.M) (*domain.ShareLink, error) {
+	var link domain.ShareLink
+	err := r.collection.FindOne(ctx, filter).Decode(&link)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrShareNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	link.HasPassword = link.PasswordHash != ""
+	return &link, nil
+}
+
+func (r *shareRepository) findShares(ctx context.Context, filter bson.M) ([]*domain.ShareLink, error) {
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var links []*domain.ShareLink
+	if err := cursor.All(ctx, &links); err != nil {
+		return nil, err
+	}
+
+	for _, link := range links {
+		link.HasPassword = link.PasswordHash != ""
+	}
+	return links, nil
+}
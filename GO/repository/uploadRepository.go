@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type UploadRepository interface {
+	CreateUpload(ctx context.Context, upload *domain.FileUpload) error
+	GetUploadByID(ctx context.Context, id string) (*domain.FileUpload, error)
+	AppendChunk(ctx context.Context, upload *domain.FileUpload, chunk io.Reader, expiresAt time.Time) (int64, error)
+	OpenUploadContent(ctx context.Context, upload *domain.FileUpload) (io.ReadCloser, error)
+	CompleteUpload(ctx context.Context, upload *domain.FileUpload, fileID string) error
+	DeleteUpload(ctx context.Context, upload *domain.FileUpload) error
+	GetExpiredUploads(ctx context.Context, now time.Time) ([]*domain.FileUpload, error)
+}
+
+type uploadRepository struct {
+	collection *mongo.Collection
+	storage    *BlobStorage
+}
+
+// NewUploadRepository keeps received chunks in storage until the upload
+// completes.
+func NewUploadRepository(db *mongo.Database, storage *BlobStorage) UploadRepository {
+	return &uploadRepository{
+		collection: db.Collection("file_uploads"),
+		storage:    storage,
+	}
+}
+
+func (r *uploadRepository) CreateUpload(ctx context.Context, upload *domain.FileUpload) error {
+	result, err := r.collection.InsertOne(ctx, upload)
+	if err != nil {
+		return err
+	}
+
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		upload.ID = id.Hex()
+	}
+	return nil
+}
+
+func (r *uploadRepository) GetUploadByID(ctx context.Context, id string) (*domain.FileUpload, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, domain.ErrUploadNotFound
+	}
+
+	var upload domain.FileUpload
+	err = r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&upload)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &upload, nil
+}
+
+// AppendChunk stores chunk as the next part of the upload. Bytes received
+// before the chunk reader fails are kept, so the client can resume from the
+// new offset; the read error is returned alongside the number of bytes kept.
+func (r *uploadRepository) AppendChunk(ctx context.Context, upload *domain.FileUpload, chunk io.Reader, expiresAt time.Time) (int64, error) {
+	objID, err := primitive.ObjectIDFromHex(upload.ID)
+	if err != nil {
+		return 0, domain.ErrUploadNotFound
+	}
+
+	blob, err := r.storage.create(ctx, uploadBucketName)
+	if err != nil {
+		return 0, err
+	}
+
+	size, copyErr := io.Copy(blob, chunk)
+	if size == 0 {
+		_ = blob.Abort()
+		return 0, copyErr
+	}
+	if err := blob.Close(); err != nil {
+		return 0, err
+	}
+	part := domain.FileUploadPart{BlobID: blob.ref.id, Store: blob.ref.store, Size: size}
+
+	// Record the part even if the client went away mid-chunk
+	saveCtx := context.WithoutCancel(ctx)
+	result, err := r.collection.UpdateOne(saveCtx, bson.M{"_id": objID, "offset": upload.Offset}, bson.M{
+		"$inc":  bson.M{"offset": size},
+		"$push": bson.M{"parts": part},
+		"$set":  bson.M{"expiresAt": expiresAt},
+	})
+	if err == nil && result.MatchedCount == 0 {
+		err = domain.ErrUploadOffsetMismatch
+	}
+	if err != nil {
+		_ = r.storage.delete(saveCtx, blob.ref)
+		return 0, err
+	}
+
+	upload.Offset += size
+	upload.Parts = append(upload.Parts, part)
+	upload.ExpiresAt = expiresAt
+	return size, copyErr
+}
+
+func (r *uploadRepository) OpenUploadContent(ctx context.Context, upload *domain.FileUpload) (io.ReadCloser, error) {
+	return &partsReader{ctx: ctx, storage: r.storage, parts: upload.Parts}, nil
+}
+
+func (r *uploadRepository) CompleteUpload(ctx context.Context, upload *domain.FileUpload, fileID string) error {
+	objID, err := primitive.ObjectIDFromHex(upload.ID)
+	if err != nil {
+		return domain.ErrUploadNotFound
+	}
+
+	if err := r.deleteParts(ctx, upload.Parts); err != nil {
+		return err
+	}
+
+	_, err = r.collection.UpdateByID(ctx, objID, bson.M{
+		"$set": bson.M{"fileId": fileID, "parts": []domain.FileUploadPart{}},
+	})
+	if err != nil {
+		return err
+	}
+
+	upload.FileID = fileID
+	upload.Parts = nil
+	return nil
+}
+
+func (r *uploadRepository) DeleteUpload(ctx context.Context, upload *domain.FileUpload) error {
+	objID, err := primitive.ObjectIDFromHex(upload.ID)
+	if err != nil {
+		return domain.ErrUploadNotFound
+	}
+
+	if err := r.deleteParts(ctx, upload.Parts); err != nil {
+		return err
+	}
+
+	_, err = r.collection.DeleteOne(ctx, bson.M{"_id": objID})
+	return err
+}
+
+func (r *uploadRepository) GetExpiredUploads(ctx context.Context, now time.Time) ([]*domain.FileUpload, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"expiresAt": bson.M{"$lt": now}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var uploads []*domain.FileUpload
+	for cursor.Next(ctx) {
+		var u domain.FileUpload
+		if err := cursor.Decode(&u); err != nil {
+			continue
+		}
+		uploads = append(uploads, &u)
+	}
+
+	return uploads, cursor.Err()
+}
+
+func (r *uploadRepository) deleteParts(ctx context.Context, parts []domain.FileUploadPart) error {
+	for _, part := range parts {
+		if err := r.storage.delete(ctx, partRef(part)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func partRef(part domain.FileUploadPart) blobRef {
+	return blobRef{store: part.Store, bucket: uploadBucketName, id: part.BlobID}
+}
+
+// partsReader reads the parts of an upload back to back, opening each
+// blob only when the previous one is exhausted.
+type partsReader struct {
+	ctx     context.Context
+	storage *BlobStorage
+	parts   []domain.FileUploadPart
+	current io.ReadCloser
+}
+
+func (p *partsReader) Read(b []byte) (int, error) {
+	for {
+		if p.current == nil {
+			if len(p.parts) == 0 {
+				return 0, io.EOF
+			}
+			stream, err := p.storage.open(p.ctx, partRef(p.parts[0]))
+			if err != nil {
+				return 0, err
+			}
+			p.current = stream
+			p.parts = p.parts[1:]
+		}
+
+		n, err := p.current.Read(b)
+		if err == io.EOF {
+			_ = p.current.Close()
+			p.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (p *partsReader) Close() error {
+	if p.current != nil {
+		return p.current.Close()
+	}
+	return nil
+}
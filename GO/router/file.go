@@ -1,36 +1,165 @@
 package router
 
 import (
+	"log"
+	"strings"
 	"time"
 
 	"github.com/OgiDac/CompanyTask/api/controllers"
+	"github.com/OgiDac/CompanyTask/blobstore"
 	"github.com/OgiDac/CompanyTask/config"
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/encryption"
 	"github.com/OgiDac/CompanyTask/repository"
+	"github.com/OgiDac/CompanyTask/scanner"
 	"github.com/OgiDac/CompanyTask/usecase"
+	"github.com/OgiDac/CompanyTask/utils"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/mongo"
 	"gorm.io/gorm"
 )
 
-func NewFileRouter(env *config.Env, timeout time.Duration, db *gorm.DB, mongoDB *mongo.Database, public *gin.RouterGroup) {
+const (
+	thumbnailInterval = 10 * time.Second
+	scanInterval      = time.Minute
+	trashInterval     = time.Hour
+	expiryInterval    = time.Minute
+	scrubInterval     = 10 * time.Minute
+	// defaultClamdTimeout bounds a single scan unless CLAMD_TIMEOUT is set
+	defaultClamdTimeout = 5 * time.Minute
+	// defaultUploadMaxParts bounds the files of one upload request unless FILE_UPLOAD_MAX_PARTS is set
+	defaultUploadMaxParts = 20
+)
+
+func NewFileRouter(env *config.Env, timeout time.Duration, db *gorm.DB, mongoDB *mongo.Database, userUseCase domain.UserUseCase, public *gin.RouterGroup, private *gin.RouterGroup, root *gin.RouterGroup) {
 	// SQL User repo (to check user exists)
 	userRepo := repository.NewUserRepository(db)
 
+	// Stored content is encrypted once a master key is configured
+	keys, err := encryption.LoadKeyring(env.FileMasterKey, env.FileMasterKeyFile, env.FilePreviousMasterKeys)
+	if err != nil {
+		log.Fatalf("Failed to load file encryption keys: %v", err)
+	}
+
+	// File bytes go to the backend FILE_STORAGE selects; metadata stays in Mongo
+	current, stores, err := blobstore.FromEnv(env, mongoDB)
+	if err != nil {
+		log.Fatalf("Failed to set up file storage: %v", err)
+	}
+	storage := repository.NewBlobStorage(mongoDB, current, stores, keys)
+
 	// Mongo File repo
-	fileRepo := repository.NewFileRepository(mongoDB)
+	fileRepo := repository.NewFileRepository(mongoDB, storage)
+	folderRepo := repository.NewFolderRepository(mongoDB)
+	quotaRepo := repository.NewQuotaRepository(mongoDB)
+	grantRepo := repository.NewGrantRepository(mongoDB)
+
+	// Every upload is scanned before it can be downloaded
+	fileScanner := newScanner(env)
 
-	// Usecase with both
-	fileUseCase := usecase.NewFileUseCase(userRepo, fileRepo, timeout)
+	// Quota overrides are reserved for the users in FILE_QUOTA_ADMINS
+	quotaAdmins, err := utils.ParseUserIDs(env.FileQuotaAdmins)
+	if err != nil {
+		log.Fatalf("Failed to parse FILE_QUOTA_ADMINS: %v", err)
+	}
+
+	// Usecase with all of them
+	fileUseCase := usecase.NewFileUseCase(userRepo, fileRepo, folderRepo, quotaRepo, grantRepo, fileScanner, quotaAdmins, timeout, env)
+	accessUseCase := usecase.NewAccessUseCase(userRepo, fileRepo, folderRepo, grantRepo, timeout)
 
 	// Controller
+	maxUploadParts := env.FileUploadMaxParts
+	if maxUploadParts <= 0 {
+		maxUploadParts = defaultUploadMaxParts
+	}
 	fileController := &controllers.FileController{
-		FileUseCase: fileUseCase,
+		FileUseCase:    fileUseCase,
+		MaxUploadParts: maxUploadParts,
+	}
+	accessController := &controllers.AccessController{
+		AccessUseCase: accessUseCase,
 	}
 
-	publicGroup := public.Group("/files")
+	// Every file operation acts on behalf of the authenticated user
+	privateGroup := private.Group("/files")
 	// Route
-	publicGroup.POST("/:id/", fileController.UploadFile)
-	publicGroup.GET("/:id/", fileController.DownloadFile)
-	publicGroup.GET("/user/:id", fileController.GetFilesByUser)
-	publicGroup.DELETE("/user/:id", fileController.DeleteFilesByUser)
+	privateGroup.GET("/shared", accessController.GetSharedWithMe)
+	privateGroup.GET("/search", fileController.SearchContent)
+	privateGroup.POST("/:id/", fileController.UploadFile)
+	privateGroup.GET("/:id/", fileController.DownloadFile)
+	privateGroup.HEAD("/:id/", fileController.DownloadFile)
+	privateGroup.PATCH("/:id/", fileController.UpdateFile)
+	privateGroup.DELETE("/:id/", fileController.DeleteFile)
+	privateGroup.GET("/user/:id", fileController.GetFilesByUser)
+	privateGroup.GET("/user/:id/search", fileController.SearchFiles)
+	privateGroup.GET("/user/:id/tags", fileController.GetTags)
+	privateGroup.POST("/user/:id/tags/rename", fileController.RenameTags)
+	privateGroup.DELETE("/user/:id", fileController.DeleteFilesByUser)
+	privateGroup.GET("/user/:id/trash", fileController.GetTrash)
+	privateGroup.DELETE("/user/:id/trash", fileController.EmptyTrash)
+	privateGroup.POST("/:id/restore", fileController.RestoreFile)
+	privateGroup.GET("/:id/versions", fileController.GetFileVersions)
+	privateGroup.GET("/:id/versions/:version", fileController.DownloadFileVersion)
+	privateGroup.POST("/:id/versions/:version/restore", fileController.RestoreFileVersion)
+	privateGroup.GET("/:id/thumbnail", fileController.GetThumbnail)
+	privateGroup.PUT("/:id/tags", fileController.SetFileTags)
+	privateGroup.PUT("/:id/expiry", fileController.SetFileExpiry)
+	privateGroup.POST("/:id/grants", accessController.GrantFileAccess)
+	privateGroup.GET("/:id/grants", accessController.GetFileGrants)
+	privateGroup.DELETE("/:id/grants/:userId", accessController.RevokeFileAccess)
+	privateGroup.POST("/user/:id/versions/prune", fileController.PruneFileVersions)
+	privateGroup.GET("/user/:id/usage", fileController.GetStorageUsage)
+	privateGroup.GET("/user/:id/resolve", fileController.ResolvePath)
+	privateGroup.HEAD("/user/:id/blobs/:digest", fileController.HasContent)
+	privateGroup.POST("/user/:id/blobs/:digest", fileController.UploadKnownContent)
+	privateGroup.GET("/user/:id/archive", fileController.DownloadArchive)
+	privateGroup.PUT("/user/:id/quota", fileController.SetStorageQuota)
+	privateGroup.DELETE("/user/:id/quota", fileController.ResetStorageQuota)
+
+	// Thumbnails of uploaded images are generated off the request path
+	schedule("generated thumbnails", thumbnailInterval, fileUseCase.GenerateThumbnails)
+
+	// Uploads the scanner couldn't be reached for are retried
+	schedule("scanned pending files", scanInterval, fileUseCase.ScanPendingFiles)
+
+	// Files kept in the trash past FILE_TRASH_RETENTION_DAYS are deleted for good
+	schedule("purged trashed files", trashInterval, fileUseCase.PurgeTrash)
+
+	// Expired files are hidden right away and deleted for good here
+	schedule("deleted expired files", expiryInterval, fileUseCase.ExpireFiles)
+
+	// Stored content is read back now and then so silent damage gets noticed
+	schedule("found corrupt content", scrubInterval, fileUseCase.ScrubContent)
+
+	// Folders next to the files group
+	NewFolderRouter(timeout, userRepo, folderRepo, fileRepo, grantRepo, fileUseCase, accessController, private)
+
+	// Share links for files; downloads are served outside the API groups
+	NewShareRouter(timeout, fileRepo, folderRepo, grantRepo, mongoDB, private, root)
+
+	// Resumable uploads next to the files group
+	NewUploadRouter(env, timeout, userRepo, mongoDB, storage, fileUseCase, public, private)
+
+	// The same files mounted as a network drive
+	NewWebDAVRouter(env, timeout, userRepo, folderRepo, fileRepo, grantRepo, userUseCase, fileUseCase, root)
+}
+
+// newScanner connects to clamd at CLAMD_ADDRESS, given as tcp://host:port or
+// unix:///path/to/clamd.sock. Without an address uploads aren't scanned.
+func newScanner(env *config.Env) domain.Scanner {
+	if env.ClamdAddress == "" {
+		return scanner.NewNoopScanner()
+	}
+
+	network, address, ok := strings.Cut(env.ClamdAddress, "://")
+	if !ok {
+		network, address = "tcp", env.ClamdAddress
+	}
+
+	timeout := time.Duration(env.ClamdTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultClamdTimeout
+	}
+
+	return scanner.NewClamdScanner(network, address, timeout)
 }
@@ -0,0 +1,41 @@
+package router
+
+import (
+	"time"
+
+	"github.com/OgiDac/CompanyTask/api/controllers"
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/repository"
+	"github.com/OgiDac/CompanyTask/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+func NewFolderRouter(
+	timeout time.Duration,
+	userRepo repository.UserRepository,
+	folderRepo repository.FolderRepository,
+	fileRepo repository.FileRepository,
+	grantRepo repository.GrantRepository,
+	fileUseCase domain.FileUseCase,
+	accessController *controllers.AccessController,
+	private *gin.RouterGroup,
+) {
+	// Files in deleted folders are removed through the file usecase
+	folderUseCase := usecase.NewFolderUseCase(userRepo, folderRepo, fileRepo, grantRepo, fileUseCase, timeout)
+
+	// Controller
+	folderController := &controllers.FolderController{
+		FolderUseCase: folderUseCase,
+	}
+
+	privateGroup := private.Group("/folders")
+	// Route
+	privateGroup.POST("/user/:id", folderController.CreateFolder)
+	privateGroup.GET("/user/:id", folderController.GetRootContents)
+	privateGroup.GET("/:id", folderController.GetFolderContents)
+	privateGroup.PATCH("/:id", folderController.UpdateFolder)
+	privateGroup.DELETE("/:id", folderController.DeleteFolder)
+	privateGroup.POST("/:id/grants", accessController.GrantFolderAccess)
+	privateGroup.GET("/:id/grants", accessController.GetFolderGrants)
+	privateGroup.DELETE("/:id/grants/:userId", accessController.RevokeFolderAccess)
+}
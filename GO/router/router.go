@@ -15,6 +15,6 @@ func Setup(env *config.Env, timeout time.Duration, db *gorm.DB, mongoDB *mongo.D
 	public := r.Group("/public/api")
 	private := r.Group("/private/api", middleware.JwtAuthMiddleware(env.AccessTokenSecret))
 
-	NewUserRouter(env, timeout, db, rabbitChannel, public, private)
-	NewFileRouter(env, timeout, db, mongoDB, public)
+	userUseCase := NewUserRouter(env, timeout, db, rabbitChannel, public, private)
+	NewFileRouter(env, timeout, db, mongoDB, userUseCase, public, private, &r.RouterGroup)
 }
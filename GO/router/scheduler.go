@@ -0,0 +1,26 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// schedule runs job in the background every interval for the lifetime of the process.
+func schedule(name string, interval time.Duration, job func(ctx context.Context) (int, error)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			n, err := job(context.Background())
+			if err != nil {
+				fmt.Println("Error running "+name+":", err)
+				continue
+			}
+			if n > 0 {
+				fmt.Println(name+":", n)
+			}
+		}
+	}()
+}
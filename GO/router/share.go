@@ -0,0 +1,44 @@
+package router
+
+import (
+	"time"
+
+	"github.com/OgiDac/CompanyTask/api/controllers"
+	"github.com/OgiDac/CompanyTask/repository"
+	"github.com/OgiDac/CompanyTask/usecase"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func NewShareRouter(
+	timeout time.Duration,
+	fileRepo repository.FileRepository,
+	folderRepo repository.FolderRepository,
+	grantRepo repository.GrantRepository,
+	mongoDB *mongo.Database,
+	private *gin.RouterGroup,
+	root *gin.RouterGroup,
+) {
+	// Mongo share link repo
+	shareRepo := repository.NewShareRepository(mongoDB)
+
+	// Shared files are opened straight from the file repo
+	shareUseCase := usecase.NewShareUseCase(shareRepo, fileRepo, folderRepo, grantRepo, timeout)
+
+	privateGroup := private.Group("/shares")
+	linkGroup := root.Group("/s")
+
+	// Controller
+	shareController := &controllers.ShareController{
+		ShareUseCase: shareUseCase,
+		LinkPath:     linkGroup.BasePath(),
+	}
+
+	// Route
+	privateGroup.POST("/file/:id", shareController.CreateShare)
+	privateGroup.GET("/file/:id", shareController.GetFileShares)
+	privateGroup.GET("/user/:id", shareController.GetUserShares)
+	privateGroup.DELETE("/:id", shareController.RevokeShare)
+	linkGroup.GET("/:token", shareController.DownloadShare)
+	linkGroup.HEAD("/:token", shareController.DownloadShare)
+}
@@ -0,0 +1,48 @@
+package router
+
+import (
+	"time"
+
+	"github.com/OgiDac/CompanyTask/api/controllers"
+	"github.com/OgiDac/CompanyTask/api/middleware"
+	"github.com/OgiDac/CompanyTask/config"
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/repository"
+	"github.com/OgiDac/CompanyTask/usecase"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const defaultUploadExpiry = 24 * time.Hour
+
+func NewUploadRouter(env *config.Env, timeout time.Duration, userRepo repository.UserRepository, mongoDB *mongo.Database, storage *repository.BlobStorage, fileUseCase domain.FileUseCase, public *gin.RouterGroup, private *gin.RouterGroup) {
+	expiry := time.Duration(env.UploadExpiryHour) * time.Hour
+	if expiry <= 0 {
+		expiry = defaultUploadExpiry
+	}
+
+	// Mongo upload repo (offsets and received chunks)
+	uploadRepo := repository.NewUploadRepository(mongoDB, storage)
+
+	// Finished uploads are stored through the file usecase
+	uploadUseCase := usecase.NewUploadUseCase(userRepo, uploadRepo, fileUseCase, expiry, timeout)
+
+	publicGroup := public.Group("/uploads", middleware.TusResumableMiddleware())
+	privateGroup := private.Group("/uploads", middleware.TusResumableMiddleware())
+
+	// Controller
+	uploadController := &controllers.UploadController{
+		UploadUseCase: uploadUseCase,
+		BasePath:      privateGroup.BasePath(),
+	}
+
+	// Route (tus 1.0); only discovery works without a token
+	publicGroup.OPTIONS("/", uploadController.Options)
+	privateGroup.POST("/user/:id", uploadController.CreateUpload)
+	privateGroup.HEAD("/:id", uploadController.GetUploadOffset)
+	privateGroup.PATCH("/:id", uploadController.WriteUploadChunk)
+	privateGroup.DELETE("/:id", uploadController.TerminateUpload)
+
+	// Abandoned uploads are removed together with their chunks
+	schedule("purged expired uploads", time.Hour, uploadUseCase.PurgeExpiredUploads)
+}
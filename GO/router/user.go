@@ -5,6 +5,7 @@ import (
 
 	"github.com/OgiDac/CompanyTask/api/controllers"
 	"github.com/OgiDac/CompanyTask/config"
+	"github.com/OgiDac/CompanyTask/domain"
 	"github.com/OgiDac/CompanyTask/publisher"
 	"github.com/OgiDac/CompanyTask/repository"
 	"github.com/OgiDac/CompanyTask/usecase"
@@ -13,7 +14,8 @@ import (
 	"gorm.io/gorm"
 )
 
-func NewUserRouter(env *config.Env, timeout time.Duration, db *gorm.DB, rabbitChanel *amqp.Channel, public *gin.RouterGroup, private *gin.RouterGroup) {
+// NewUserRouter returns the user usecase, which other routes authenticate with.
+func NewUserRouter(env *config.Env, timeout time.Duration, db *gorm.DB, rabbitChanel *amqp.Channel, public *gin.RouterGroup, private *gin.RouterGroup) domain.UserUseCase {
 	ur := repository.NewUserRepository(db)
 	publisher := publisher.NewRabbitPublisher(rabbitChanel, "user-queue")
 	uc := &controllers.UserController{
@@ -29,4 +31,5 @@ func NewUserRouter(env *config.Env, timeout time.Duration, db *gorm.DB, rabbitCh
 	privateGroup.PUT("/", uc.UpdateUser)
 	privateGroup.DELETE("/:id", uc.DeleteUser)
 
+	return uc.UserUseCase
 }
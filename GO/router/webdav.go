@@ -0,0 +1,44 @@
+package router
+
+import (
+	"time"
+
+	"github.com/OgiDac/CompanyTask/api/controllers"
+	"github.com/OgiDac/CompanyTask/api/middleware"
+	"github.com/OgiDac/CompanyTask/config"
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/repository"
+	"github.com/OgiDac/CompanyTask/usecase"
+	"github.com/gin-gonic/gin"
+)
+
+func NewWebDAVRouter(
+	env *config.Env,
+	timeout time.Duration,
+	userRepo repository.UserRepository,
+	folderRepo repository.FolderRepository,
+	fileRepo repository.FileRepository,
+	grantRepo repository.GrantRepository,
+	userUseCase domain.UserUseCase,
+	fileUseCase domain.FileUseCase,
+	root *gin.RouterGroup,
+) {
+	// Folders are created, moved and deleted the same way as through the API
+	folderUseCase := usecase.NewFolderUseCase(userRepo, folderRepo, fileRepo, grantRepo, fileUseCase, timeout)
+
+	// Clients mount the tree directly, so it lives outside the API groups and takes passwords too
+	group := root.Group("/webdav", middleware.DavAuthMiddleware(env.AccessTokenSecret, userUseCase))
+
+	// Controller
+	davController := &controllers.WebDAVController{
+		FileUseCase:   fileUseCase,
+		FolderUseCase: folderUseCase,
+		Prefix:        group.BasePath(),
+	}
+
+	// Route
+	for _, method := range controllers.DavMethods {
+		group.Handle(method, "", davController.ServeWebDAV)
+		group.Handle(method, "/*path", davController.ServeWebDAV)
+	}
+}
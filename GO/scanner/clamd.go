@@ -0,0 +1,118 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+)
+
+// clamdChunkSize is the largest chunk sent to clamd in one INSTREAM frame.
+const clamdChunkSize = 64 * 1024
+
+type clamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamdScanner returns a scanner that streams content to a clamd daemon
+// listening on network ("tcp" or "unix") at address, using the INSTREAM
+// command. A scan taking longer than timeout fails.
+func NewClamdScanner(network, address string, timeout time.Duration) domain.Scanner {
+	return &clamdScanner{
+		network: network,
+		address: address,
+		timeout: timeout,
+	}
+}
+
+func (s *clamdScanner) Scan(ctx context.Context, content io.Reader) (*domain.ScanResult, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	// clamd answers early and closes the connection when it rejects the
+	// stream, e.g. over StreamMaxLength; its reply explains more than the write error
+	if err := s.stream(conn, content); err != nil {
+		if result, replyErr := readClamdReply(conn); replyErr == nil {
+			return result, nil
+		}
+		return nil, err
+	}
+
+	return readClamdReply(conn)
+}
+
+// stream sends content as INSTREAM chunks followed by the terminating
+// zero-length chunk.
+func (s *clamdScanner) stream(conn net.Conn, content io.Reader) error {
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, err := io.ReadFull(content, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := conn.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// readClamdReply parses replies such as "stream: OK", "stream: Eicar-Signature FOUND"
+// and "INSTREAM size limit exceeded. ERROR".
+func readClamdReply(conn net.Conn) (*domain.ScanResult, error) {
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && (err != io.EOF || len(reply) == 0) {
+		return nil, err
+	}
+	line := strings.TrimSpace(string(bytes.TrimRight(reply, "\x00")))
+
+	switch {
+	case strings.HasSuffix(line, " FOUND"):
+		signature := strings.TrimSuffix(line, " FOUND")
+		if i := strings.Index(signature, ": "); i >= 0 {
+			signature = signature[i+2:]
+		}
+		return &domain.ScanResult{Status: domain.ScanInfected, Signature: signature}, nil
+	case strings.HasSuffix(line, " OK"):
+		return &domain.ScanResult{Status: domain.ScanClean}, nil
+	case strings.HasSuffix(line, " ERROR"):
+		return &domain.ScanResult{Status: domain.ScanError}, nil
+	}
+
+	return nil, errors.New("unexpected clamd reply: " + line)
+}
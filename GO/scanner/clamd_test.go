@@ -0,0 +1,132 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/stretchr/testify/require"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd speaks enough of the clamd INSTREAM protocol to test the scanner.
+// It reports content containing the EICAR test string as infected and
+// rejects streams longer than maxLength like clamd's StreamMaxLength.
+type fakeClamd struct {
+	listener  net.Listener
+	maxLength int
+	received  chan []byte
+}
+
+func startFakeClamd(t *testing.T, network, address string) *fakeClamd {
+	listener, err := net.Listen(network, address)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+
+	server := &fakeClamd{listener: listener, maxLength: 1 << 20, received: make(chan []byte, 1)}
+	go server.serve()
+	return server
+}
+
+func (s *fakeClamd) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeClamd) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	command, err := reader.ReadString(0)
+	if err != nil || command != "zINSTREAM\x00" {
+		_, _ = conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+
+	var content bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		if content.Len()+int(size) > s.maxLength {
+			_, _ = conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+			// Keep reading so the client sees the reply instead of a reset connection
+			_, _ = io.Copy(io.Discard, reader)
+			return
+		}
+		if _, err := io.CopyN(&content, reader, int64(size)); err != nil {
+			return
+		}
+	}
+
+	s.received <- content.Bytes()
+	if bytes.Contains(content.Bytes(), []byte(eicar)) {
+		_, _ = conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+		return
+	}
+	_, _ = conn.Write([]byte("stream: OK\x00"))
+}
+
+func TestClamdScanner_Clean(t *testing.T) {
+	server := startFakeClamd(t, "tcp", "127.0.0.1:0")
+	scanner := NewClamdScanner("tcp", server.listener.Addr().String(), 5*time.Second)
+
+	// Larger than one chunk, so the content is split into several frames
+	content := bytes.Repeat([]byte("clean content "), 10_000)
+	result, err := scanner.Scan(context.Background(), bytes.NewReader(content))
+
+	require.NoError(t, err)
+	require.Equal(t, &domain.ScanResult{Status: domain.ScanClean}, result)
+	require.Equal(t, content, <-server.received)
+}
+
+func TestClamdScanner_InfectedOverUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "clamd.sock")
+	startFakeClamd(t, "unix", socket)
+	scanner := NewClamdScanner("unix", socket, 5*time.Second)
+
+	result, err := scanner.Scan(context.Background(), bytes.NewReader([]byte(eicar)))
+
+	require.NoError(t, err)
+	require.Equal(t, &domain.ScanResult{Status: domain.ScanInfected, Signature: "Eicar-Test-Signature"}, result)
+}
+
+func TestClamdScanner_SizeLimit(t *testing.T) {
+	server := startFakeClamd(t, "tcp", "127.0.0.1:0")
+	server.maxLength = 1024
+	scanner := NewClamdScanner("tcp", server.listener.Addr().String(), 5*time.Second)
+
+	result, err := scanner.Scan(context.Background(), bytes.NewReader(make([]byte, 4096)))
+
+	require.NoError(t, err)
+	require.Equal(t, domain.ScanError, result.Status)
+}
+
+func TestClamdScanner_Unreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	scanner := NewClamdScanner("tcp", address, time.Second)
+	_, err = scanner.Scan(context.Background(), bytes.NewReader([]byte("data")))
+
+	require.Error(t, err)
+}
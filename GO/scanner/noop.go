@@ -0,0 +1,24 @@
+package scanner
+
+import (
+	"context"
+	"io"
+
+	"github.com/OgiDac/CompanyTask/domain"
+)
+
+type noopScanner struct{}
+
+// NewNoopScanner returns a scanner that reports all content as clean, for
+// deployments without a virus scanner.
+func NewNoopScanner() domain.Scanner {
+	return noopScanner{}
+}
+
+func (noopScanner) Scan(ctx context.Context, content io.Reader) (*domain.ScanResult, error) {
+	// Read everything like a real scanner would, so callers streaming into it never block
+	if _, err := io.Copy(io.Discard, content); err != nil {
+		return nil, err
+	}
+	return &domain.ScanResult{Status: domain.ScanClean}, nil
+}
@@ -0,0 +1,258 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/repository"
+)
+
+type accessUseCase struct {
+	userRepo   repository.UserRepository
+	fileRepo   repository.FileRepository
+	folderRepo repository.FolderRepository
+	grantRepo  repository.GrantRepository
+	timeout    time.Duration
+}
+
+func NewAccessUseCase(
+	userRepo repository.UserRepository,
+	fileRepo repository.FileRepository,
+	folderRepo repository.FolderRepository,
+	grantRepo repository.GrantRepository,
+	timeout time.Duration,
+) domain.AccessUseCase {
+	return &accessUseCase{
+		userRepo:   userRepo,
+		fileRepo:   fileRepo,
+		folderRepo: folderRepo,
+		grantRepo:  grantRepo,
+		timeout:    timeout,
+	}
+}
+
+// GrantAccess gives request.UserID read or write access to a file or folder
+// of the caller, replacing the permission of an earlier grant.
+func (u *accessUseCase) GrantAccess(ctx context.Context, callerID uint, resource domain.Resource, request domain.GrantRequest) (*domain.Grant, error) {
+	if request.Permission != domain.PermissionRead && request.Permission != domain.PermissionWrite {
+		return nil, domain.ErrInvalidGrant
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	if err := u.checkOwner(ctx, callerID, resource); err != nil {
+		return nil, err
+	}
+	if request.UserID == callerID {
+		return nil, domain.ErrInvalidGrant
+	}
+	if _, err := u.userRepo.GetUserByID(ctx, request.UserID); err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	grant := &domain.Grant{
+		Resource:   resource,
+		OwnerID:    callerID,
+		UserID:     request.UserID,
+		Permission: request.Permission,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := u.grantRepo.SetGrant(ctx, grant); err != nil {
+		return nil, err
+	}
+
+	return grant, nil
+}
+
+func (u *accessUseCase) GetGrants(ctx context.Context, callerID uint, resource domain.Resource) ([]*domain.Grant, error) {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	if err := u.checkOwner(ctx, callerID, resource); err != nil {
+		return nil, err
+	}
+
+	grants, err := u.grantRepo.GetGrants(ctx, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]*domain.Grant{}, grants...), nil
+}
+
+// RevokeAccess removes the grant of userID on resource. The owner can revoke
+// any grant and other users can give up their own.
+func (u *accessUseCase) RevokeAccess(ctx context.Context, callerID uint, resource domain.Resource, userID uint) error {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	if callerID != userID {
+		if err := u.checkOwner(ctx, callerID, resource); err != nil {
+			return err
+		}
+	}
+
+	return u.grantRepo.DeleteGrant(ctx, resource, userID)
+}
+
+// GetSharedWithMe lists what other users granted the caller. Grants whose
+// file or folder no longer exists are skipped.
+func (u *accessUseCase) GetSharedWithMe(ctx context.Context, callerID uint) (*domain.SharedWithMe, error) {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	grants, err := u.grantRepo.GetGrantsByUserID(ctx, callerID)
+	if err != nil {
+		return nil, err
+	}
+
+	shared := &domain.SharedWithMe{
+		Files:   []*domain.SharedFile{},
+		Folders: []*domain.SharedFolder{},
+	}
+	for _, grant := range grants {
+		switch grant.Resource.Type {
+		case domain.ResourceFile:
+			file, err := u.fileRepo.GetFileByID(ctx, grant.Resource.ID)
+			if errors.Is(err, domain.ErrFileNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			shared.Files = append(shared.Files, &domain.SharedFile{
+				UserFileMeta: fileMeta(file),
+				OwnerID:      file.UserID,
+				Permission:   grant.Permission,
+			})
+		case domain.ResourceFolder:
+			folder, err := u.folderRepo.GetFolderByID(ctx, grant.Resource.ID)
+			if errors.Is(err, domain.ErrFolderNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			shared.Folders = append(shared.Folders, &domain.SharedFolder{
+				Folder:     folder,
+				Permission: grant.Permission,
+			})
+		}
+	}
+
+	return shared, nil
+}
+
+// checkOwner verifies that resource exists and belongs to the caller.
+func (u *accessUseCase) checkOwner(ctx context.Context, callerID uint, resource domain.Resource) error {
+	var ownerID uint
+	switch resource.Type {
+	case domain.ResourceFile:
+		file, err := u.fileRepo.GetFileByID(ctx, resource.ID)
+		if err != nil {
+			return err
+		}
+		ownerID = file.UserID
+	case domain.ResourceFolder:
+		folder, err := u.folderRepo.GetFolderByID(ctx, resource.ID)
+		if err != nil {
+			return err
+		}
+		ownerID = folder.UserID
+	default:
+		return domain.ErrInvalidGrant
+	}
+
+	if ownerID != callerID {
+		return domain.ErrForbidden
+	}
+	return nil
+}
+
+// accessControl decides what callers may do with files and folders of other
+// users. Owners may do anything; everyone else needs a grant on the resource
+// or on one of the folders above it.
+type accessControl struct {
+	folderRepo repository.FolderRepository
+	grantRepo  repository.GrantRepository
+}
+
+func (a accessControl) checkFile(ctx context.Context, callerID uint, file *domain.UserFile, required domain.Permission) error {
+	if file.UserID == callerID {
+		return nil
+	}
+	return a.checkGrants(ctx, callerID, domain.Resource{Type: domain.ResourceFile, ID: file.ID}, file.FolderID, required)
+}
+
+func (a accessControl) checkFolder(ctx context.Context, callerID uint, folder *domain.Folder, required domain.Permission) error {
+	if folder.UserID == callerID {
+		return nil
+	}
+	return a.checkGrants(ctx, callerID, domain.Resource{Type: domain.ResourceFolder, ID: folder.ID}, folder.ParentID, required)
+}
+
+// checkSharer verifies that ownerID shared anything with the caller, which
+// any access to their files and folders needs.
+func (a accessControl) checkSharer(ctx context.Context, callerID, ownerID uint) error {
+	if callerID == ownerID {
+		return nil
+	}
+
+	grants, err := a.grantRepo.GetGrantsByUserID(ctx, callerID)
+	if err != nil {
+		return err
+	}
+	for _, grant := range grants {
+		if grant.OwnerID == ownerID {
+			return nil
+		}
+	}
+
+	return domain.ErrForbidden
+}
+
+// checkGrants looks for a grant of the caller on resource or any folder from
+// parentID up to the root that allows required.
+func (a accessControl) checkGrants(ctx context.Context, callerID uint, resource domain.Resource, parentID string, required domain.Permission) error {
+	resources := []domain.Resource{resource}
+	seen := map[string]bool{}
+	for parentID != "" && !seen[parentID] {
+		seen[parentID] = true
+
+		folder, err := a.folderRepo.GetFolderByID(ctx, parentID)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, domain.Resource{Type: domain.ResourceFolder, ID: folder.ID})
+		parentID = folder.ParentID
+	}
+
+	grants, err := a.grantRepo.GetUserGrants(ctx, callerID, resources)
+	if err != nil {
+		return err
+	}
+	for _, grant := range grants {
+		if grant.Permission.Allows(required) {
+			return nil
+		}
+	}
+
+	return domain.ErrForbidden
+}
+
+// deleteGrants drops the grants on resources that were just deleted. It is
+// best effort, as stale grants only point at nothing.
+func (a accessControl) deleteGrants(ctx context.Context, resources ...domain.Resource) {
+	_ = a.grantRepo.DeleteResourceGrants(ctx, resources)
+}
+
+// checkUser allows callers to act on their own account only.
+func checkUser(callerID, userID uint) error {
+	if callerID != userID {
+		return domain.ErrForbidden
+	}
+	return nil
+}
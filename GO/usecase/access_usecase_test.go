@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrantAccess_Success(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+
+	useCase := NewAccessUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockGrantRepo, 2*time.Second)
+
+	resource := domain.Resource{Type: domain.ResourceFolder, ID: "f1"}
+
+	mockFolderRepo.On("GetFolderByID", mock.Anything, "f1").Return(&domain.Folder{ID: "f1", UserID: 1}, nil)
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(2)).Return(&domain.User{ID: 2}, nil)
+	mockGrantRepo.On("SetGrant", mock.Anything, mock.Anything).Return(nil)
+
+	grant, err := useCase.GrantAccess(context.Background(), 1, resource, domain.GrantRequest{UserID: 2, Permission: domain.PermissionWrite})
+
+	require.NoError(t, err)
+	require.Equal(t, resource, grant.Resource)
+	require.Equal(t, uint(1), grant.OwnerID)
+	require.Equal(t, uint(2), grant.UserID)
+	require.Equal(t, domain.PermissionWrite, grant.Permission)
+	mockGrantRepo.AssertExpectations(t)
+}
+
+func TestGrantAccess_NotOwner(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+
+	useCase := NewAccessUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockGrantRepo, 2*time.Second)
+
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(&domain.UserFile{ID: "abc123", UserID: 1}, nil)
+
+	// Write access lets a user change the file, not pass it on
+	grant, err := useCase.GrantAccess(context.Background(), 2, domain.Resource{Type: domain.ResourceFile, ID: "abc123"},
+		domain.GrantRequest{UserID: 3, Permission: domain.PermissionRead})
+
+	require.ErrorIs(t, err, domain.ErrForbidden)
+	require.Nil(t, grant)
+	mockGrantRepo.AssertNotCalled(t, "SetGrant", mock.Anything, mock.Anything)
+}
+
+func TestGetSharedWithMe_SkipsDeleted(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+
+	useCase := NewAccessUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockGrantRepo, 2*time.Second)
+
+	mockGrantRepo.On("GetGrantsByUserID", mock.Anything, uint(2)).Return([]*domain.Grant{
+		{Resource: domain.Resource{Type: domain.ResourceFile, ID: "abc123"}, UserID: 2, Permission: domain.PermissionRead},
+		{Resource: domain.Resource{Type: domain.ResourceFile, ID: "gone"}, UserID: 2, Permission: domain.PermissionRead},
+		{Resource: domain.Resource{Type: domain.ResourceFolder, ID: "f1"}, UserID: 2, Permission: domain.PermissionWrite},
+	}, nil)
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(&domain.UserFile{ID: "abc123", UserID: 1, Filename: "q3.pdf"}, nil)
+	mockFileRepo.On("GetFileByID", mock.Anything, "gone").Return(nil, domain.ErrFileNotFound)
+	mockFolderRepo.On("GetFolderByID", mock.Anything, "f1").Return(&domain.Folder{ID: "f1", UserID: 1, Name: "reports"}, nil)
+
+	shared, err := useCase.GetSharedWithMe(context.Background(), 2)
+
+	require.NoError(t, err)
+	require.Len(t, shared.Files, 1)
+	require.Equal(t, "q3.pdf", shared.Files[0].Filename)
+	require.Equal(t, uint(1), shared.Files[0].OwnerID)
+	require.Len(t, shared.Folders, 1)
+	require.Equal(t, domain.PermissionWrite, shared.Folders[0].Permission)
+}
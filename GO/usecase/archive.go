@@ -0,0 +1,208 @@
+package usecase
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/OgiDac/CompanyTask/domain"
+)
+
+// compressedTypes are stored in archives as they are, since deflating them
+// again costs time without making them smaller.
+var compressedTypes = []string{
+	"image/jpeg",
+	"image/png",
+	"image/gif",
+	"image/webp",
+	"video/*",
+	"audio/*",
+	"application/zip",
+	"application/gzip",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/x-xz",
+	"application/zstd",
+}
+
+func (f *fileUseCase) GetArchive(ctx context.Context, callerID, userID uint, filter domain.ArchiveFilter) (*domain.Archive, error) {
+	if err := checkUser(callerID, userID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	folders, err := f.folderRepo.GetFoldersByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	paths := newFolderPaths(folders, filter.FolderID)
+
+	archive := &domain.Archive{Name: "files.zip"}
+	if filter.FolderID != "" {
+		folder, ok := paths.folders[filter.FolderID]
+		if !ok {
+			return nil, domain.ErrFolderNotFound
+		}
+		archive.Name = folder.Name + ".zip"
+	}
+
+	files, err := f.fileRepo.GetFilesByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]bool{}
+	for _, id := range filter.FileIDs {
+		wanted[id] = true
+	}
+
+	for _, file := range files {
+		if len(wanted) > 0 && !wanted[file.ID] {
+			continue
+		}
+		dir, ok := paths.get(file.FolderID)
+		if !ok {
+			continue
+		}
+		delete(wanted, file.ID)
+
+		if file.ScanStatus.Check() != nil {
+			archive.Skipped++
+			continue
+		}
+		archive.Entries = append(archive.Entries, domain.ArchiveEntry{
+			Path: path.Join(dir, archiveName(file.Filename)),
+			File: file,
+		})
+	}
+
+	// Every requested file must be part of the selection
+	if len(wanted) > 0 {
+		return nil, domain.ErrFileNotFound
+	}
+
+	uniqueArchivePaths(archive.Entries)
+	return archive, nil
+}
+
+// WriteArchive streams the files of archive to w as a ZIP file. Content is
+// copied one file at a time, so the archive is never held in memory. On
+// error the archive is left unfinished, so clients can't mistake it for a
+// complete one.
+func (f *fileUseCase) WriteArchive(ctx context.Context, archive *domain.Archive, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	for _, entry := range archive.Entries {
+		if err := f.writeArchiveEntry(ctx, zw, entry); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (f *fileUseCase) writeArchiveEntry(ctx context.Context, zw *zip.Writer, entry domain.ArchiveEntry) error {
+	content, err := f.fileRepo.OpenFileContent(ctx, entry.File)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	method := zip.Deflate
+	if matchesAny(lookupContentType(entry.File.ContentType), compressedTypes) {
+		method = zip.Store
+	}
+
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     entry.Path,
+		Method:   method,
+		Modified: entry.File.UploadedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, content)
+	return err
+}
+
+// folderPaths builds the paths of a user's folders relative to a base
+// folder, or to the root when the base is empty.
+type folderPaths struct {
+	folders map[string]*domain.Folder
+	base    string
+}
+
+func newFolderPaths(folders []*domain.Folder, base string) *folderPaths {
+	paths := &folderPaths{folders: map[string]*domain.Folder{}, base: base}
+	for _, folder := range folders {
+		paths.folders[folder.ID] = folder
+	}
+	return paths
+}
+
+// get returns the path of the folder with the given ID, and false when the
+// folder is not below the base.
+func (p *folderPaths) get(id string) (string, bool) {
+	var names []string
+	for id != p.base {
+		folder, ok := p.folders[id]
+		// Folders outside the base end at the root or at a missing parent
+		if !ok || len(names) > len(p.folders) {
+			return "", false
+		}
+		names = append(names, archiveName(folder.Name))
+		id = folder.ParentID
+	}
+
+	dir := ""
+	for i := len(names) - 1; i >= 0; i-- {
+		dir = path.Join(dir, names[i])
+	}
+	return dir, true
+}
+
+// archiveName replaces characters that unzip tools treat as path separators.
+func archiveName(name string) string {
+	return strings.ReplaceAll(name, `\`, "_")
+}
+
+// uniqueArchivePaths renames entries whose paths differ only in case, which
+// would overwrite each other when extracted on Windows and macOS, and files
+// named like a folder. The later entries get a counter before the
+// extension, like "report (2).pdf".
+func uniqueArchivePaths(entries []domain.ArchiveEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Path != entries[j].Path {
+			return entries[i].Path < entries[j].Path
+		}
+		return entries[i].File.UploadedAt.Before(entries[j].File.UploadedAt)
+	})
+
+	// A file can't take the name of a folder holding other entries either
+	used := map[string]bool{}
+	for _, entry := range entries {
+		for dir := path.Dir(entry.Path); dir != "."; dir = path.Dir(dir) {
+			used[strings.ToLower(dir)] = true
+		}
+	}
+
+	for i := range entries {
+		name := entries[i].Path
+		ext := path.Ext(name)
+		if ext == path.Base(name) {
+			// Dot files like .env have no extension to keep
+			ext = ""
+		}
+		stem := strings.TrimSuffix(name, ext)
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			name = stem + " (" + strconv.Itoa(n) + ")" + ext
+		}
+		used[strings.ToLower(name)] = true
+		entries[i].Path = name
+	}
+}
@@ -0,0 +1,228 @@
+package usecase
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/OgiDac/CompanyTask/compression"
+	"github.com/OgiDac/CompanyTask/config"
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// sniffLength is how much of the content is inspected to detect its type.
+const sniffLength = 3072
+
+// defaultDeniedTypes keeps content browsers would run or render as a page
+// out of storage unless FILE_DENIED_TYPES says otherwise.
+var defaultDeniedTypes = []string{
+	"text/html",
+	"image/svg+xml",
+	"text/javascript",
+	"application/vnd.microsoft.portable-executable",
+	"application/x-elf",
+	"application/x-mach-binary",
+	"application/x-ms-installer",
+}
+
+// contentPolicy decides which detected content types may be stored. Types
+// match themselves, their subtypes (application/zip covers .docx) and
+// wildcards such as image/*.
+type contentPolicy struct {
+	allowed []string
+	denied  []string
+	// extensions limits names with a given extension to the listed types; an
+	// empty list rejects the extension altogether.
+	extensions map[string][]string
+}
+
+// newContentPolicy reads the policy from comma separated FILE_ALLOWED_TYPES
+// and FILE_DENIED_TYPES and FILE_EXTENSION_TYPES entries like
+// ".jpg=image/jpeg;.csv=text/csv|text/plain;.exe=".
+func newContentPolicy(env *config.Env) contentPolicy {
+	policy := contentPolicy{
+		allowed:    splitList(env.FileAllowedTypes, ","),
+		denied:     splitList(env.FileDeniedTypes, ","),
+		extensions: map[string][]string{},
+	}
+	if len(policy.denied) == 0 {
+		policy.denied = defaultDeniedTypes
+	}
+
+	for _, entry := range splitList(env.FileExtensionTypes, ";") {
+		ext, types, ok := strings.Cut(entry, "=")
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if !ok || !strings.HasPrefix(ext, ".") {
+			log.Printf("Ignoring invalid FILE_EXTENSION_TYPES entry %q", entry)
+			continue
+		}
+		policy.extensions[ext] = splitList(types, "|")
+	}
+
+	return policy
+}
+
+// compressionRule stores content of a type with a codec.
+type compressionRule struct {
+	contentType string
+	codec       string
+}
+
+// compressionPolicy picks the codec content is stored with. The first rule
+// matching the detected type wins; content matching none is stored as it is.
+type compressionPolicy []compressionRule
+
+// newCompressionPolicy reads FILE_COMPRESSION_TYPES entries like
+// "text/csv=zstd;text/*=gzip;application/json=gzip".
+func newCompressionPolicy(env *config.Env) compressionPolicy {
+	var policy compressionPolicy
+	for _, entry := range splitList(env.FileCompressionTypes, ";") {
+		contentType, codec, ok := strings.Cut(entry, "=")
+		contentType = strings.TrimSpace(contentType)
+		codec = strings.ToLower(strings.TrimSpace(codec))
+		if !ok || contentType == "" || !compression.Supported(codec) {
+			log.Printf("Ignoring invalid FILE_COMPRESSION_TYPES entry %q", entry)
+			continue
+		}
+		policy = append(policy, compressionRule{contentType: contentType, codec: codec})
+	}
+	return policy
+}
+
+// codec returns the codec to store content of the detected type with, or
+// an empty string to store it uncompressed.
+func (p compressionPolicy) codec(detected *mimetype.MIME) string {
+	for _, rule := range p {
+		if isType(detected, rule.contentType) {
+			return rule.codec
+		}
+	}
+	return ""
+}
+
+// checkName rejects names whose extension no content type is allowed for, so
+// such uploads fail before any content is sent.
+func (p contentPolicy) checkName(filename string) error {
+	if types, ok := p.extensions[strings.ToLower(path.Ext(filename))]; ok && len(types) == 0 {
+		return domain.ErrContentTypeNotAllowed
+	}
+	return nil
+}
+
+// check verifies that content detected as detected may be stored under
+// filename, and that it matches the type the client declared.
+func (p contentPolicy) check(filename, declared string, detected *mimetype.MIME) error {
+	if !declaredMatches(declared, detected) {
+		return domain.ErrContentTypeMismatch
+	}
+
+	if matchesAny(detected, p.denied) {
+		return domain.ErrContentTypeNotAllowed
+	}
+	if len(p.allowed) > 0 && !matchesAny(detected, p.allowed) {
+		return domain.ErrContentTypeNotAllowed
+	}
+
+	return p.checkExtension(filename, detected)
+}
+
+// checkExtension applies the per-extension policy to content of the given type.
+func (p contentPolicy) checkExtension(filename string, contentType *mimetype.MIME) error {
+	if types, ok := p.extensions[strings.ToLower(path.Ext(filename))]; ok && !matchesAny(contentType, types) {
+		return domain.ErrContentTypeNotAllowed
+	}
+	return nil
+}
+
+// detectContentType reads the start of content and returns its detected type
+// along with a reader that still yields the complete content.
+func detectContentType(content io.Reader) (*mimetype.MIME, io.Reader, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, nil, err
+	}
+	head = head[:n]
+
+	return mimetype.Detect(head), io.MultiReader(bytes.NewReader(head), content), nil
+}
+
+// lookupContentType returns the known type for a stored content type, or
+// application/octet-stream when it isn't known.
+func lookupContentType(contentType string) *mimetype.MIME {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if m := mimetype.Lookup(mediaType); m != nil {
+		return m
+	}
+	return mimetype.Lookup("application/octet-stream")
+}
+
+// declaredMatches reports whether the declared type describes the detected
+// content, either exactly or as a more general or more specific type of it.
+// Clients that declare nothing or a generic binary type always match.
+func declaredMatches(declared string, detected *mimetype.MIME) bool {
+	mediaType, _, err := mime.ParseMediaType(declared)
+	if err != nil || mediaType == "" || mediaType == "application/octet-stream" {
+		return true
+	}
+
+	// Unrecognised binary content can't contradict anything; it is stored as octet-stream
+	if detected.Parent() == nil {
+		return true
+	}
+	if isType(detected, mediaType) {
+		return true
+	}
+	if specific := mimetype.Lookup(mediaType); specific != nil && isType(specific, detected.String()) {
+		return true
+	}
+
+	// Plain text is often declared with a type the detector doesn't know, like text/markdown
+	return detected.Is("text/plain") && strings.HasPrefix(mediaType, "text/")
+}
+
+func matchesAny(detected *mimetype.MIME, patterns []string) bool {
+	for _, pattern := range patterns {
+		if isType(detected, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// isType reports whether m is pattern or one of its subtypes. A pattern
+// ending in /* matches every type with that prefix. The generic root type
+// only matches itself, so it never covers every other type.
+func isType(m *mimetype.MIME, pattern string) bool {
+	pattern = strings.ToLower(pattern)
+	for node := m; node != nil; node = node.Parent() {
+		if node != m && node.Parent() == nil {
+			break
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+			if mediaType, _, _ := mime.ParseMediaType(node.String()); strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if node.Is(pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a separated list and drops empty entries.
+func splitList(value, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
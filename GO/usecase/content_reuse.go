@@ -0,0 +1,89 @@
+package usecase
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+)
+
+// HasContent only looks at the user's own files, so a digest can't be used to
+// find out what other users store.
+func (f *fileUseCase) HasContent(ctx context.Context, callerID, userID uint, digest string) (bool, error) {
+	if err := checkUser(callerID, userID); err != nil {
+		return false, err
+	}
+	if !validContentDigest(digest) {
+		return false, domain.ErrInvalidDigest
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	_, err := f.fileRepo.GetFileByDigest(ctx, userID, digest)
+	if errors.Is(err, domain.ErrFileNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UploadKnownContent reads the content from the file that has it and uploads
+// it like the client would have, so names, type policy, quota and scan apply
+// as usual. The stored bytes are shared with the existing file.
+func (f *fileUseCase) UploadKnownContent(ctx context.Context, callerID, userID uint, folderID, filename string, tags []string, expiresAt *time.Time, digest string) (*domain.UserFileMeta, error) {
+	if err := checkUser(callerID, userID); err != nil {
+		return nil, err
+	}
+	if !validContentDigest(digest) {
+		return nil, domain.ErrInvalidDigest
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	source, err := f.fileRepo.GetFileByDigest(lookupCtx, userID, digest)
+	if err != nil {
+		return nil, err
+	}
+	version, ok := knownVersion(source, digest)
+	if !ok {
+		// The file changed since it was found
+		return nil, domain.ErrFileNotFound
+	}
+
+	content, err := f.fileRepo.OpenFileContent(ctx, source.AtVersion(version))
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	sum, _ := hex.DecodeString(digest)
+	digests := []domain.ContentDigest{{Algorithm: domain.DigestSHA256, Sum: sum}}
+	return f.UploadFile(ctx, callerID, userID, folderID, filename, version.ContentType, tags, expiresAt, digests, content)
+}
+
+// knownVersion returns the clean, intact version of file with the digest.
+func knownVersion(file *domain.UserFile, digest string) (domain.FileVersion, bool) {
+	for _, version := range file.Versions {
+		if version.Digest == digest && version.ScanStatus == domain.ScanClean && !version.Corrupt {
+			return version, true
+		}
+	}
+	return domain.FileVersion{}, false
+}
+
+// validContentDigest reports whether digest is a lowercase hex SHA-256, the
+// form UserFileMeta.Digest has.
+func validContentDigest(digest string) bool {
+	if len(digest) != 2*32 {
+		return false
+	}
+	_, err := hex.DecodeString(digest)
+	return err == nil && digest == strings.ToLower(digest)
+}
@@ -0,0 +1,61 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+)
+
+// expiryBatch bounds how many files a single sweeper run deletes.
+const expiryBatch = 100
+
+// checkExpiry rejects expiry times that have already passed.
+func checkExpiry(expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return domain.ErrInvalidExpiry
+	}
+	return nil
+}
+
+func (f *fileUseCase) SetFileExpiry(ctx context.Context, callerID uint, id string, expiresAt *time.Time) (*domain.UserFileMeta, error) {
+	if err := checkExpiry(expiresAt); err != nil {
+		return nil, err
+	}
+	if expiresAt != nil {
+		utc := expiresAt.UTC()
+		expiresAt = &utc
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	file, err := f.file(ctx, callerID, id, domain.PermissionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.fileRepo.SetFileExpiry(ctx, file, expiresAt); err != nil {
+		return nil, err
+	}
+
+	return fileMeta(file), nil
+}
+
+// ExpireFiles deletes expired files the way the trash purger does, releasing
+// their content, usage and grants. Expired files are hidden as soon as they
+// expire, so the sweeper only has to catch up.
+func (f *fileUseCase) ExpireFiles(ctx context.Context) (int, error) {
+	listCtx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	files, err := f.fileRepo.GetExpiredFiles(listCtx, now, expiryBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	return f.purge(ctx, files, func(ctx context.Context, file *domain.UserFile) error {
+		return f.fileRepo.DeleteExpiredFile(ctx, file, now)
+	})
+}
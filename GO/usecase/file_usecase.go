@@ -3,77 +3,728 @@ package usecase
 import (
 	"context"
 	"errors"
+	"io"
+	"mime"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/OgiDac/CompanyTask/config"
 	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/integrity"
 	"github.com/OgiDac/CompanyTask/repository"
 )
 
 type fileUseCase struct {
-	userRepo repository.UserRepository
-	fileRepo repository.FileRepository
-	timeout  time.Duration
+	userRepo   repository.UserRepository
+	fileRepo   repository.FileRepository
+	folderRepo repository.FolderRepository
+	quotaRepo  repository.QuotaRepository
+	scanner    domain.Scanner
+	access     accessControl
+	timeout    time.Duration
+	retention  domain.VersionRetention
+	quota      domain.StorageQuota
+	// quotaAdmins are the users allowed to override quotas
+	quotaAdmins []uint
+	policy      contentPolicy
+	compress    compressionPolicy
+	// trashRetention is how long deleted files are kept in the trash
+	trashRetention time.Duration
+	// scrubPeriod is how often stored content is read back and verified
+	scrubPeriod time.Duration
 }
 
-func NewFileUseCase(userRepo repository.UserRepository, fileRepo repository.FileRepository, timeout time.Duration) domain.FileUseCase {
+func NewFileUseCase(
+	userRepo repository.UserRepository,
+	fileRepo repository.FileRepository,
+	folderRepo repository.FolderRepository,
+	quotaRepo repository.QuotaRepository,
+	grantRepo repository.GrantRepository,
+	scanner domain.Scanner,
+	quotaAdmins []uint,
+	timeout time.Duration,
+	env *config.Env,
+) domain.FileUseCase {
 	return &fileUseCase{
-		userRepo: userRepo,
-		fileRepo: fileRepo,
-		timeout:  timeout,
+		userRepo:   userRepo,
+		fileRepo:   fileRepo,
+		folderRepo: folderRepo,
+		quotaRepo:  quotaRepo,
+		scanner:    scanner,
+		access:     accessControl{folderRepo: folderRepo, grantRepo: grantRepo},
+		timeout:    timeout,
+		retention: domain.VersionRetention{
+			MaxVersions: env.FileMaxVersions,
+			MaxAgeDays:  env.FileVersionMaxAgeDays,
+		},
+		quota: domain.StorageQuota{
+			MaxBytes: env.FileQuotaBytes,
+			MaxFiles: env.FileQuotaFiles,
+		},
+		quotaAdmins:    quotaAdmins,
+		policy:         newContentPolicy(env),
+		compress:       newCompressionPolicy(env),
+		trashRetention: trashRetention(env),
+		scrubPeriod:    scrubPeriod(env),
 	}
 }
 
-func (u *fileUseCase) GetFileByID(ctx context.Context, id string) (*domain.UserFile, error) {
+func (u *fileUseCase) GetFileByID(ctx context.Context, callerID uint, id string) (*domain.UserFile, error) {
 	ctx, cancel := context.WithTimeout(ctx, u.timeout)
 	defer cancel()
 
-	return u.fileRepo.GetFileByID(ctx, id)
+	return u.file(ctx, callerID, id, domain.PermissionRead)
 }
 
-func (f *fileUseCase) UploadFile(ctx context.Context, userID uint, filename, contentType string, data []byte) error {
+func (f *fileUseCase) UpdateFile(ctx context.Context, callerID uint, id string, update domain.FileUpdate) (*domain.UserFile, error) {
+	if update.Filename != nil {
+		filename, ok := cleanName(*update.Filename)
+		if !ok {
+			return nil, domain.ErrInvalidFilename
+		}
+		update.Filename = &filename
+	}
+	for key := range update.Metadata {
+		// Keys become field paths in the stored document
+		if key == "" || strings.ContainsAny(key, ".$") {
+			return nil, domain.ErrInvalidMetadataKey
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, f.timeout)
 	defer cancel()
 
-	// Check if user exists in MySQL
-	_, err := f.userRepo.GetUserByID(ctx, userID)
+	file, err := f.file(ctx, callerID, id, domain.PermissionWrite)
 	if err != nil {
-		return errors.New("user not found")
+		return nil, err
+	}
+	if err := f.checkUpdateType(file, update); err != nil {
+		return nil, err
+	}
+
+	// Moving needs write access to the target as well; only owners write to their root
+	if update.FolderID != nil && *update.FolderID != file.FolderID {
+		if *update.FolderID == "" {
+			err = checkUser(callerID, file.UserID)
+		} else {
+			err = f.checkFolder(ctx, callerID, file.UserID, *update.FolderID)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := f.fileRepo.UpdateFile(ctx, file, update); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// DeleteFile moves the file to the trash. Its content, usage and grants are
+// kept until the trash is emptied or the file expires.
+func (f *fileUseCase) DeleteFile(ctx context.Context, callerID uint, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	file, err := f.file(ctx, callerID, id, domain.PermissionWrite)
+	if err != nil {
+		return err
+	}
+
+	return f.fileRepo.TrashFile(ctx, file, time.Now().UTC())
+}
+
+func (u *fileUseCase) DownloadFile(ctx context.Context, callerID uint, id string) (*domain.UserFile, io.ReadSeekCloser, error) {
+	file, err := u.GetFileByID(ctx, callerID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := file.ScanStatus.Check(); err != nil {
+		return nil, nil, err
+	}
+
+	// The stream outlives this call, so it is bound to the request context only
+	content, err := u.fileRepo.OpenFileContent(ctx, file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return file, content, nil
+}
+
+func (f *fileUseCase) UploadFile(ctx context.Context, callerID, userID uint, folderID, filename, contentType string, tags []string, expiresAt *time.Time, digests []domain.ContentDigest, content io.Reader) (*domain.UserFileMeta, error) {
+	verifier, err := integrity.NewVerifier(digests)
+	if err != nil {
+		return nil, err
+	}
+	tags, err = normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkExpiry(expiresAt); err != nil {
+		return nil, err
+	}
+	if expiresAt != nil {
+		utc := expiresAt.UTC()
+		expiresAt = &utc
+	}
+
+	userCtx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	filename, usage, quota, err := f.prepareUpload(userCtx, callerID, userID, folderID, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Store what the content is rather than what the client says it is
+	detected, content, err := detectContentType(content)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.policy.check(filename, contentType, detected); err != nil {
+		return nil, err
+	}
+	contentType = detected.String()
+	content = verifier.Reader(content)
+
+	if quota.MaxBytes > 0 {
+		// Stop reading as soon as the upload can't fit instead of storing it first
+		remaining := quota.MaxBytes - usage.Bytes
+		if remaining < 0 {
+			return nil, domain.ErrQuotaExceeded
+		}
+		content = &quotaReader{reader: content, remaining: remaining}
+	}
+
+	// Stream file into GridFS; large uploads are bounded by the request, not the timeout.
+	// The scanner sees the content on the way, so nothing is served before it was scanned
+	content, finishScan := f.startScan(ctx, content)
+	version, err := f.fileRepo.StoreContent(ctx, content, f.compress.codec(detected))
+	scan := finishScan(err)
+	if err != nil {
+		return nil, err
+	}
+	version.ScanStatus = scan.Status
+	version.ScanSignature = scan.Signature
+
+	// Content that got damaged on the way is dropped before it becomes a version
+	if err := verifier.Verify(); err != nil {
+		_ = f.fileRepo.ReleaseContent(context.Background(), version)
+		return nil, err
+	}
+
+	saveCtx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	newFiles := 0
+	if _, err := f.fileRepo.GetFileByName(saveCtx, userID, folderID, filename); errors.Is(err, domain.ErrFileNotFound) {
+		newFiles = 1
+	} else if err != nil {
+		_ = f.fileRepo.ReleaseContent(context.Background(), version)
+		return nil, err
+	}
+
+	if err := f.quotaRepo.ReserveUsage(saveCtx, userID, version.Size, newFiles, quota); err != nil {
+		_ = f.fileRepo.ReleaseContent(context.Background(), version)
+		return nil, err
 	}
 
-	// Save file in Mongo
 	userFile := &domain.UserFile{
 		UserID:      userID,
+		FolderID:    folderID,
 		Filename:    filename,
 		ContentType: contentType,
-		Data:        data,
+		UploadedAt:  time.Now().UTC(),
+		Tags:        tags,
+		ExpiresAt:   expiresAt,
+	}
+
+	if err := f.fileRepo.SaveUserFile(saveCtx, userFile, *version); err != nil {
+		// Don't leave an orphaned reference or usage behind when the metadata can't be written
+		_ = f.fileRepo.ReleaseContent(context.Background(), version)
+		_ = f.quotaRepo.AddUsage(context.Background(), userID, -version.Size, -newFiles)
+		return nil, err
+	}
+
+	// A concurrent upload of the same name may have created the file first
+	if created := userFile.Version == 1; created != (newFiles == 1) {
+		if created {
+			_ = f.quotaRepo.AddUsage(saveCtx, userID, 0, 1)
+		} else {
+			_ = f.quotaRepo.AddUsage(saveCtx, userID, 0, -1)
+		}
+	}
+
+	if scan.Status == domain.ScanInfected {
+		if v, ok := userFile.FindVersion(userFile.Version); ok {
+			_ = f.quarantine(saveCtx, userFile, v, scan.Signature)
+		}
+	}
+
+	// Apply the default retention to the file that just grew; the upload itself already succeeded
+	if expired := expiredVersions(userFile, f.retention, time.Now()); len(expired) > 0 {
+		_ = f.deleteVersions(saveCtx, userFile, expired)
+	}
+
+	// New files are created with their tags; existing ones keep theirs and gain the new ones
+	if merged := mergeTags(userFile.Tags, tags); len(merged) != len(userFile.Tags) {
+		_ = f.fileRepo.SetFileTags(saveCtx, userFile, merged)
+	}
+	// An expiry given with a new version replaces the file's
+	if expiresAt != nil && (userFile.ExpiresAt == nil || !userFile.ExpiresAt.Equal(*expiresAt)) {
+		_ = f.fileRepo.SetFileExpiry(saveCtx, userFile, expiresAt)
 	}
 
-	return f.fileRepo.SaveUserFile(ctx, userFile)
+	// The file can be found without its text, so failing to index it doesn't fail the upload
+	_ = f.indexText(saveCtx, userFile)
+
+	return fileMeta(userFile), nil
 }
 
-func (f *fileUseCase) GetFilesByUserID(ctx context.Context, userID uint) ([]*domain.UserFileMeta, error) {
+func (f *fileUseCase) CheckUpload(ctx context.Context, callerID, userID uint, folderID, filename string, size int64) error {
 	ctx, cancel := context.WithTimeout(ctx, f.timeout)
 	defer cancel()
 
-	files, err := f.fileRepo.GetFilesByUserID(ctx, userID)
+	_, usage, quota, err := f.prepareUpload(ctx, callerID, userID, folderID, filename)
+	if err != nil {
+		return err
+	}
+	if quota.MaxBytes > 0 && usage.Bytes+size > quota.MaxBytes {
+		return domain.ErrQuotaExceeded
+	}
+
+	return nil
+}
+
+// prepareUpload checks that the user exists, the name is valid and the
+// caller may write it, and returns the cleaned name with the user's storage
+// usage and quota.
+func (f *fileUseCase) prepareUpload(ctx context.Context, callerID, userID uint, folderID, filename string) (string, *domain.StorageUsage, domain.StorageQuota, error) {
+	// Check if user exists in MySQL
+	if _, err := f.userRepo.GetUserByID(ctx, userID); err != nil {
+		return "", nil, domain.StorageQuota{}, errors.New("user not found")
+	}
+
+	filename, ok := cleanName(filename)
+	if !ok {
+		return "", nil, domain.StorageQuota{}, domain.ErrInvalidFilename
+	}
+	if err := f.policy.checkName(filename); err != nil {
+		return "", nil, domain.StorageQuota{}, err
+	}
+
+	// Only owners write to their root; elsewhere write access to the folder is needed
+	err := checkUser(callerID, userID)
+	if folderID != "" {
+		err = f.checkFolder(ctx, callerID, userID, folderID)
+	}
+	if errors.Is(err, domain.ErrForbidden) {
+		// Write access to an existing file is enough to upload a new version of it
+		file, lookupErr := f.fileRepo.GetFileByName(ctx, userID, folderID, filename)
+		if lookupErr == nil {
+			err = f.access.checkFile(ctx, callerID, file, domain.PermissionWrite)
+		} else if !errors.Is(lookupErr, domain.ErrFileNotFound) {
+			err = lookupErr
+		}
+	}
+	if err != nil {
+		return "", nil, domain.StorageQuota{}, err
+	}
+
+	usage, quota, err := f.storageUsage(ctx, userID)
+	if err != nil {
+		return "", nil, domain.StorageQuota{}, err
+	}
+
+	return filename, usage, quota, nil
+}
+
+// ResolvePath finds the file at a slash separated path such as
+// /reports/2026/q3.pdf, where every segment but the last names a folder.
+// Paths the caller may not read are not found either, so that resolving
+// doesn't reveal what exists.
+func (f *fileUseCase) ResolvePath(ctx context.Context, callerID, userID uint, path string) (*domain.UserFile, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	if err := f.access.checkSharer(ctx, callerID, userID); errors.Is(err, domain.ErrForbidden) {
+		return nil, domain.ErrFileNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	if len(segments) == 0 {
+		return nil, domain.ErrFileNotFound
+	}
+
+	folderID := ""
+	for _, name := range segments[:len(segments)-1] {
+		folder, err := f.folderRepo.GetFolderByName(ctx, userID, folderID, name)
+		if errors.Is(err, domain.ErrFolderNotFound) {
+			return nil, domain.ErrFileNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		folderID = folder.ID
+	}
+
+	file, err := f.fileRepo.GetFileByName(ctx, userID, folderID, segments[len(segments)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.access.checkFile(ctx, callerID, file, domain.PermissionRead); errors.Is(err, domain.ErrForbidden) {
+		return nil, domain.ErrFileNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// GetFilesByUserID lists the user's files carrying tags. Only the user can
+// list their files.
+func (f *fileUseCase) GetFilesByUserID(ctx context.Context, callerID, userID uint, tags domain.TagFilter) ([]*domain.UserFileMeta, error) {
+	if err := checkUser(callerID, userID); err != nil {
+		return nil, err
+	}
+	var err error
+	if tags.Tags, err = normalizeTags(tags.Tags); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	// Listings never need the version history or content of the files
+	files, _, err := f.fileRepo.SearchFiles(ctx, userID, domain.FileSearch{Tags: tags, Sort: domain.FileSortName})
 	if err != nil {
 		return nil, err
 	}
 
 	var meta []*domain.UserFileMeta
 	for _, file := range files {
-		meta = append(meta, &domain.UserFileMeta{
-			ID:       file.ID,
-			Filename: file.Filename,
-		})
+		meta = append(meta, fileMeta(file))
 	}
 
 	return meta, nil
 }
 
-func (f *fileUseCase) DeleteFilesByUserID(ctx context.Context, userID uint) error {
+// DeleteFilesByUserID moves every file of the user to the trash.
+func (f *fileUseCase) DeleteFilesByUserID(ctx context.Context, callerID, userID uint) error {
+	if err := checkUser(callerID, userID); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	return f.fileRepo.TrashFilesByUserID(ctx, userID, time.Now().UTC())
+}
+
+func (f *fileUseCase) GetStorageUsage(ctx context.Context, callerID, userID uint) (*domain.StorageUsageResponse, error) {
+	if err := checkUser(callerID, userID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	if _, err := f.userRepo.GetUserByID(ctx, userID); err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	usage, quota, err := f.storageUsage(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.StorageUsageResponse{
+		UsedBytes: usage.Bytes,
+		FileCount: usage.Files,
+		MaxBytes:  quota.MaxBytes,
+		MaxFiles:  quota.MaxFiles,
+		Custom:    usage.Quota != nil,
+	}, nil
+}
+
+// SetStorageQuota overrides the default quota of a user. Only admins can
+// change quotas, their own included.
+func (f *fileUseCase) SetStorageQuota(ctx context.Context, callerID, userID uint, quota *domain.StorageQuota) error {
+	if !slices.Contains(f.quotaAdmins, callerID) {
+		return domain.ErrForbidden
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	if _, err := f.userRepo.GetUserByID(ctx, userID); err != nil {
+		return errors.New("user not found")
+	}
+
+	return f.quotaRepo.SetQuota(ctx, userID, quota)
+}
+
+// storageUsage returns the user's current usage and the quota that applies
+// to them, which is their override or the configured default.
+func (f *fileUseCase) storageUsage(ctx context.Context, userID uint) (*domain.StorageUsage, domain.StorageQuota, error) {
+	usage, err := f.quotaRepo.GetUsage(ctx, userID)
+	if err != nil {
+		return nil, domain.StorageQuota{}, err
+	}
+
+	if usage.Quota != nil {
+		return usage, *usage.Quota, nil
+	}
+	return usage, f.quota, nil
+}
+
+func (f *fileUseCase) GetFileVersions(ctx context.Context, callerID uint, id string) ([]domain.FileVersion, error) {
+	file, err := f.GetFileByID(ctx, callerID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return file.Versions, nil
+}
+
+func (f *fileUseCase) DownloadFileVersion(ctx context.Context, callerID uint, id string, version int) (*domain.UserFile, io.ReadSeekCloser, error) {
+	file, err := f.GetFileByID(ctx, callerID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	v, ok := file.FindVersion(version)
+	if !ok {
+		return nil, nil, domain.ErrFileVersionNotFound
+	}
+	if err := v.ScanStatus.Check(); err != nil {
+		return nil, nil, err
+	}
+
+	versionFile := file.AtVersion(v)
+	content, err := f.fileRepo.OpenFileContent(ctx, versionFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return versionFile, content, nil
+}
+
+func (f *fileUseCase) RestoreFileVersion(ctx context.Context, callerID uint, id string, version int) (*domain.UserFileMeta, error) {
 	ctx, cancel := context.WithTimeout(ctx, f.timeout)
 	defer cancel()
 
-	return f.fileRepo.DeleteFilesByUserID(ctx, userID)
+	file, err := f.file(ctx, callerID, id, domain.PermissionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != file.Version {
+		v, ok := file.FindVersion(version)
+		if !ok {
+			return nil, domain.ErrFileVersionNotFound
+		}
+		if v.ScanStatus == domain.ScanInfected {
+			return nil, domain.ErrFileQuarantined
+		}
+
+		// The restored copy counts towards the quota like any other version
+		_, quota, err := f.storageUsage(ctx, file.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.quotaRepo.ReserveUsage(ctx, file.UserID, v.Size, 0, quota); err != nil {
+			return nil, err
+		}
+
+		if err := f.fileRepo.RestoreFileVersion(ctx, file, version); err != nil {
+			_ = f.quotaRepo.AddUsage(context.Background(), file.UserID, -v.Size, 0)
+			return nil, err
+		}
+		_ = f.indexText(ctx, file)
+	}
+
+	return fileMeta(file), nil
+}
+
+func (f *fileUseCase) PruneFileVersions(ctx context.Context, callerID, userID uint, retention domain.VersionRetention) (int, error) {
+	if err := checkUser(callerID, userID); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	files, err := f.fileRepo.GetFilesByUserID(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	now := time.Now()
+	for _, file := range files {
+		expired := expiredVersions(file, retention, now)
+		if len(expired) == 0 {
+			continue
+		}
+		if err := f.deleteVersions(ctx, file, expired); err != nil {
+			return pruned, err
+		}
+		pruned += len(expired)
+	}
+
+	return pruned, nil
+}
+
+// file returns the file with the given ID if the caller has the required
+// permission on it.
+func (f *fileUseCase) file(ctx context.Context, callerID uint, id string, required domain.Permission) (*domain.UserFile, error) {
+	file, err := f.fileRepo.GetFileByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.access.checkFile(ctx, callerID, file, required); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// checkFolder verifies that folderID is a folder of the user that the caller
+// may write to.
+func (f *fileUseCase) checkFolder(ctx context.Context, callerID, userID uint, folderID string) error {
+	folder, err := f.folderRepo.GetFolderByID(ctx, folderID)
+	if err != nil {
+		return err
+	}
+	if folder.UserID != userID {
+		return domain.ErrFolderNotFound
+	}
+	return f.access.checkFolder(ctx, callerID, folder, domain.PermissionWrite)
+}
+
+// checkUpdateType applies the content policy to a rename or content type
+// change. The stored type was detected from the content, so it can only be
+// replaced by a more general type, such as application/zip for a .docx file.
+func (f *fileUseCase) checkUpdateType(file *domain.UserFile, update domain.FileUpdate) error {
+	if update.Filename == nil && update.ContentType == nil {
+		return nil
+	}
+
+	current := lookupContentType(file.ContentType)
+	if update.ContentType != nil {
+		mediaType, _, err := mime.ParseMediaType(*update.ContentType)
+		if err != nil {
+			return domain.ErrContentTypeMismatch
+		}
+		if mediaType != "application/octet-stream" && !isType(current, mediaType) {
+			return domain.ErrContentTypeMismatch
+		}
+	}
+
+	filename := file.Filename
+	if update.Filename != nil {
+		filename = *update.Filename
+	}
+	return f.policy.checkExtension(filename, current)
+}
+
+// deleteVersions removes versions from file and releases their usage.
+func (f *fileUseCase) deleteVersions(ctx context.Context, file *domain.UserFile, numbers []int) error {
+	var size int64
+	for _, n := range numbers {
+		if v, ok := file.FindVersion(n); ok {
+			size += v.Size
+		}
+	}
+
+	if err := f.fileRepo.DeleteFileVersions(ctx, file, numbers); err != nil {
+		return err
+	}
+
+	return f.quotaRepo.AddUsage(ctx, file.UserID, -size, 0)
+}
+
+// expiredVersions returns the numbers of the versions of file that fall
+// outside retention. The current version is never returned and counts towards
+// MaxVersions.
+func expiredVersions(file *domain.UserFile, retention domain.VersionRetention, now time.Time) []int {
+	var old []domain.FileVersion
+	for _, v := range file.Versions {
+		if v.Number != file.Version {
+			old = append(old, v)
+		}
+	}
+	sort.Slice(old, func(i, j int) bool { return old[i].Number > old[j].Number })
+
+	cutoff := now.AddDate(0, 0, -retention.MaxAgeDays)
+
+	var expired []int
+	for i, v := range old {
+		tooMany := retention.MaxVersions > 0 && i+1 >= retention.MaxVersions
+		tooOld := retention.MaxAgeDays > 0 && v.UploadedAt.Before(cutoff)
+		if tooMany || tooOld {
+			expired = append(expired, v.Number)
+		}
+	}
+
+	return expired
+}
+
+func fileMeta(file *domain.UserFile) *domain.UserFileMeta {
+	return &domain.UserFileMeta{
+		ID:          file.ID,
+		FolderID:    file.FolderID,
+		Filename:    file.Filename,
+		ContentType: file.ContentType,
+		Size:        file.Size,
+		Version:     file.Version,
+		UploadedAt:  file.UploadedAt,
+		Tags:        file.Tags,
+		ExpiresAt:   file.ExpiresAt,
+		Digest:      file.Digest,
+		ScanStatus:  file.ScanStatus,
+		Corrupt:     file.Corrupt,
+		// Generation runs in the background, so pending images have none yet
+		HasThumbnail: file.ThumbnailStatus == domain.ThumbnailReady && len(file.Thumbnails) > 0,
+		DeletedAt:    file.DeletedAt,
+	}
+}
+
+// cleanName trims a file or folder name and reports whether it is usable as
+// a path segment.
+func cleanName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return "", false
+	}
+	return name, true
+}
+
+// quotaReader fails with ErrQuotaExceeded once more than remaining bytes
+// have been read.
+type quotaReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (q *quotaReader) Read(p []byte) (int, error) {
+	n, err := q.reader.Read(p)
+	q.remaining -= int64(n)
+	if q.remaining < 0 {
+		return n, domain.ErrQuotaExceeded
+	}
+	return n, err
 }
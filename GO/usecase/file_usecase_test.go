@@ -1,8 +1,16 @@
 package usecase
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,31 +23,97 @@ import (
 func TestUploadFile_Success(t *testing.T) {
 	mockUserRepo := new(mocks.UserRepository)
 	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
 
-	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, 2*time.Second)
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	version := &domain.FileVersion{
+		BlobID:     "blob123",
+		Digest:     "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7",
+		Size:       4,
+		ScanStatus: domain.ScanClean,
+	}
 
 	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
-	mockFileRepo.On("SaveUserFile", mock.Anything, mock.Anything).Return(nil)
+	mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{UserID: 1}, nil)
+	mockScanner.On("Scan", mock.Anything, mock.Anything).Return(&domain.ScanResult{Status: domain.ScanClean}, nil)
+	mockFileRepo.On("StoreContent", mock.Anything, mock.Anything, mock.Anything).Return(version, nil)
+	mockFileRepo.On("GetFileByName", mock.Anything, uint(1), "", "file.txt").Return(nil, domain.ErrFileNotFound)
+	mockQuotaRepo.On("ReserveUsage", mock.Anything, uint(1), int64(4), 1, domain.StorageQuota{}).Return(nil)
+	mockFileRepo.On("SaveUserFile", mock.Anything, mock.Anything, *version).
+		Run(func(args mock.Arguments) {
+			file := args.Get(1).(*domain.UserFile)
+			file.ID = "abc123"
+			file.Version = 1
+			file.Digest = version.Digest
+		}).
+		Return(nil)
+	mockFileRepo.On("OpenFileContent", mock.Anything, mock.Anything).Return(mocks.NewContent("data"), nil)
+	mockFileRepo.On("SetFileText", mock.Anything, mock.Anything, "data").Return(nil)
 
-	err := useCase.UploadFile(context.Background(), 1, "file.txt", "text/plain", []byte("data"))
+	meta, err := useCase.UploadFile(context.Background(), 1, 1, "", "file.txt", "text/plain", nil, nil, nil, strings.NewReader("data"))
 
 	require.NoError(t, err)
+	require.Equal(t, "abc123", meta.ID)
+	require.Equal(t, "file.txt", meta.Filename)
+	require.Equal(t, "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7", meta.Digest)
 	mockUserRepo.AssertExpectations(t)
 	mockFileRepo.AssertExpectations(t)
+	mockQuotaRepo.AssertExpectations(t)
+}
+
+func TestUploadFile_QuotaExceeded(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	env := getTestEnv()
+	env.FileQuotaBytes = 10
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, env)
+
+	version := &domain.FileVersion{BlobID: "blob123", Digest: "digest", Size: 4, ScanStatus: domain.ScanClean}
+	quota := domain.StorageQuota{MaxBytes: 10}
+
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{UserID: 1, Bytes: 8}, nil)
+	mockScanner.On("Scan", mock.Anything, mock.Anything).Return(&domain.ScanResult{Status: domain.ScanClean}, nil)
+	mockFileRepo.On("StoreContent", mock.Anything, mock.Anything, mock.Anything).Return(version, nil)
+	mockFileRepo.On("GetFileByName", mock.Anything, uint(1), "", "file.txt").Return(&domain.UserFile{ID: "abc123"}, nil)
+	// Another upload used up the quota after the content was stored
+	mockQuotaRepo.On("ReserveUsage", mock.Anything, uint(1), int64(4), 0, quota).Return(domain.ErrQuotaExceeded)
+	mockFileRepo.On("ReleaseContent", mock.Anything, version).Return(nil)
+
+	meta, err := useCase.UploadFile(context.Background(), 1, 1, "", "file.txt", "text/plain", nil, nil, nil, strings.NewReader("data"))
+
+	require.ErrorIs(t, err, domain.ErrQuotaExceeded)
+	require.Nil(t, meta)
+	mockFileRepo.AssertExpectations(t)
+	mockFileRepo.AssertNotCalled(t, "SaveUserFile", mock.Anything, mock.Anything, mock.Anything)
 }
 
 func TestUploadFile_UserNotFound(t *testing.T) {
 	mockUserRepo := new(mocks.UserRepository)
 	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
 
-	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, 2*time.Second)
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
 
 	// Correctly simulate user not found
 	mockUserRepo.On("GetUserByID", mock.Anything, mock.Anything).Return(nil, errors.New("user not found"))
 
-	err := useCase.UploadFile(context.Background(), 2, "file.txt", "text/plain", []byte("data"))
+	meta, err := useCase.UploadFile(context.Background(), 1, 2, "", "file.txt", "text/plain", nil, nil, nil, strings.NewReader("data"))
 
 	require.Error(t, err)
+	require.Nil(t, meta)
 	require.Equal(t, "user not found", err.Error())
 
 	mockUserRepo.AssertExpectations(t)
@@ -48,17 +122,22 @@ func TestUploadFile_UserNotFound(t *testing.T) {
 func TestGetFileByID_Success(t *testing.T) {
 	mockUserRepo := new(mocks.UserRepository)
 	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
 
-	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, 2*time.Second)
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
 
 	expectedFile := &domain.UserFile{
 		ID:       "abc123",
+		UserID:   1,
 		Filename: "file.txt",
 	}
 
 	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(expectedFile, nil)
 
-	result, err := useCase.GetFileByID(context.Background(), "abc123")
+	result, err := useCase.GetFileByID(context.Background(), 1, "abc123")
 
 	require.NoError(t, err)
 	require.Equal(t, expectedFile, result)
@@ -68,15 +147,1793 @@ func TestGetFileByID_Success(t *testing.T) {
 func TestGetFileByID_NotFound(t *testing.T) {
 	mockUserRepo := new(mocks.UserRepository)
 	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
 
-	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, 2*time.Second)
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
 
 	mockFileRepo.On("GetFileByID", mock.Anything, "notfound").Return(nil, errors.New("not found"))
 
-	result, err := useCase.GetFileByID(context.Background(), "notfound")
+	result, err := useCase.GetFileByID(context.Background(), 1, "notfound")
 
 	require.Error(t, err)
 	require.Nil(t, result)
 	require.EqualError(t, err, "not found")
 	mockFileRepo.AssertExpectations(t)
 }
+
+func TestDownloadFile_Success(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	expectedFile := &domain.UserFile{
+		ID:         "abc123",
+		UserID:     1,
+		Filename:   "file.txt",
+		BlobID:     "blob123",
+		Size:       4,
+		ScanStatus: domain.ScanClean,
+	}
+
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(expectedFile, nil)
+	mockFileRepo.On("OpenFileContent", mock.Anything, expectedFile).Return(mocks.NewContent("data"), nil)
+
+	file, content, err := useCase.DownloadFile(context.Background(), 1, "abc123")
+
+	require.NoError(t, err)
+	require.Equal(t, expectedFile, file)
+	data, err := io.ReadAll(content)
+	require.NoError(t, err)
+	require.Equal(t, "data", string(data))
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestDownloadFile_NotFound(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	mockFileRepo.On("GetFileByID", mock.Anything, "notfound").Return(nil, errors.New("not found"))
+
+	file, content, err := useCase.DownloadFile(context.Background(), 1, "notfound")
+
+	require.EqualError(t, err, "not found")
+	require.Nil(t, file)
+	require.Nil(t, content)
+	mockFileRepo.AssertNotCalled(t, "OpenFileContent", mock.Anything, mock.Anything)
+}
+
+func TestDownloadFileVersion_NotFound(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(&domain.UserFile{
+		ID:       "abc123",
+		UserID:   1,
+		Version:  1,
+		Versions: []domain.FileVersion{{Number: 1, BlobID: "blob1"}},
+	}, nil)
+
+	_, _, err := useCase.DownloadFileVersion(context.Background(), 1, "abc123", 2)
+
+	require.ErrorIs(t, err, domain.ErrFileVersionNotFound)
+	mockFileRepo.AssertNotCalled(t, "OpenFileContent", mock.Anything, mock.Anything)
+}
+
+func TestRestoreFileVersion_Success(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	file := &domain.UserFile{
+		ID:       "abc123",
+		UserID:   1,
+		Filename: "file.txt",
+		Version:  2,
+		Versions: []domain.FileVersion{{Number: 1, BlobID: "blob1"}, {Number: 2, BlobID: "blob2"}},
+	}
+
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(file, nil)
+	mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{}, nil)
+	mockQuotaRepo.On("ReserveUsage", mock.Anything, uint(1), int64(0), 0, domain.StorageQuota{}).Return(nil)
+	mockFileRepo.On("RestoreFileVersion", mock.Anything, file, 1).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.UserFile).Version = 3
+		}).
+		Return(nil)
+	// The restored version has no text to search, so the old one is removed
+	mockFileRepo.On("SetFileText", mock.Anything, file, "").Return(nil)
+
+	meta, err := useCase.RestoreFileVersion(context.Background(), 1, "abc123", 1)
+
+	require.NoError(t, err)
+	require.Equal(t, 3, meta.Version)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestPruneFileVersions_KeepsCurrentAndNewest(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	now := time.Now()
+	file := &domain.UserFile{
+		ID:      "abc123",
+		UserID:  1,
+		Version: 4,
+		Versions: []domain.FileVersion{
+			{Number: 1, Size: 3, UploadedAt: now.AddDate(0, 0, -40)},
+			{Number: 2, Size: 5, UploadedAt: now.AddDate(0, 0, -20)},
+			{Number: 3, UploadedAt: now.AddDate(0, 0, -10)},
+			{Number: 4, UploadedAt: now.AddDate(0, 0, -50)},
+		},
+	}
+
+	mockFileRepo.On("GetFilesByUserID", mock.Anything, uint(1)).Return([]*domain.UserFile{file}, nil)
+	// Version 4 is current even though it is the oldest; version 3 is the newest old version
+	mockFileRepo.On("DeleteFileVersions", mock.Anything, file, []int{2, 1}).Return(nil)
+	mockQuotaRepo.On("AddUsage", mock.Anything, uint(1), int64(-8), 0).Return(nil)
+
+	pruned, err := useCase.PruneFileVersions(context.Background(), 1, 1, domain.VersionRetention{MaxVersions: 2, MaxAgeDays: 30})
+
+	require.NoError(t, err)
+	require.Equal(t, 2, pruned)
+	mockFileRepo.AssertExpectations(t)
+	mockQuotaRepo.AssertExpectations(t)
+}
+
+func TestGetStorageUsage_Override(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	env := getTestEnv()
+	env.FileQuotaBytes = 100
+	env.FileQuotaFiles = 5
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, env)
+
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{
+		UserID: 1,
+		Bytes:  40,
+		Files:  2,
+		Quota:  &domain.StorageQuota{MaxBytes: 1000},
+	}, nil)
+
+	usage, err := useCase.GetStorageUsage(context.Background(), 1, 1)
+
+	require.NoError(t, err)
+	require.Equal(t, &domain.StorageUsageResponse{
+		UsedBytes: 40,
+		FileCount: 2,
+		MaxBytes:  1000,
+		MaxFiles:  0,
+		Custom:    true,
+	}, usage)
+}
+
+func TestUpdateFile_Success(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	file := &domain.UserFile{ID: "abc123", UserID: 1, Filename: "file.txt", Version: 1}
+	filename := " report.txt "
+	owner := "finance"
+
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(file, nil)
+	mockFileRepo.On("UpdateFile", mock.Anything, file, mock.MatchedBy(func(update domain.FileUpdate) bool {
+		return *update.Filename == "report.txt" && *update.Metadata["owner"] == "finance"
+	})).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.UserFile).Filename = "report.txt"
+		}).
+		Return(nil)
+
+	result, err := useCase.UpdateFile(context.Background(), 1, "abc123", domain.FileUpdate{
+		Filename: &filename,
+		Metadata: map[string]*string{"owner": &owner},
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, "report.txt", result.Filename)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestUpdateFile_InvalidMetadataKey(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	value := "x"
+	result, err := useCase.UpdateFile(context.Background(), 1, "abc123", domain.FileUpdate{
+		Metadata: map[string]*string{"a.b": &value},
+	})
+
+	require.ErrorIs(t, err, domain.ErrInvalidMetadataKey)
+	require.Nil(t, result)
+	mockFileRepo.AssertNotCalled(t, "UpdateFile", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeleteFile_MovesToTrash(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	file := &domain.UserFile{
+		ID:       "abc123",
+		UserID:   1,
+		Version:  2,
+		Versions: []domain.FileVersion{{Number: 1, Size: 3}, {Number: 2, Size: 5}},
+	}
+
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(file, nil)
+	mockFileRepo.On("TrashFile", mock.Anything, file, mock.Anything).Return(nil)
+
+	err := useCase.DeleteFile(context.Background(), 1, "abc123")
+
+	require.NoError(t, err)
+	mockFileRepo.AssertExpectations(t)
+	// Usage and grants stay until the trash is emptied
+	mockFileRepo.AssertNotCalled(t, "DeleteFile", mock.Anything, mock.Anything)
+	mockQuotaRepo.AssertNotCalled(t, "AddUsage", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockGrantRepo.AssertNotCalled(t, "DeleteResourceGrants", mock.Anything, mock.Anything)
+}
+
+func TestDeleteFile_NotFound(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	mockFileRepo.On("GetFileByID", mock.Anything, "missing").Return(nil, domain.ErrFileNotFound)
+
+	err := useCase.DeleteFile(context.Background(), 1, "missing")
+
+	require.ErrorIs(t, err, domain.ErrFileNotFound)
+	mockFileRepo.AssertNotCalled(t, "DeleteFile", mock.Anything, mock.Anything)
+}
+
+func TestUploadFile_FolderOfOtherUser(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockFolderRepo.On("GetFolderByID", mock.Anything, "folder2").Return(&domain.Folder{ID: "folder2", UserID: 2}, nil)
+
+	meta, err := useCase.UploadFile(context.Background(), 1, 1, "folder2", "file.txt", "text/plain", nil, nil, nil, strings.NewReader("data"))
+
+	require.ErrorIs(t, err, domain.ErrFolderNotFound)
+	require.Nil(t, meta)
+	mockFileRepo.AssertNotCalled(t, "StoreContent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestResolvePath_Success(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	expectedFile := &domain.UserFile{ID: "abc123", UserID: 1, FolderID: "f2", Filename: "q3.pdf"}
+
+	mockFolderRepo.On("GetFolderByName", mock.Anything, uint(1), "", "reports").Return(&domain.Folder{ID: "f1"}, nil)
+	mockFolderRepo.On("GetFolderByName", mock.Anything, uint(1), "f1", "2026").Return(&domain.Folder{ID: "f2"}, nil)
+	mockFileRepo.On("GetFileByName", mock.Anything, uint(1), "f2", "q3.pdf").Return(expectedFile, nil)
+
+	file, err := useCase.ResolvePath(context.Background(), 1, 1, "/reports/2026/q3.pdf")
+
+	require.NoError(t, err)
+	require.Equal(t, expectedFile, file)
+	mockFolderRepo.AssertExpectations(t)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestResolvePath_MissingFolder(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	mockFolderRepo.On("GetFolderByName", mock.Anything, uint(1), "", "reports").Return(nil, domain.ErrFolderNotFound)
+
+	file, err := useCase.ResolvePath(context.Background(), 1, 1, "/reports/q3.pdf")
+
+	require.ErrorIs(t, err, domain.ErrFileNotFound)
+	require.Nil(t, file)
+}
+
+func TestResolvePath_NothingShared(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	mockGrantRepo.On("GetGrantsByUserID", mock.Anything, uint(2)).Return([]*domain.Grant{{OwnerID: 3}}, nil)
+
+	file, err := useCase.ResolvePath(context.Background(), 2, 1, "/reports/q3.pdf")
+
+	require.ErrorIs(t, err, domain.ErrFileNotFound)
+	require.Nil(t, file)
+	mockFolderRepo.AssertNotCalled(t, "GetFolderByName", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestResolvePath_NoGrantOnFile(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	file := &domain.UserFile{ID: "abc123", UserID: 1, Filename: "q3.pdf"}
+
+	mockGrantRepo.On("GetGrantsByUserID", mock.Anything, uint(2)).Return([]*domain.Grant{{OwnerID: 1}}, nil)
+	mockFileRepo.On("GetFileByName", mock.Anything, uint(1), "", "q3.pdf").Return(file, nil)
+	mockGrantRepo.On("GetUserGrants", mock.Anything, uint(2), []domain.Resource{{Type: domain.ResourceFile, ID: "abc123"}}).Return(nil, nil)
+
+	result, err := useCase.ResolvePath(context.Background(), 2, 1, "/q3.pdf")
+
+	// Existing files the caller can't read look the same as missing ones
+	require.ErrorIs(t, err, domain.ErrFileNotFound)
+	require.Nil(t, result)
+}
+
+func TestGetFileByID_GrantOnParentFolder(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	expectedFile := &domain.UserFile{ID: "abc123", UserID: 1, FolderID: "f2", Filename: "q3.pdf"}
+
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(expectedFile, nil)
+	mockFolderRepo.On("GetFolderByID", mock.Anything, "f2").Return(&domain.Folder{ID: "f2", UserID: 1, ParentID: "f1"}, nil)
+	mockFolderRepo.On("GetFolderByID", mock.Anything, "f1").Return(&domain.Folder{ID: "f1", UserID: 1}, nil)
+	// The grant on the top folder covers every file below it
+	mockGrantRepo.On("GetUserGrants", mock.Anything, uint(2), []domain.Resource{
+		{Type: domain.ResourceFile, ID: "abc123"},
+		{Type: domain.ResourceFolder, ID: "f2"},
+		{Type: domain.ResourceFolder, ID: "f1"},
+	}).Return([]*domain.Grant{{UserID: 2, Permission: domain.PermissionRead}}, nil)
+
+	file, err := useCase.GetFileByID(context.Background(), 2, "abc123")
+
+	require.NoError(t, err)
+	require.Equal(t, expectedFile, file)
+	mockGrantRepo.AssertExpectations(t)
+}
+
+func TestUpdateFile_ReadGrantForbidden(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	filename := "report.txt"
+
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(&domain.UserFile{ID: "abc123", UserID: 1}, nil)
+	mockGrantRepo.On("GetUserGrants", mock.Anything, uint(2), []domain.Resource{{Type: domain.ResourceFile, ID: "abc123"}}).
+		Return([]*domain.Grant{{UserID: 2, Permission: domain.PermissionRead}}, nil)
+
+	result, err := useCase.UpdateFile(context.Background(), 2, "abc123", domain.FileUpdate{Filename: &filename})
+
+	require.ErrorIs(t, err, domain.ErrForbidden)
+	require.Nil(t, result)
+	mockFileRepo.AssertNotCalled(t, "UpdateFile", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUploadFile_WriteGrantOnFolder(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	version := &domain.FileVersion{BlobID: "blob123", Digest: "digest", Size: 4, ScanStatus: domain.ScanClean}
+
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockFolderRepo.On("GetFolderByID", mock.Anything, "f1").Return(&domain.Folder{ID: "f1", UserID: 1}, nil)
+	mockGrantRepo.On("GetUserGrants", mock.Anything, uint(2), []domain.Resource{{Type: domain.ResourceFolder, ID: "f1"}}).
+		Return([]*domain.Grant{{UserID: 2, Permission: domain.PermissionWrite}}, nil)
+	mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{UserID: 1}, nil)
+	mockScanner.On("Scan", mock.Anything, mock.Anything).Return(&domain.ScanResult{Status: domain.ScanClean}, nil)
+	mockFileRepo.On("StoreContent", mock.Anything, mock.Anything, mock.Anything).Return(version, nil)
+	mockFileRepo.On("GetFileByName", mock.Anything, uint(1), "f1", "file.txt").Return(nil, domain.ErrFileNotFound)
+	// The upload counts towards the owner of the folder, not the caller
+	mockQuotaRepo.On("ReserveUsage", mock.Anything, uint(1), int64(4), 1, domain.StorageQuota{}).Return(nil)
+	mockFileRepo.On("SaveUserFile", mock.Anything, mock.MatchedBy(func(file *domain.UserFile) bool {
+		return file.UserID == 1 && file.FolderID == "f1"
+	}), *version).
+		Run(func(args mock.Arguments) {
+			file := args.Get(1).(*domain.UserFile)
+			file.ID = "abc123"
+			file.Version = 1
+		}).
+		Return(nil)
+	mockFileRepo.On("OpenFileContent", mock.Anything, mock.Anything).Return(mocks.NewContent("data"), nil)
+	mockFileRepo.On("SetFileText", mock.Anything, mock.Anything, "data").Return(nil)
+
+	meta, err := useCase.UploadFile(context.Background(), 2, 1, "f1", "file.txt", "text/plain", nil, nil, nil, strings.NewReader("data"))
+
+	require.NoError(t, err)
+	require.Equal(t, "abc123", meta.ID)
+	mockFileRepo.AssertExpectations(t)
+	mockQuotaRepo.AssertExpectations(t)
+}
+
+func TestGetFilesByUserID_OtherUser(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	files, err := useCase.GetFilesByUserID(context.Background(), 2, 1, domain.TagFilter{})
+
+	require.ErrorIs(t, err, domain.ErrForbidden)
+	require.Nil(t, files)
+	mockFileRepo.AssertNotCalled(t, "SearchFiles", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSearchFiles_Defaults(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	uploadedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	files := []*domain.UserFile{{ID: "abc123", UserID: 1, Filename: "report.pdf", ContentType: "application/pdf", Size: 42, UploadedAt: uploadedAt}}
+
+	search := domain.FileSearch{Name: "*.pdf", Sort: domain.FileSortName, Limit: defaultSearchLimit}
+	mockFileRepo.On("SearchFiles", mock.Anything, uint(1), search).Return(files, "next", nil)
+
+	result, err := useCase.SearchFiles(context.Background(), 1, 1, domain.FileSearch{Name: "*.pdf"})
+
+	require.NoError(t, err)
+	require.Equal(t, "next", result.NextCursor)
+	require.Len(t, result.Files, 1)
+	require.Equal(t, "application/pdf", result.Files[0].ContentType)
+	require.Equal(t, uploadedAt, result.Files[0].UploadedAt)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestSearchFiles_CapsLimit(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	search := domain.FileSearch{Sort: domain.FileSortSize, Desc: true, Limit: maxSearchLimit}
+	mockFileRepo.On("SearchFiles", mock.Anything, uint(1), search).Return([]*domain.UserFile{}, "", nil)
+
+	result, err := useCase.SearchFiles(context.Background(), 1, 1, domain.FileSearch{Sort: domain.FileSortSize, Desc: true, Limit: 10000})
+
+	require.NoError(t, err)
+	require.Empty(t, result.Files)
+	require.Empty(t, result.NextCursor)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestSearchFiles_InvalidSearch(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	minSize, maxSize := int64(10), int64(5)
+	after := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, search := range []domain.FileSearch{
+		{Sort: "owner"},
+		{MinSize: &minSize, MaxSize: &maxSize},
+		{UploadedAfter: &after, UploadedBefore: &before},
+		{Limit: -1},
+	} {
+		result, err := useCase.SearchFiles(context.Background(), 1, 1, search)
+
+		require.ErrorIs(t, err, domain.ErrInvalidSearch)
+		require.Nil(t, result)
+	}
+	mockFileRepo.AssertNotCalled(t, "SearchFiles", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGenerateThumbnails_Success(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 600, 300))))
+
+	file := &domain.UserFile{ID: "abc123", UserID: 1, ContentType: "image/png", Version: 1, ThumbnailStatus: domain.ThumbnailPending}
+
+	mockFileRepo.On("GetPendingThumbnails", mock.Anything, thumbnailBatch).Return([]*domain.UserFile{file}, nil)
+	mockFileRepo.On("OpenFileContent", mock.Anything, file).Return(mocks.NewContent(encoded.String()), nil)
+	mockFileRepo.On("StoreThumbnail", mock.Anything, file, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			thumbnail := args.Get(2).(*domain.Thumbnail)
+			thumbnail.BlobID = "thumb-" + string(thumbnail.Size)
+		}).
+		Return(nil)
+	mockFileRepo.On("SetThumbnails", mock.Anything, file, domain.ThumbnailReady, mock.Anything).Return(nil)
+
+	generated, err := useCase.GenerateThumbnails(context.Background())
+
+	require.NoError(t, err)
+	require.Equal(t, 1, generated)
+
+	thumbnails := mockFileRepo.Calls[len(mockFileRepo.Calls)-1].Arguments.Get(3).([]domain.Thumbnail)
+	require.Len(t, thumbnails, 3)
+	require.Equal(t, domain.ThumbnailSmall, thumbnails[0].Size)
+	require.Equal(t, 128, thumbnails[0].Width)
+	require.Equal(t, 64, thumbnails[0].Height)
+	require.Equal(t, 512, thumbnails[2].Width)
+	require.Equal(t, "image/png", thumbnails[2].ContentType)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestGenerateThumbnails_InvalidImage(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	file := &domain.UserFile{ID: "abc123", UserID: 1, ContentType: "image/jpeg", Version: 1, ThumbnailStatus: domain.ThumbnailPending}
+
+	mockFileRepo.On("GetPendingThumbnails", mock.Anything, thumbnailBatch).Return([]*domain.UserFile{file}, nil)
+	mockFileRepo.On("OpenFileContent", mock.Anything, file).Return(mocks.NewContent("not an image"), nil)
+	mockFileRepo.On("SetThumbnails", mock.Anything, file, domain.ThumbnailFailed, []domain.Thumbnail(nil)).Return(nil)
+
+	generated, err := useCase.GenerateThumbnails(context.Background())
+
+	require.NoError(t, err)
+	require.Equal(t, 1, generated)
+	mockFileRepo.AssertExpectations(t)
+	mockFileRepo.AssertNotCalled(t, "StoreThumbnail", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetThumbnail_Pending(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	file := &domain.UserFile{ID: "abc123", UserID: 1, ContentType: "image/png", ThumbnailStatus: domain.ThumbnailPending}
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(file, nil)
+
+	thumbnail, content, err := useCase.GetThumbnail(context.Background(), 1, "abc123", domain.ThumbnailSmall)
+
+	require.ErrorIs(t, err, domain.ErrThumbnailNotFound)
+	require.Nil(t, thumbnail)
+	require.Nil(t, content)
+	require.False(t, fileMeta(file).HasThumbnail)
+	mockFileRepo.AssertNotCalled(t, "OpenThumbnail", mock.Anything, mock.Anything)
+}
+
+func TestUploadFile_DisguisedContentType(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{UserID: 1}, nil)
+
+	meta, err := useCase.UploadFile(context.Background(), 1, 1, "", "cat.png", "image/png", nil, nil, nil, strings.NewReader("<html><script>alert(1)</script></html>"))
+
+	require.ErrorIs(t, err, domain.ErrContentTypeMismatch)
+	require.Nil(t, meta)
+	mockFileRepo.AssertNotCalled(t, "StoreContent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUploadFile_StoresDetectedType(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	var encoded bytes.Buffer
+	require.NoError(t, png.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 2, 2))))
+	version := &domain.FileVersion{BlobID: "blob123", Digest: "digest", Size: int64(encoded.Len()), ScanStatus: domain.ScanClean}
+
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{UserID: 1}, nil)
+	mockScanner.On("Scan", mock.Anything, mock.Anything).Return(&domain.ScanResult{Status: domain.ScanClean}, nil)
+	mockFileRepo.On("StoreContent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			// The sniffed bytes must still reach storage
+			stored, err := io.ReadAll(args.Get(1).(io.Reader))
+			require.NoError(t, err)
+			require.Equal(t, encoded.Bytes(), stored)
+		}).
+		Return(version, nil)
+	mockFileRepo.On("GetFileByName", mock.Anything, uint(1), "", "pixel.png").Return(nil, domain.ErrFileNotFound)
+	mockQuotaRepo.On("ReserveUsage", mock.Anything, uint(1), version.Size, 1, domain.StorageQuota{}).Return(nil)
+	mockFileRepo.On("SaveUserFile", mock.Anything, mock.MatchedBy(func(file *domain.UserFile) bool {
+		return file.ContentType == "image/png"
+	}), *version).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.UserFile).Version = 1
+		}).
+		Return(nil)
+
+	_, err := useCase.UploadFile(context.Background(), 1, 1, "", "pixel.png", "application/octet-stream", nil, nil, nil, bytes.NewReader(encoded.Bytes()))
+
+	require.NoError(t, err)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestUploadFile_CompressionByType(t *testing.T) {
+	var pixel bytes.Buffer
+	require.NoError(t, png.Encode(&pixel, image.NewRGBA(image.Rect(0, 0, 2, 2))))
+
+	env := getTestEnv()
+	// The first matching rule wins; unknown codecs are ignored
+	env.FileCompressionTypes = "text/csv=zstd; text/*=gzip; image/*=br"
+
+	for _, tc := range []struct {
+		filename string
+		content  []byte
+		encoding string
+	}{
+		{"report.csv", []byte("id,name\n1,widget\n2,gadget\n"), "zstd"},
+		{"server.log", []byte("GET /health 200\n"), "gzip"},
+		{"pixel.png", pixel.Bytes(), ""},
+	} {
+		mockUserRepo := new(mocks.UserRepository)
+		mockFileRepo := new(mocks.FileRepository)
+		mockQuotaRepo := new(mocks.QuotaRepository)
+		mockScanner := new(mocks.Scanner)
+
+		useCase := NewFileUseCase(mockUserRepo, mockFileRepo, new(mocks.FolderRepository), mockQuotaRepo, new(mocks.GrantRepository), mockScanner, nil, 2*time.Second, env)
+
+		version := &domain.FileVersion{BlobID: "blob123", Digest: "digest", Size: int64(len(tc.content)), Encoding: tc.encoding, ScanStatus: domain.ScanClean}
+
+		mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+		mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{UserID: 1}, nil)
+		mockScanner.On("Scan", mock.Anything, mock.Anything).Return(&domain.ScanResult{Status: domain.ScanClean}, nil)
+		mockFileRepo.On("StoreContent", mock.Anything, mock.Anything, tc.encoding).Return(version, nil)
+		mockFileRepo.On("GetFileByName", mock.Anything, uint(1), "", tc.filename).Return(nil, domain.ErrFileNotFound)
+		mockQuotaRepo.On("ReserveUsage", mock.Anything, uint(1), version.Size, 1, domain.StorageQuota{}).Return(nil)
+		mockFileRepo.On("SaveUserFile", mock.Anything, mock.Anything, *version).
+			Run(func(args mock.Arguments) {
+				args.Get(1).(*domain.UserFile).Version = 1
+			}).
+			Return(nil)
+		// Text files are indexed for full-text search
+		mockFileRepo.On("OpenFileContent", mock.Anything, mock.Anything).Return(mocks.NewContent(string(tc.content)), nil).Maybe()
+		mockFileRepo.On("SetFileText", mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+
+		_, err := useCase.UploadFile(context.Background(), 1, 1, "", tc.filename, "", nil, nil, nil, bytes.NewReader(tc.content))
+
+		require.NoError(t, err, tc.filename)
+		mockFileRepo.AssertExpectations(t)
+	}
+}
+
+func TestUploadFile_ExtensionPolicy(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	env := getTestEnv()
+	env.FileExtensionTypes = ".csv=text/csv|text/plain;.bat="
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, env)
+
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{UserID: 1}, nil)
+
+	err := useCase.CheckUpload(context.Background(), 1, 1, "", "run.BAT", 10)
+	require.ErrorIs(t, err, domain.ErrContentTypeNotAllowed)
+
+	_, err = useCase.UploadFile(context.Background(), 1, 1, "", "data.csv", "", nil, nil, nil, strings.NewReader("%PDF-1.7\n"))
+	require.ErrorIs(t, err, domain.ErrContentTypeNotAllowed)
+	mockFileRepo.AssertNotCalled(t, "StoreContent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateFile_ContentTypeMismatch(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	file := &domain.UserFile{ID: "abc123", UserID: 1, Filename: "notes.txt", ContentType: "text/plain; charset=utf-8"}
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(file, nil)
+
+	contentType := "text/html"
+	updated, err := useCase.UpdateFile(context.Background(), 1, "abc123", domain.FileUpdate{ContentType: &contentType})
+
+	require.ErrorIs(t, err, domain.ErrContentTypeMismatch)
+	require.Nil(t, updated)
+	mockFileRepo.AssertNotCalled(t, "UpdateFile", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUploadFile_InfectedIsQuarantined(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	version := &domain.FileVersion{BlobID: "blob123", Digest: "digest", Size: 4}
+	infected := domain.FileVersion{BlobID: "blob123", Digest: "digest", Size: 4, ScanStatus: domain.ScanInfected, ScanSignature: "Eicar-Test-Signature"}
+
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{UserID: 1}, nil)
+	mockScanner.On("Scan", mock.Anything, mock.Anything).
+		Return(&domain.ScanResult{Status: domain.ScanInfected, Signature: "Eicar-Test-Signature"}, nil)
+	mockFileRepo.On("StoreContent", mock.Anything, mock.Anything, mock.Anything).Return(version, nil)
+	mockFileRepo.On("GetFileByName", mock.Anything, uint(1), "", "eicar.txt").Return(nil, domain.ErrFileNotFound)
+	mockQuotaRepo.On("ReserveUsage", mock.Anything, uint(1), int64(4), 1, domain.StorageQuota{}).Return(nil)
+	mockFileRepo.On("SaveUserFile", mock.Anything, mock.Anything, infected).
+		Run(func(args mock.Arguments) {
+			file := args.Get(1).(*domain.UserFile)
+			v := args.Get(2).(domain.FileVersion)
+			v.Number = 1
+			*file = *file.AtVersion(v)
+			file.ID = "abc123"
+			file.Versions = []domain.FileVersion{v}
+		}).
+		Return(nil)
+	mockFileRepo.On("QuarantineVersion", mock.Anything, mock.MatchedBy(func(entry *domain.QuarantineEntry) bool {
+		return entry.FileID == "abc123" && entry.Version == 1 && entry.Signature == "Eicar-Test-Signature"
+	})).Return(nil)
+
+	meta, err := useCase.UploadFile(context.Background(), 1, 1, "", "eicar.txt", "", nil, nil, nil, strings.NewReader("data"))
+
+	require.NoError(t, err)
+	require.Equal(t, domain.ScanInfected, meta.ScanStatus)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestDownloadFile_NotClean(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	mockFileRepo.On("GetFileByID", mock.Anything, "pending").
+		Return(&domain.UserFile{ID: "pending", UserID: 1, ScanStatus: domain.ScanPending}, nil)
+	mockFileRepo.On("GetFileByID", mock.Anything, "infected").
+		Return(&domain.UserFile{ID: "infected", UserID: 1, ScanStatus: domain.ScanInfected}, nil)
+
+	_, _, err := useCase.DownloadFile(context.Background(), 1, "pending")
+	require.ErrorIs(t, err, domain.ErrFileNotScanned)
+
+	_, _, err = useCase.DownloadFile(context.Background(), 1, "infected")
+	require.ErrorIs(t, err, domain.ErrFileQuarantined)
+	mockFileRepo.AssertNotCalled(t, "OpenFileContent", mock.Anything, mock.Anything)
+}
+
+func TestScanPendingFiles_RetriesPendingVersions(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	file := &domain.UserFile{ID: "abc123", UserID: 1, Version: 2, Versions: []domain.FileVersion{
+		{Number: 1, BlobID: "blob1", ScanStatus: domain.ScanClean},
+		{Number: 2, BlobID: "blob2", ScanStatus: domain.ScanPending},
+	}}
+	result := domain.ScanResult{Status: domain.ScanInfected, Signature: "Eicar-Test-Signature"}
+
+	mockFileRepo.On("GetPendingScans", mock.Anything, scanBatch).Return([]*domain.UserFile{file}, nil)
+	mockFileRepo.On("OpenFileContent", mock.Anything, mock.MatchedBy(func(f *domain.UserFile) bool { return f.BlobID == "blob2" })).
+		Return(mocks.NewContent("data"), nil)
+	mockScanner.On("Scan", mock.Anything, mock.Anything).Return(&result, nil).Once()
+	mockFileRepo.On("SetScanResult", mock.Anything, file, 2, result).Return(nil)
+	// The infected version is current, so its text must not be searchable
+	mockFileRepo.On("SetFileText", mock.Anything, file, "").Return(nil)
+	mockFileRepo.On("QuarantineVersion", mock.Anything, mock.MatchedBy(func(entry *domain.QuarantineEntry) bool {
+		return entry.FileID == "abc123" && entry.Version == 2
+	})).Return(nil)
+
+	scanned, err := useCase.ScanPendingFiles(context.Background())
+
+	require.NoError(t, err)
+	require.Equal(t, 1, scanned)
+	mockFileRepo.AssertExpectations(t)
+	mockScanner.AssertExpectations(t)
+}
+
+func TestGetArchive_FolderWithCollidingNames(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	mockFolderRepo.On("GetFoldersByUserID", mock.Anything, uint(1)).Return([]*domain.Folder{
+		{ID: "reports", UserID: 1, Name: "reports"},
+		{ID: "q3", UserID: 1, ParentID: "reports", Name: "q3"},
+		{ID: "other", UserID: 1, Name: "other"},
+	}, nil)
+	mockFileRepo.On("GetFilesByUserID", mock.Anything, uint(1)).Return([]*domain.UserFile{
+		{ID: "f1", FolderID: "reports", Filename: "Summary.pdf", ScanStatus: domain.ScanClean},
+		{ID: "f2", FolderID: "reports", Filename: "summary.pdf", ScanStatus: domain.ScanClean},
+		{ID: "f3", FolderID: "q3", Filename: "data.csv", ScanStatus: domain.ScanClean},
+		{ID: "f4", FolderID: "reports", Filename: "Q3", ScanStatus: domain.ScanClean},
+		{ID: "f5", FolderID: "reports", Filename: "new.pdf", ScanStatus: domain.ScanPending},
+		{ID: "f6", FolderID: "other", Filename: "notes.txt", ScanStatus: domain.ScanClean},
+		{ID: "f7", Filename: "root.txt", ScanStatus: domain.ScanClean},
+	}, nil)
+
+	archive, err := useCase.GetArchive(context.Background(), 1, 1, domain.ArchiveFilter{FolderID: "reports"})
+
+	require.NoError(t, err)
+	require.Equal(t, "reports.zip", archive.Name)
+	require.Equal(t, 1, archive.Skipped)
+
+	paths := map[string]string{}
+	for _, entry := range archive.Entries {
+		paths[entry.File.ID] = entry.Path
+	}
+	require.Equal(t, map[string]string{
+		"f1": "Summary.pdf",
+		"f2": "summary (2).pdf",
+		"f3": "q3/data.csv",
+		"f4": "Q3 (2)",
+	}, paths)
+}
+
+func TestGetArchive_UnknownFileID(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	mockFolderRepo.On("GetFoldersByUserID", mock.Anything, uint(1)).Return([]*domain.Folder{}, nil)
+	mockFileRepo.On("GetFilesByUserID", mock.Anything, uint(1)).Return([]*domain.UserFile{
+		{ID: "f1", Filename: "a.txt", ScanStatus: domain.ScanClean},
+	}, nil)
+
+	archive, err := useCase.GetArchive(context.Background(), 1, 1, domain.ArchiveFilter{FileIDs: []string{"f1", "someone-elses"}})
+
+	require.ErrorIs(t, err, domain.ErrFileNotFound)
+	require.Nil(t, archive)
+}
+
+func TestWriteArchive_Success(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	notes := &domain.UserFile{ID: "f1", Filename: "notes.txt", ContentType: "text/plain"}
+	photo := &domain.UserFile{ID: "f2", Filename: "photo.jpg", ContentType: "image/jpeg"}
+	mockFileRepo.On("OpenFileContent", mock.Anything, notes).Return(mocks.NewContent("some notes"), nil)
+	mockFileRepo.On("OpenFileContent", mock.Anything, photo).Return(mocks.NewContent("jpeg bytes"), nil)
+
+	var buf bytes.Buffer
+	err := useCase.WriteArchive(context.Background(), &domain.Archive{Entries: []domain.ArchiveEntry{
+		{Path: "notes.txt", File: notes},
+		{Path: "photos/photo.jpg", File: photo},
+	}}, &buf)
+	require.NoError(t, err)
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Len(t, reader.File, 2)
+	require.Equal(t, "notes.txt", reader.File[0].Name)
+	require.Equal(t, zip.Deflate, reader.File[0].Method)
+	require.Equal(t, "photos/photo.jpg", reader.File[1].Name)
+	require.Equal(t, zip.Store, reader.File[1].Method)
+
+	content, err := reader.File[0].Open()
+	require.NoError(t, err)
+	data, err := io.ReadAll(content)
+	require.NoError(t, err)
+	require.Equal(t, "some notes", string(data))
+}
+
+func TestRestoreFile_Success(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	deletedAt := time.Now().UTC()
+	file := &domain.UserFile{ID: "abc123", UserID: 1, FolderID: "docs", Filename: "a.txt", DeletedAt: &deletedAt}
+
+	mockFileRepo.On("GetTrashedFile", mock.Anything, "abc123").Return(file, nil)
+	mockFolderRepo.On("GetFolderByID", mock.Anything, "docs").Return(&domain.Folder{ID: "docs", UserID: 1}, nil)
+	mockFileRepo.On("RestoreFile", mock.Anything, file, "docs").Return(nil)
+
+	result, err := useCase.RestoreFile(context.Background(), 1, "abc123")
+
+	require.NoError(t, err)
+	require.Equal(t, "abc123", result.ID)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestRestoreFile_DeletedFolderRestoresToRoot(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	deletedAt := time.Now().UTC()
+	file := &domain.UserFile{ID: "abc123", UserID: 1, FolderID: "gone", Filename: "a.txt", DeletedAt: &deletedAt}
+
+	mockFileRepo.On("GetTrashedFile", mock.Anything, "abc123").Return(file, nil)
+	mockFolderRepo.On("GetFolderByID", mock.Anything, "gone").Return(nil, domain.ErrFolderNotFound)
+	mockFileRepo.On("RestoreFile", mock.Anything, file, "").Return(nil)
+
+	_, err := useCase.RestoreFile(context.Background(), 1, "abc123")
+
+	require.NoError(t, err)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestRestoreFile_OtherUser(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	deletedAt := time.Now().UTC()
+	file := &domain.UserFile{ID: "abc123", UserID: 1, Filename: "a.txt", DeletedAt: &deletedAt}
+
+	mockFileRepo.On("GetTrashedFile", mock.Anything, "abc123").Return(file, nil)
+
+	result, err := useCase.RestoreFile(context.Background(), 2, "abc123")
+
+	require.ErrorIs(t, err, domain.ErrForbidden)
+	require.Nil(t, result)
+	mockFileRepo.AssertNotCalled(t, "RestoreFile", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRestoreFile_Expired(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	// Expired files are moved to the trash when their name is taken again
+	expiresAt := time.Now().UTC().Add(-time.Hour)
+	file := &domain.UserFile{ID: "abc123", UserID: 1, Filename: "a.txt", ExpiresAt: &expiresAt, DeletedAt: &expiresAt}
+
+	mockFileRepo.On("GetTrashedFile", mock.Anything, "abc123").Return(file, nil)
+
+	result, err := useCase.RestoreFile(context.Background(), 1, "abc123")
+
+	require.ErrorIs(t, err, domain.ErrFileExpired)
+	require.Nil(t, result)
+	mockFileRepo.AssertNotCalled(t, "RestoreFile", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestEmptyTrash_ReleasesUsage(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	file := &domain.UserFile{
+		ID:       "abc123",
+		UserID:   1,
+		Version:  2,
+		Versions: []domain.FileVersion{{Number: 1, Size: 3}, {Number: 2, Size: 5}},
+	}
+	// Restored while the trash was being emptied
+	restored := &domain.UserFile{ID: "def456", UserID: 1}
+
+	mockFileRepo.On("GetTrashedFiles", mock.Anything, uint(1)).Return([]*domain.UserFile{file, restored}, nil)
+	mockFileRepo.On("DeleteFile", mock.Anything, file).Return(nil)
+	mockFileRepo.On("DeleteFile", mock.Anything, restored).Return(domain.ErrFileNotFound)
+	mockGrantRepo.On("DeleteResourceGrants", mock.Anything, []domain.Resource{{Type: domain.ResourceFile, ID: "abc123"}}).Return(nil)
+	mockQuotaRepo.On("AddUsage", mock.Anything, uint(1), int64(-8), -1).Return(nil)
+
+	purged, err := useCase.EmptyTrash(context.Background(), 1, 1)
+
+	require.NoError(t, err)
+	require.Equal(t, 1, purged)
+	mockFileRepo.AssertExpectations(t)
+	mockQuotaRepo.AssertExpectations(t)
+	mockGrantRepo.AssertExpectations(t)
+}
+
+func TestPurgeTrash_ContinuesAfterError(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	env := getTestEnv()
+	env.FileTrashRetentionDays = 7
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, env)
+
+	failing := &domain.UserFile{ID: "abc123", UserID: 1, Versions: []domain.FileVersion{{Number: 1, Size: 3}}}
+	file := &domain.UserFile{ID: "def456", UserID: 2, Versions: []domain.FileVersion{{Number: 1, Size: 5}}}
+	dbErr := errors.New("connection reset")
+
+	before := mock.MatchedBy(func(before time.Time) bool {
+		age := time.Since(before)
+		return age > 7*24*time.Hour-time.Minute && age < 7*24*time.Hour+time.Minute
+	})
+	mockFileRepo.On("GetExpiredTrash", mock.Anything, before, trashBatch).Return([]*domain.UserFile{failing, file}, nil)
+	mockFileRepo.On("DeleteFile", mock.Anything, failing).Return(dbErr)
+	mockFileRepo.On("DeleteFile", mock.Anything, file).Return(nil)
+	mockGrantRepo.On("DeleteResourceGrants", mock.Anything, []domain.Resource{{Type: domain.ResourceFile, ID: "def456"}}).Return(nil)
+	mockQuotaRepo.On("AddUsage", mock.Anything, uint(2), int64(-5), -1).Return(nil)
+
+	purged, err := useCase.PurgeTrash(context.Background())
+
+	require.ErrorIs(t, err, dbErr)
+	require.Equal(t, 1, purged)
+	mockFileRepo.AssertExpectations(t)
+	mockQuotaRepo.AssertExpectations(t)
+}
+
+func TestSearchContent_OnlyReadableFiles(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	own := &domain.UserFile{ID: "own", UserID: 2, Filename: "notes.txt", Version: 1}
+	shared := &domain.UserFile{ID: "shared", UserID: 1, Filename: "budget.csv", Version: 3}
+	private := &domain.UserFile{ID: "private", UserID: 1, Filename: "salaries.csv", Version: 1}
+	restored := &domain.UserFile{ID: "restored", UserID: 2, Filename: "old.txt", Version: 4}
+
+	mockGrantRepo.On("GetGrantsByUserID", mock.Anything, uint(2)).Return([]*domain.Grant{
+		{Resource: domain.Resource{Type: domain.ResourceFile, ID: "shared"}, OwnerID: 1, UserID: 2, Permission: domain.PermissionRead},
+	}, nil)
+	mockFileRepo.On("SearchFileText", mock.Anything, "budget 2026", []uint{2, 1}, contentCandidates).Return([]*domain.FileTextMatch{
+		{FileID: "shared", Version: 3, Score: 2.5},
+		{FileID: "private", Version: 1, Score: 2},
+		{FileID: "restored", Version: 3, Score: 1.5},
+		{FileID: "trashed", Version: 1, Score: 1.2},
+		{FileID: "own", Version: 1, Score: 1},
+	}, nil)
+	mockFileRepo.On("GetFileByID", mock.Anything, "shared").Return(shared, nil)
+	mockFileRepo.On("GetFileByID", mock.Anything, "private").Return(private, nil)
+	mockFileRepo.On("GetFileByID", mock.Anything, "restored").Return(restored, nil)
+	mockFileRepo.On("GetFileByID", mock.Anything, "trashed").Return(nil, domain.ErrFileNotFound)
+	mockFileRepo.On("GetFileByID", mock.Anything, "own").Return(own, nil)
+	mockGrantRepo.On("GetUserGrants", mock.Anything, uint(2), []domain.Resource{{Type: domain.ResourceFile, ID: "shared"}}).
+		Return([]*domain.Grant{{UserID: 2, Permission: domain.PermissionRead}}, nil)
+	mockGrantRepo.On("GetUserGrants", mock.Anything, uint(2), []domain.Resource{{Type: domain.ResourceFile, ID: "private"}}).
+		Return([]*domain.Grant{}, nil)
+	mockFileRepo.On("GetFileText", mock.Anything, "shared").Return("id amount budget 2026 1200", nil)
+	mockFileRepo.On("GetFileText", mock.Anything, "own").Return("plans for the budget", nil)
+
+	hits, err := useCase.SearchContent(context.Background(), 2, "  budget 2026 ", 0)
+
+	require.NoError(t, err)
+	require.Len(t, hits, 2)
+	require.Equal(t, "shared", hits[0].ID)
+	require.Equal(t, uint(1), hits[0].OwnerID)
+	require.Equal(t, 2.5, hits[0].Score)
+	require.Equal(t, "id amount <mark>budget</mark> <mark>2026</mark> 1200", hits[0].Snippet)
+	require.Equal(t, "own", hits[1].ID)
+	mockFileRepo.AssertNotCalled(t, "GetFileText", mock.Anything, "private")
+	mockFileRepo.AssertNotCalled(t, "GetFileText", mock.Anything, "restored")
+}
+
+func TestSearchContent_EmptyQuery(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	hits, err := useCase.SearchContent(context.Background(), 1, " -draft ", 0)
+
+	require.ErrorIs(t, err, domain.ErrInvalidSearch)
+	require.Nil(t, hits)
+	mockFileRepo.AssertNotCalled(t, "SearchFileText", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUploadFile_IndexesText(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	content := `{"title": "Quarterly budget", "total": 1200}`
+	version := &domain.FileVersion{BlobID: "blob123", Digest: "digest", Size: int64(len(content)), ScanStatus: domain.ScanClean}
+
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{UserID: 1}, nil)
+	mockScanner.On("Scan", mock.Anything, mock.Anything).Return(&domain.ScanResult{Status: domain.ScanClean}, nil)
+	mockFileRepo.On("StoreContent", mock.Anything, mock.Anything, mock.Anything).Return(version, nil)
+	mockFileRepo.On("GetFileByName", mock.Anything, uint(1), "", "budget.json").Return(nil, domain.ErrFileNotFound)
+	mockQuotaRepo.On("ReserveUsage", mock.Anything, uint(1), version.Size, 1, domain.StorageQuota{}).Return(nil)
+	mockFileRepo.On("SaveUserFile", mock.Anything, mock.Anything, *version).
+		Run(func(args mock.Arguments) {
+			file := args.Get(1).(*domain.UserFile)
+			file.ID = "abc123"
+			file.Version = 1
+		}).
+		Return(nil)
+	mockFileRepo.On("OpenFileContent", mock.Anything, mock.Anything).Return(mocks.NewContent(content), nil)
+	mockFileRepo.On("SetFileText", mock.Anything, mock.Anything, "title Quarterly budget total").Return(nil)
+
+	_, err := useCase.UploadFile(context.Background(), 1, 1, "", "budget.json", "", nil, nil, nil, strings.NewReader(content))
+
+	require.NoError(t, err)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestUploadFile_MergesTags(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	version := &domain.FileVersion{BlobID: "blob123", Digest: "digest", Size: 4, ScanStatus: domain.ScanClean}
+
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{UserID: 1}, nil)
+	mockScanner.On("Scan", mock.Anything, mock.Anything).Return(&domain.ScanResult{Status: domain.ScanClean}, nil)
+	mockFileRepo.On("StoreContent", mock.Anything, mock.Anything, mock.Anything).Return(version, nil)
+	mockFileRepo.On("GetFileByName", mock.Anything, uint(1), "", "file.txt").Return(&domain.UserFile{ID: "abc123", UserID: 1}, nil)
+	mockQuotaRepo.On("ReserveUsage", mock.Anything, uint(1), int64(4), 0, domain.StorageQuota{}).Return(nil)
+	// The file already exists, so it keeps its tags
+	mockFileRepo.On("SaveUserFile", mock.Anything, mock.Anything, *version).
+		Run(func(args mock.Arguments) {
+			file := args.Get(1).(*domain.UserFile)
+			file.ID = "abc123"
+			file.Version = 2
+			file.Tags = []string{"work"}
+		}).
+		Return(nil)
+	mockFileRepo.On("SetFileTags", mock.Anything, mock.Anything, []string{"q3 report", "work"}).Return(nil)
+	mockFileRepo.On("OpenFileContent", mock.Anything, mock.Anything).Return(mocks.NewContent("data"), nil)
+	mockFileRepo.On("SetFileText", mock.Anything, mock.Anything, "data").Return(nil)
+
+	_, err := useCase.UploadFile(context.Background(), 1, 1, "", "file.txt", "", []string{" Q3   Report ", "WORK"}, nil, nil, strings.NewReader("data"))
+
+	require.NoError(t, err)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestUploadFile_InvalidTag(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	meta, err := useCase.UploadFile(context.Background(), 1, 1, "", "file.txt", "text/plain", []string{"work", "  "}, nil, nil, strings.NewReader("data"))
+
+	require.ErrorIs(t, err, domain.ErrInvalidTag)
+	require.Nil(t, meta)
+	mockFileRepo.AssertNotCalled(t, "StoreContent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSetFileTags_Success(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	file := &domain.UserFile{ID: "abc123", UserID: 1, Filename: "file.txt"}
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(file, nil)
+	mockFileRepo.On("SetFileTags", mock.Anything, file, []string{"invoices", "tax"}).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.UserFile).Tags = args.Get(2).([]string)
+		}).
+		Return(nil)
+
+	meta, err := useCase.SetFileTags(context.Background(), 1, "abc123", []string{"Tax", "invoices", "tax"})
+
+	require.NoError(t, err)
+	require.Equal(t, []string{"invoices", "tax"}, meta.Tags)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestSetFileTags_Invalid(t *testing.T) {
+	tooMany := make([]string, maxFileTags+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("tag%d", i)
+	}
+
+	tests := []struct {
+		name string
+		tags []string
+		err  error
+	}{
+		{"comma", []string{"a,b"}, domain.ErrInvalidTag},
+		{"empty", []string{""}, domain.ErrInvalidTag},
+		{"too long", []string{strings.Repeat("x", maxTagLength+1)}, domain.ErrInvalidTag},
+		{"too many", tooMany, domain.ErrTooManyTags},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mockFileRepo := new(mocks.FileRepository)
+			useCase := NewFileUseCase(new(mocks.UserRepository), mockFileRepo, new(mocks.FolderRepository), new(mocks.QuotaRepository), new(mocks.GrantRepository), new(mocks.Scanner), nil, 2*time.Second, getTestEnv())
+
+			meta, err := useCase.SetFileTags(context.Background(), 1, "abc123", tc.tags)
+
+			require.ErrorIs(t, err, tc.err)
+			require.Nil(t, meta)
+			mockFileRepo.AssertNotCalled(t, "SetFileTags", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestRenameTags_Merges(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	mockFileRepo.On("RenameTags", mock.Anything, uint(1), []string{"invoice", "invoices"}, "billing").Return(3, nil)
+
+	updated, err := useCase.RenameTags(context.Background(), 1, 1, domain.TagRename{From: []string{"Invoices", "invoice"}, To: " Billing"})
+
+	require.NoError(t, err)
+	require.Equal(t, 3, updated)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestRenameTags_OtherUser(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	updated, err := useCase.RenameTags(context.Background(), 2, 1, domain.TagRename{From: []string{"a"}, To: "b"})
+
+	require.ErrorIs(t, err, domain.ErrForbidden)
+	require.Zero(t, updated)
+	mockFileRepo.AssertNotCalled(t, "RenameTags", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetFilesByUserID_TagFilter(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	files := []*domain.UserFile{{ID: "abc123", UserID: 1, Filename: "q3.pdf", Tags: []string{"q3", "tax"}}}
+	search := domain.FileSearch{Tags: domain.TagFilter{Tags: []string{"q3", "tax"}, All: true}, Sort: domain.FileSortName}
+	mockFileRepo.On("SearchFiles", mock.Anything, uint(1), search).Return(files, "", nil)
+
+	result, err := useCase.GetFilesByUserID(context.Background(), 1, 1, domain.TagFilter{Tags: []string{"TAX", "q3"}, All: true})
+
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	require.Equal(t, []string{"q3", "tax"}, result[0].Tags)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestUploadFile_WithExpiry(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	expiresAt := time.Now().Add(24 * time.Hour).UTC()
+	version := &domain.FileVersion{BlobID: "blob123", Digest: "digest", Size: 4, ScanStatus: domain.ScanClean}
+
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{UserID: 1}, nil)
+	mockScanner.On("Scan", mock.Anything, mock.Anything).Return(&domain.ScanResult{Status: domain.ScanClean}, nil)
+	mockFileRepo.On("StoreContent", mock.Anything, mock.Anything, mock.Anything).Return(version, nil)
+	mockFileRepo.On("GetFileByName", mock.Anything, uint(1), "", "export.txt").Return(nil, domain.ErrFileNotFound)
+	mockQuotaRepo.On("ReserveUsage", mock.Anything, uint(1), int64(4), 1, domain.StorageQuota{}).Return(nil)
+	// New files are created with their expiry
+	mockFileRepo.On("SaveUserFile", mock.Anything, mock.MatchedBy(func(file *domain.UserFile) bool {
+		return file.ExpiresAt != nil && file.ExpiresAt.Equal(expiresAt)
+	}), *version).
+		Run(func(args mock.Arguments) {
+			file := args.Get(1).(*domain.UserFile)
+			file.ID = "abc123"
+			file.Version = 1
+		}).
+		Return(nil)
+	mockFileRepo.On("OpenFileContent", mock.Anything, mock.Anything).Return(mocks.NewContent("data"), nil)
+	mockFileRepo.On("SetFileText", mock.Anything, mock.Anything, "data").Return(nil)
+
+	meta, err := useCase.UploadFile(context.Background(), 1, 1, "", "export.txt", "", nil, &expiresAt, nil, strings.NewReader("data"))
+
+	require.NoError(t, err)
+	require.NotNil(t, meta.ExpiresAt)
+	require.True(t, meta.ExpiresAt.Equal(expiresAt))
+	mockFileRepo.AssertExpectations(t)
+	mockFileRepo.AssertNotCalled(t, "SetFileExpiry", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUploadFile_ExpiryInPast(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	expiresAt := time.Now().Add(-time.Minute)
+
+	meta, err := useCase.UploadFile(context.Background(), 1, 1, "", "export.txt", "", nil, &expiresAt, nil, strings.NewReader("data"))
+
+	require.ErrorIs(t, err, domain.ErrInvalidExpiry)
+	require.Nil(t, meta)
+	mockFileRepo.AssertNotCalled(t, "StoreContent", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSetFileExpiry_Extends(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	current := time.Now().Add(time.Hour).UTC()
+	extended := current.Add(48 * time.Hour)
+	file := &domain.UserFile{ID: "abc123", UserID: 1, Filename: "export.txt", ExpiresAt: &current}
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(file, nil)
+	mockFileRepo.On("SetFileExpiry", mock.Anything, file, &extended).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.UserFile).ExpiresAt = args.Get(2).(*time.Time)
+		}).
+		Return(nil)
+
+	meta, err := useCase.SetFileExpiry(context.Background(), 1, "abc123", &extended)
+
+	require.NoError(t, err)
+	require.Equal(t, &extended, meta.ExpiresAt)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestSetFileExpiry_OtherUser(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	file := &domain.UserFile{ID: "abc123", UserID: 1, Filename: "export.txt"}
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(file, nil)
+	mockGrantRepo.On("GetUserGrants", mock.Anything, uint(2), mock.Anything).Return([]*domain.Grant{}, nil)
+
+	meta, err := useCase.SetFileExpiry(context.Background(), 2, "abc123", nil)
+
+	require.ErrorIs(t, err, domain.ErrForbidden)
+	require.Nil(t, meta)
+	mockFileRepo.AssertNotCalled(t, "SetFileExpiry", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestExpireFiles_SkipsExtended(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	extended := &domain.UserFile{ID: "abc123", UserID: 1, Versions: []domain.FileVersion{{Number: 1, Size: 3}}}
+	file := &domain.UserFile{ID: "def456", UserID: 2, Versions: []domain.FileVersion{{Number: 1, Size: 5}, {Number: 2, Size: 7}}}
+
+	mockFileRepo.On("GetExpiredFiles", mock.Anything, mock.Anything, expiryBatch).Return([]*domain.UserFile{extended, file}, nil)
+	// The first file's expiry was extended after it was listed
+	mockFileRepo.On("DeleteExpiredFile", mock.Anything, extended, mock.Anything).Return(domain.ErrFileNotFound)
+	mockFileRepo.On("DeleteExpiredFile", mock.Anything, file, mock.Anything).Return(nil)
+	mockGrantRepo.On("DeleteResourceGrants", mock.Anything, []domain.Resource{{Type: domain.ResourceFile, ID: "def456"}}).Return(nil)
+	mockQuotaRepo.On("AddUsage", mock.Anything, uint(2), int64(-12), -1).Return(nil)
+
+	expired, err := useCase.ExpireFiles(context.Background())
+
+	require.NoError(t, err)
+	require.Equal(t, 1, expired)
+	mockFileRepo.AssertExpectations(t)
+	mockQuotaRepo.AssertExpectations(t)
+	mockQuotaRepo.AssertNotCalled(t, "AddUsage", mock.Anything, uint(1), mock.Anything, mock.Anything)
+}
+
+func TestUploadFile_DigestMismatch(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	version := &domain.FileVersion{BlobID: "blob123", Digest: "digest", Size: 4, ScanStatus: domain.ScanClean}
+	sum := sha256.Sum256([]byte("date"))
+
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{UserID: 1}, nil)
+	mockScanner.On("Scan", mock.Anything, mock.Anything).Return(&domain.ScanResult{Status: domain.ScanClean}, nil)
+	mockFileRepo.On("StoreContent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			_, err := io.ReadAll(args.Get(1).(io.Reader))
+			require.NoError(t, err)
+		}).
+		Return(version, nil)
+	// The stored copy is dropped again
+	mockFileRepo.On("ReleaseContent", mock.Anything, version).Return(nil)
+
+	digests := []domain.ContentDigest{{Algorithm: domain.DigestSHA256, Sum: sum[:]}}
+	meta, err := useCase.UploadFile(context.Background(), 1, 1, "", "export.txt", "", nil, nil, digests, strings.NewReader("data"))
+
+	require.ErrorIs(t, err, domain.ErrDigestMismatch)
+	require.Nil(t, meta)
+	mockFileRepo.AssertExpectations(t)
+	mockFileRepo.AssertNotCalled(t, "SaveUserFile", mock.Anything, mock.Anything, mock.Anything)
+	mockQuotaRepo.AssertNotCalled(t, "ReserveUsage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUploadFile_DigestMatch(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	version := &domain.FileVersion{BlobID: "blob123", Digest: "digest", Size: 4, ScanStatus: domain.ScanClean}
+	sum := sha256.Sum256([]byte("data"))
+
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{UserID: 1}, nil)
+	mockScanner.On("Scan", mock.Anything, mock.Anything).Return(&domain.ScanResult{Status: domain.ScanClean}, nil)
+	mockFileRepo.On("StoreContent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			_, err := io.ReadAll(args.Get(1).(io.Reader))
+			require.NoError(t, err)
+		}).
+		Return(version, nil)
+	mockFileRepo.On("GetFileByName", mock.Anything, uint(1), "", "export.txt").Return(nil, domain.ErrFileNotFound)
+	mockQuotaRepo.On("ReserveUsage", mock.Anything, uint(1), int64(4), 1, domain.StorageQuota{}).Return(nil)
+	mockFileRepo.On("SaveUserFile", mock.Anything, mock.Anything, *version).
+		Run(func(args mock.Arguments) {
+			file := args.Get(1).(*domain.UserFile)
+			file.ID = "abc123"
+			file.Version = 1
+		}).
+		Return(nil)
+	mockFileRepo.On("OpenFileContent", mock.Anything, mock.Anything).Return(mocks.NewContent("data"), nil)
+	mockFileRepo.On("SetFileText", mock.Anything, mock.Anything, "data").Return(nil)
+
+	digests := []domain.ContentDigest{{Algorithm: domain.DigestSHA256, Sum: sum[:]}}
+	meta, err := useCase.UploadFile(context.Background(), 1, 1, "", "export.txt", "", nil, nil, digests, strings.NewReader("data"))
+
+	require.NoError(t, err)
+	require.Equal(t, "abc123", meta.ID)
+	mockFileRepo.AssertNotCalled(t, "ReleaseContent", mock.Anything, mock.Anything)
+}
+
+func TestScrubContent_CountsCorrupt(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	unreachable := errors.New("store unreachable")
+	mockFileRepo.On("GetUnverifiedContent", mock.Anything, mock.Anything, scrubBatch).Return([]string{"intact", "damaged", "offline"}, nil)
+	mockFileRepo.On("VerifyContent", mock.Anything, "intact").Return(nil)
+	mockFileRepo.On("VerifyContent", mock.Anything, "damaged").Return(domain.ErrContentCorrupt)
+	// Content that can't be read doesn't stop the rest of the run
+	mockFileRepo.On("VerifyContent", mock.Anything, "offline").Return(unreachable)
+
+	corrupt, err := useCase.ScrubContent(context.Background())
+
+	require.ErrorIs(t, err, unreachable)
+	require.Equal(t, 1, corrupt)
+	mockFileRepo.AssertExpectations(t)
+}
+
+func TestSetStorageQuota_Admin(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, []uint{7}, 2*time.Second, getTestEnv())
+
+	quota := &domain.StorageQuota{MaxBytes: 1000}
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockQuotaRepo.On("SetQuota", mock.Anything, uint(1), quota).Return(nil)
+
+	err := useCase.SetStorageQuota(context.Background(), 7, 1, quota)
+
+	require.NoError(t, err)
+	mockQuotaRepo.AssertExpectations(t)
+}
+
+func TestSetStorageQuota_NotAdmin(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, []uint{7}, 2*time.Second, getTestEnv())
+
+	// Not even for their own account
+	err := useCase.SetStorageQuota(context.Background(), 1, 1, &domain.StorageQuota{})
+
+	require.ErrorIs(t, err, domain.ErrForbidden)
+	mockQuotaRepo.AssertNotCalled(t, "SetQuota", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHasContent_OtherUser(t *testing.T) {
+	mockFileRepo := new(mocks.FileRepository)
+
+	useCase := NewFileUseCase(new(mocks.UserRepository), mockFileRepo, new(mocks.FolderRepository), new(mocks.QuotaRepository), new(mocks.GrantRepository), new(mocks.Scanner), nil, 2*time.Second, getTestEnv())
+
+	_, err := useCase.HasContent(context.Background(), 2, 1, "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7")
+
+	require.ErrorIs(t, err, domain.ErrForbidden)
+	mockFileRepo.AssertNotCalled(t, "GetFileByDigest", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUploadKnownContent_Success(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockQuotaRepo := new(mocks.QuotaRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockScanner := new(mocks.Scanner)
+
+	useCase := NewFileUseCase(mockUserRepo, mockFileRepo, mockFolderRepo, mockQuotaRepo, mockGrantRepo, mockScanner, nil, 2*time.Second, getTestEnv())
+
+	digest := "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7"
+	known := domain.FileVersion{Number: 1, BlobID: "blob123", Digest: digest, Size: 4, ContentType: "text/plain; charset=utf-8", ScanStatus: domain.ScanClean}
+	source := &domain.UserFile{ID: "src", UserID: 1, Filename: "old.txt", Version: 1, Versions: []domain.FileVersion{known}}
+	version := &domain.FileVersion{BlobID: "blob123", Digest: digest, Size: 4, ScanStatus: domain.ScanClean}
+
+	mockFileRepo.On("GetFileByDigest", mock.Anything, uint(1), digest).Return(source, nil)
+	mockFileRepo.On("OpenFileContent", mock.Anything, mock.Anything).Return(mocks.NewContent("data"), nil).Once()
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockQuotaRepo.On("GetUsage", mock.Anything, uint(1)).Return(&domain.StorageUsage{UserID: 1}, nil)
+	mockScanner.On("Scan", mock.Anything, mock.Anything).Return(&domain.ScanResult{Status: domain.ScanClean}, nil)
+	mockFileRepo.On("StoreContent", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			_, _ = io.Copy(io.Discard, args.Get(1).(io.Reader))
+		}).
+		Return(version, nil)
+	mockFileRepo.On("GetFileByName", mock.Anything, uint(1), "", "copy.txt").Return(nil, domain.ErrFileNotFound)
+	mockQuotaRepo.On("ReserveUsage", mock.Anything, uint(1), int64(4), 1, domain.StorageQuota{}).Return(nil)
+	mockFileRepo.On("SaveUserFile", mock.Anything, mock.Anything, *version).
+		Run(func(args mock.Arguments) {
+			file := args.Get(1).(*domain.UserFile)
+			file.ID = "abc123"
+			file.Version = 1
+			file.Digest = digest
+		}).
+		Return(nil)
+	// The new file is read again for its text
+	mockFileRepo.On("OpenFileContent", mock.Anything, mock.Anything).Return(mocks.NewContent("data"), nil).Once()
+	mockFileRepo.On("SetFileText", mock.Anything, mock.Anything, "data").Return(nil)
+
+	meta, err := useCase.UploadKnownContent(context.Background(), 1, 1, "", "copy.txt", nil, nil, digest)
+
+	require.NoError(t, err)
+	require.Equal(t, "abc123", meta.ID)
+	require.Equal(t, digest, meta.Digest)
+	mockFileRepo.AssertExpectations(t)
+	mockQuotaRepo.AssertExpectations(t)
+}
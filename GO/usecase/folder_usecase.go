@@ -0,0 +1,315 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/repository"
+)
+
+type folderUseCase struct {
+	userRepo    repository.UserRepository
+	folderRepo  repository.FolderRepository
+	fileRepo    repository.FileRepository
+	fileUseCase domain.FileUseCase
+	access      accessControl
+	timeout     time.Duration
+}
+
+func NewFolderUseCase(
+	userRepo repository.UserRepository,
+	folderRepo repository.FolderRepository,
+	fileRepo repository.FileRepository,
+	grantRepo repository.GrantRepository,
+	fileUseCase domain.FileUseCase,
+	timeout time.Duration,
+) domain.FolderUseCase {
+	return &folderUseCase{
+		userRepo:    userRepo,
+		folderRepo:  folderRepo,
+		fileRepo:    fileRepo,
+		fileUseCase: fileUseCase,
+		access:      accessControl{folderRepo: folderRepo, grantRepo: grantRepo},
+		timeout:     timeout,
+	}
+}
+
+func (u *folderUseCase) CreateFolder(ctx context.Context, callerID, userID uint, request domain.CreateFolderRequest) (*domain.Folder, error) {
+	name, ok := cleanName(request.Name)
+	if !ok {
+		return nil, domain.ErrInvalidFolderName
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	if _, err := u.userRepo.GetUserByID(ctx, userID); err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	if request.ParentID != "" {
+		if _, err := u.userFolder(ctx, callerID, userID, request.ParentID); err != nil {
+			return nil, err
+		}
+	} else if err := checkUser(callerID, userID); err != nil {
+		return nil, err
+	}
+
+	folder := &domain.Folder{
+		UserID:    userID,
+		ParentID:  request.ParentID,
+		Name:      name,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := u.folderRepo.CreateFolder(ctx, folder); err != nil {
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+func (u *folderUseCase) GetFolderContents(ctx context.Context, callerID uint, id string) (*domain.FolderContents, error) {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	folder, err := u.folder(ctx, callerID, id, domain.PermissionRead)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := u.contents(ctx, folder.UserID, folder.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	contents.Folder = folder
+	return contents, nil
+}
+
+func (u *folderUseCase) GetRootContents(ctx context.Context, callerID, userID uint) (*domain.FolderContents, error) {
+	if err := checkUser(callerID, userID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	if _, err := u.userRepo.GetUserByID(ctx, userID); err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	return u.contents(ctx, userID, "")
+}
+
+func (u *folderUseCase) ResolveFolder(ctx context.Context, callerID, userID uint, path string) (*domain.Folder, error) {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	if err := u.access.checkSharer(ctx, callerID, userID); errors.Is(err, domain.ErrForbidden) {
+		return nil, domain.ErrFolderNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	var folder *domain.Folder
+	parentID := ""
+	for _, name := range strings.Split(path, "/") {
+		if name == "" {
+			continue
+		}
+		var err error
+		if folder, err = u.folderRepo.GetFolderByName(ctx, userID, parentID, name); err != nil {
+			return nil, err
+		}
+		parentID = folder.ID
+	}
+	if folder == nil {
+		return nil, domain.ErrFolderNotFound
+	}
+
+	if err := u.access.checkFolder(ctx, callerID, folder, domain.PermissionRead); errors.Is(err, domain.ErrForbidden) {
+		return nil, domain.ErrFolderNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+func (u *folderUseCase) UpdateFolder(ctx context.Context, callerID uint, id string, update domain.FolderUpdate) (*domain.Folder, error) {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	folder, err := u.folder(ctx, callerID, id, domain.PermissionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Name != nil {
+		name, ok := cleanName(*update.Name)
+		if !ok {
+			return nil, domain.ErrInvalidFolderName
+		}
+		folder.Name = name
+	}
+
+	if update.ParentID != nil && *update.ParentID != folder.ParentID {
+		if *update.ParentID != "" {
+			if err := u.checkMove(ctx, callerID, folder, *update.ParentID); err != nil {
+				return nil, err
+			}
+		} else if err := checkUser(callerID, folder.UserID); err != nil {
+			return nil, err
+		}
+		folder.ParentID = *update.ParentID
+	}
+
+	if err := u.folderRepo.UpdateFolder(ctx, folder); err != nil {
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+// DeleteFolder deletes the folder with every folder below it and moves the
+// files in them to the trash.
+func (u *folderUseCase) DeleteFolder(ctx context.Context, callerID uint, id string) error {
+	lookupCtx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	folder, err := u.folder(lookupCtx, callerID, id, domain.PermissionWrite)
+	if err != nil {
+		return err
+	}
+
+	all, err := u.folderRepo.GetFoldersByUserID(lookupCtx, folder.UserID)
+	if err != nil {
+		return err
+	}
+	ids := descendantFolders(folder.ID, all)
+
+	// Files go through the file use case into the trash; their content and
+	// quota usage are released once the purger deletes them for good
+	for _, folderID := range ids {
+		files, err := u.fileRepo.GetFilesInFolder(lookupCtx, folder.UserID, folderID)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := u.fileUseCase.DeleteFile(ctx, callerID, file.ID); err != nil && !errors.Is(err, domain.ErrFileNotFound) {
+				return err
+			}
+		}
+	}
+
+	deleteCtx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	if err := u.folderRepo.DeleteFolders(deleteCtx, ids); err != nil {
+		return err
+	}
+
+	resources := make([]domain.Resource, 0, len(ids))
+	for _, folderID := range ids {
+		resources = append(resources, domain.Resource{Type: domain.ResourceFolder, ID: folderID})
+	}
+	u.access.deleteGrants(deleteCtx, resources...)
+
+	return nil
+}
+
+func (u *folderUseCase) contents(ctx context.Context, userID uint, folderID string) (*domain.FolderContents, error) {
+	folders, err := u.folderRepo.GetFoldersByParent(ctx, userID, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := u.fileRepo.GetFilesInFolder(ctx, userID, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := &domain.FolderContents{
+		Folders: []*domain.Folder{},
+		Files:   []*domain.UserFileMeta{},
+	}
+	contents.Folders = append(contents.Folders, folders...)
+	for _, file := range files {
+		contents.Files = append(contents.Files, fileMeta(file))
+	}
+
+	return contents, nil
+}
+
+// folder returns the folder with the given ID if the caller has the required
+// permission on it.
+func (u *folderUseCase) folder(ctx context.Context, callerID uint, id string, required domain.Permission) (*domain.Folder, error) {
+	folder, err := u.folderRepo.GetFolderByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.access.checkFolder(ctx, callerID, folder, required); err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+// userFolder returns the folder with the given ID if it belongs to the user
+// and the caller may write to it.
+func (u *folderUseCase) userFolder(ctx context.Context, callerID, userID uint, id string) (*domain.Folder, error) {
+	folder, err := u.folderRepo.GetFolderByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if folder.UserID != userID {
+		return nil, domain.ErrFolderNotFound
+	}
+
+	if err := u.access.checkFolder(ctx, callerID, folder, domain.PermissionWrite); err != nil {
+		return nil, err
+	}
+	return folder, nil
+}
+
+// checkMove verifies that folder can be moved into parentID, which must be a
+// folder of the same user that the caller may write to and that is neither
+// folder itself nor below it.
+func (u *folderUseCase) checkMove(ctx context.Context, callerID uint, folder *domain.Folder, parentID string) error {
+	parent, err := u.userFolder(ctx, callerID, folder.UserID, parentID)
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for {
+		if parent.ID == folder.ID {
+			return domain.ErrFolderCycle
+		}
+		if parent.ParentID == "" || seen[parent.ID] {
+			return nil
+		}
+		seen[parent.ID] = true
+
+		if parent, err = u.folderRepo.GetFolderByID(ctx, parent.ParentID); err != nil {
+			return err
+		}
+	}
+}
+
+// descendantFolders returns rootID followed by the IDs of every folder below it.
+func descendantFolders(rootID string, folders []*domain.Folder) []string {
+	children := map[string][]string{}
+	for _, folder := range folders {
+		children[folder.ParentID] = append(children[folder.ParentID], folder.ID)
+	}
+
+	ids := []string{rootID}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids
+}
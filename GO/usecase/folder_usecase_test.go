@@ -0,0 +1,145 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateFolder_Success(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockFileUseCase := new(mocks.FileUseCase)
+
+	useCase := NewFolderUseCase(mockUserRepo, mockFolderRepo, mockFileRepo, mockGrantRepo, mockFileUseCase, 2*time.Second)
+
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(1)).Return(&domain.User{ID: 1}, nil)
+	mockFolderRepo.On("GetFolderByID", mock.Anything, "parent").Return(&domain.Folder{ID: "parent", UserID: 1}, nil)
+	mockFolderRepo.On("CreateFolder", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.Folder).ID = "child"
+		}).
+		Return(nil)
+
+	folder, err := useCase.CreateFolder(context.Background(), 1, 1, domain.CreateFolderRequest{Name: " 2026 ", ParentID: "parent"})
+
+	require.NoError(t, err)
+	require.Equal(t, "child", folder.ID)
+	require.Equal(t, "2026", folder.Name)
+	require.Equal(t, "parent", folder.ParentID)
+	mockFolderRepo.AssertExpectations(t)
+}
+
+func TestCreateFolder_InvalidName(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockFileUseCase := new(mocks.FileUseCase)
+
+	useCase := NewFolderUseCase(mockUserRepo, mockFolderRepo, mockFileRepo, mockGrantRepo, mockFileUseCase, 2*time.Second)
+
+	folder, err := useCase.CreateFolder(context.Background(), 1, 1, domain.CreateFolderRequest{Name: "a/b"})
+
+	require.ErrorIs(t, err, domain.ErrInvalidFolderName)
+	require.Nil(t, folder)
+	mockFolderRepo.AssertNotCalled(t, "CreateFolder", mock.Anything, mock.Anything)
+}
+
+func TestResolveFolder_Nested(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockFileUseCase := new(mocks.FileUseCase)
+
+	useCase := NewFolderUseCase(mockUserRepo, mockFolderRepo, mockFileRepo, mockGrantRepo, mockFileUseCase, 2*time.Second)
+
+	mockFolderRepo.On("GetFolderByName", mock.Anything, uint(1), "", "reports").Return(&domain.Folder{ID: "reports", UserID: 1, Name: "reports"}, nil)
+	mockFolderRepo.On("GetFolderByName", mock.Anything, uint(1), "reports", "2026").
+		Return(&domain.Folder{ID: "2026", UserID: 1, ParentID: "reports", Name: "2026"}, nil)
+
+	folder, err := useCase.ResolveFolder(context.Background(), 1, 1, "/reports//2026/")
+
+	require.NoError(t, err)
+	require.Equal(t, "2026", folder.ID)
+}
+
+func TestResolveFolder_Root(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockFileUseCase := new(mocks.FileUseCase)
+
+	useCase := NewFolderUseCase(mockUserRepo, mockFolderRepo, mockFileRepo, mockGrantRepo, mockFileUseCase, 2*time.Second)
+
+	folder, err := useCase.ResolveFolder(context.Background(), 1, 1, "/")
+
+	require.ErrorIs(t, err, domain.ErrFolderNotFound)
+	require.Nil(t, folder)
+}
+
+func TestUpdateFolder_MoveIntoDescendant(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockFileUseCase := new(mocks.FileUseCase)
+
+	useCase := NewFolderUseCase(mockUserRepo, mockFolderRepo, mockFileRepo, mockGrantRepo, mockFileUseCase, 2*time.Second)
+
+	mockFolderRepo.On("GetFolderByID", mock.Anything, "a").Return(&domain.Folder{ID: "a", UserID: 1, Name: "a"}, nil)
+	mockFolderRepo.On("GetFolderByID", mock.Anything, "c").Return(&domain.Folder{ID: "c", UserID: 1, ParentID: "b"}, nil)
+	mockFolderRepo.On("GetFolderByID", mock.Anything, "b").Return(&domain.Folder{ID: "b", UserID: 1, ParentID: "a"}, nil)
+
+	parentID := "c"
+	folder, err := useCase.UpdateFolder(context.Background(), 1, "a", domain.FolderUpdate{ParentID: &parentID})
+
+	require.ErrorIs(t, err, domain.ErrFolderCycle)
+	require.Nil(t, folder)
+	mockFolderRepo.AssertNotCalled(t, "UpdateFolder", mock.Anything, mock.Anything)
+}
+
+func TestDeleteFolder_Recursive(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+	mockFileUseCase := new(mocks.FileUseCase)
+
+	useCase := NewFolderUseCase(mockUserRepo, mockFolderRepo, mockFileRepo, mockGrantRepo, mockFileUseCase, 2*time.Second)
+
+	mockFolderRepo.On("GetFolderByID", mock.Anything, "a").Return(&domain.Folder{ID: "a", UserID: 1}, nil)
+	mockFolderRepo.On("GetFoldersByUserID", mock.Anything, uint(1)).Return([]*domain.Folder{
+		{ID: "a", UserID: 1},
+		{ID: "b", UserID: 1, ParentID: "a"},
+		{ID: "c", UserID: 1, ParentID: "b"},
+		{ID: "other", UserID: 1},
+	}, nil)
+	mockFileRepo.On("GetFilesInFolder", mock.Anything, uint(1), "a").Return([]*domain.UserFile{{ID: "f1"}}, nil)
+	mockFileRepo.On("GetFilesInFolder", mock.Anything, uint(1), "b").Return([]*domain.UserFile{}, nil)
+	mockFileRepo.On("GetFilesInFolder", mock.Anything, uint(1), "c").Return([]*domain.UserFile{{ID: "f2"}}, nil)
+	mockFileUseCase.On("DeleteFile", mock.Anything, uint(1), "f1").Return(nil)
+	mockFileUseCase.On("DeleteFile", mock.Anything, uint(1), "f2").Return(domain.ErrFileNotFound)
+	mockFolderRepo.On("DeleteFolders", mock.Anything, []string{"a", "b", "c"}).Return(nil)
+	mockGrantRepo.On("DeleteResourceGrants", mock.Anything, []domain.Resource{
+		{Type: domain.ResourceFolder, ID: "a"},
+		{Type: domain.ResourceFolder, ID: "b"},
+		{Type: domain.ResourceFolder, ID: "c"},
+	}).Return(nil)
+
+	err := useCase.DeleteFolder(context.Background(), 1, "a")
+
+	require.NoError(t, err)
+	mockFolderRepo.AssertExpectations(t)
+	mockFileRepo.AssertExpectations(t)
+	mockFileUseCase.AssertExpectations(t)
+}
@@ -0,0 +1,63 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/config"
+	"github.com/OgiDac/CompanyTask/domain"
+)
+
+// defaultScrubPeriodDays is how often stored content is re-verified unless
+// FILE_SCRUB_PERIOD_DAYS is set.
+const defaultScrubPeriodDays = 30
+
+// scrubBatch bounds how many contents a single scrubber run reads back.
+const scrubBatch = 20
+
+func scrubPeriod(env *config.Env) time.Duration {
+	days := env.FileScrubPeriodDays
+	if days <= 0 {
+		days = defaultScrubPeriodDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// ScrubContent re-hashes stored content that wasn't verified within the scrub
+// period and returns how much of it was found corrupt. Files using corrupt
+// content are flagged until the same content is uploaded again. Content that
+// can't be read right now is retried on the next run.
+func (f *fileUseCase) ScrubContent(ctx context.Context) (int, error) {
+	listCtx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	digests, err := f.fileRepo.GetUnverifiedContent(listCtx, time.Now().Add(-f.scrubPeriod), scrubBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	corrupt := 0
+	var firstErr error
+	for _, digest := range digests {
+		err := f.verifyContent(ctx, digest)
+		if errors.Is(err, domain.ErrContentCorrupt) {
+			log.Printf("Stored content %s is corrupt", digest)
+			corrupt++
+			continue
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return corrupt, firstErr
+}
+
+func (f *fileUseCase) verifyContent(ctx context.Context, digest string) error {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	return f.fileRepo.VerifyContent(ctx, digest)
+}
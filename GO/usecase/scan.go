@@ -0,0 +1,139 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+)
+
+// scanBatch bounds how many files a single background scan run processes.
+const scanBatch = 20
+
+func (f *fileUseCase) ScanPendingFiles(ctx context.Context) (int, error) {
+	listCtx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	files, err := f.fileRepo.GetPendingScans(listCtx, scanBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	// The scanner being unreachable for one version must not hold up the others
+	scanned := 0
+	var firstErr error
+	for _, file := range files {
+		for _, v := range file.Versions {
+			if v.ScanStatus != domain.ScanPending {
+				continue
+			}
+			if err := f.scanVersion(ctx, file, v); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			scanned++
+		}
+	}
+
+	return scanned, firstErr
+}
+
+// scanVersion scans stored content of one version of file and records the verdict.
+func (f *fileUseCase) scanVersion(ctx context.Context, file *domain.UserFile, v domain.FileVersion) error {
+	content, err := f.fileRepo.OpenFileContent(ctx, file.AtVersion(v))
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	result, err := f.scanner.Scan(ctx, content)
+	if err != nil {
+		return err
+	}
+
+	saveCtx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	if err := f.fileRepo.SetScanResult(saveCtx, file, v.Number, *result); err != nil {
+		return err
+	}
+	if result.Status == domain.ScanInfected {
+		// Infected content is not searchable either
+		if v.Number == file.Version {
+			_ = f.fileRepo.SetFileText(saveCtx, file, "")
+		}
+		return f.quarantine(saveCtx, file, v, result.Signature)
+	}
+	return nil
+}
+
+// quarantine records an infected version for review. Its content stays
+// stored but can't be downloaded, shared or restored.
+func (f *fileUseCase) quarantine(ctx context.Context, file *domain.UserFile, v domain.FileVersion, signature string) error {
+	return f.fileRepo.QuarantineVersion(ctx, &domain.QuarantineEntry{
+		FileID:     file.ID,
+		UserID:     file.UserID,
+		Filename:   file.Filename,
+		Version:    v.Number,
+		Digest:     v.Digest,
+		Signature:  signature,
+		DetectedAt: time.Now().UTC(),
+	})
+}
+
+// startScan passes everything read from the returned reader on to the
+// scanner while it is being stored. finish ends the stream, with storeErr
+// when storing failed, and waits for the verdict. Content the scanner could
+// not give a verdict on stays pending and is retried by ScanPendingFiles.
+func (f *fileUseCase) startScan(ctx context.Context, content io.Reader) (io.Reader, func(storeErr error) domain.ScanResult) {
+	reader, writer := io.Pipe()
+	feed := &scanFeed{writer: writer}
+	results := make(chan domain.ScanResult, 1)
+
+	go func() {
+		result, err := f.scanner.Scan(ctx, reader)
+		// A scanner that stops reading early must not stall the upload
+		_ = reader.Close()
+		if err != nil || result == nil {
+			results <- domain.ScanResult{Status: domain.ScanPending}
+			return
+		}
+		results <- *result
+	}()
+
+	finish := func(storeErr error) domain.ScanResult {
+		if storeErr != nil {
+			_ = writer.CloseWithError(storeErr)
+		} else {
+			_ = writer.Close()
+		}
+
+		result := <-results
+		// A clean verdict only counts for content the scanner actually read
+		if feed.stopped && result.Status == domain.ScanClean {
+			return domain.ScanResult{Status: domain.ScanPending}
+		}
+		return result
+	}
+
+	return io.TeeReader(content, feed), finish
+}
+
+// scanFeed copies stored content to the scanner. Once the scanner stops
+// reading, the rest is dropped instead of failing the upload.
+type scanFeed struct {
+	writer  io.Writer
+	stopped bool
+}
+
+func (s *scanFeed) Write(p []byte) (int, error) {
+	if !s.stopped {
+		if _, err := s.writer.Write(p); err != nil {
+			s.stopped = true
+		}
+	}
+	return len(p), nil
+}
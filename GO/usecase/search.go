@@ -0,0 +1,70 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/OgiDac/CompanyTask/domain"
+)
+
+const (
+	// defaultSearchLimit is the page size of searches that don't ask for one
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+)
+
+// SearchFiles returns a page of the user's files matching search, ordered by
+// name unless another sort is given. Only the user can search their files.
+func (f *fileUseCase) SearchFiles(ctx context.Context, callerID, userID uint, search domain.FileSearch) (*domain.FileSearchResult, error) {
+	if err := checkUser(callerID, userID); err != nil {
+		return nil, err
+	}
+
+	if search.Sort == "" {
+		search.Sort = domain.FileSortName
+	}
+	if err := checkSearch(search); err != nil {
+		return nil, err
+	}
+	var err error
+	if search.Tags.Tags, err = normalizeTags(search.Tags.Tags); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case search.Limit == 0:
+		search.Limit = defaultSearchLimit
+	case search.Limit > maxSearchLimit:
+		search.Limit = maxSearchLimit
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	files, next, err := f.fileRepo.SearchFiles(ctx, userID, search)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.FileSearchResult{Files: []*domain.UserFileMeta{}, NextCursor: next}
+	for _, file := range files {
+		result.Files = append(result.Files, fileMeta(file))
+	}
+
+	return result, nil
+}
+
+func checkSearch(search domain.FileSearch) error {
+	if !search.Sort.Valid() || search.Limit < 0 {
+		return domain.ErrInvalidSearch
+	}
+	if (search.MinSize != nil && *search.MinSize < 0) || (search.MaxSize != nil && *search.MaxSize < 0) {
+		return domain.ErrInvalidSearch
+	}
+	if search.MinSize != nil && search.MaxSize != nil && *search.MinSize > *search.MaxSize {
+		return domain.ErrInvalidSearch
+	}
+	if search.UploadedAfter != nil && search.UploadedBefore != nil && !search.UploadedAfter.Before(*search.UploadedBefore) {
+		return domain.ErrInvalidSearch
+	}
+	return nil
+}
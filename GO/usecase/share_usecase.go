@@ -0,0 +1,200 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// shareTokenBytes is the amount of randomness in a share token
+const shareTokenBytes = 32
+
+type shareUseCase struct {
+	shareRepo repository.ShareRepository
+	fileRepo  repository.FileRepository
+	access    accessControl
+	timeout   time.Duration
+}
+
+func NewShareUseCase(
+	shareRepo repository.ShareRepository,
+	fileRepo repository.FileRepository,
+	folderRepo repository.FolderRepository,
+	grantRepo repository.GrantRepository,
+	timeout time.Duration,
+) domain.ShareUseCase {
+	return &shareUseCase{
+		shareRepo: shareRepo,
+		fileRepo:  fileRepo,
+		access:    accessControl{folderRepo: folderRepo, grantRepo: grantRepo},
+		timeout:   timeout,
+	}
+}
+
+// CreateShare makes the file public to anyone with the token, so the caller
+// needs write access to it.
+func (u *shareUseCase) CreateShare(ctx context.Context, callerID uint, fileID string, request domain.CreateShareRequest) (*domain.CreateShareResponse, error) {
+	if request.MaxDownloads < 0 || (request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now())) {
+		return nil, domain.ErrInvalidShare
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	file, err := u.file(ctx, callerID, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	link := &domain.ShareLink{
+		FileID:       file.ID,
+		UserID:       file.UserID,
+		TokenHash:    hashShareToken(token),
+		ExpiresAt:    request.ExpiresAt,
+		MaxDownloads: request.MaxDownloads,
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	if request.Password != "" {
+		passwordHash, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		link.PasswordHash = string(passwordHash)
+	}
+
+	if err := u.shareRepo.CreateShare(ctx, link); err != nil {
+		return nil, err
+	}
+
+	return &domain.CreateShareResponse{Link: link, Token: token}, nil
+}
+
+func (u *shareUseCase) GetFileShares(ctx context.Context, callerID uint, fileID string) ([]*domain.ShareLink, error) {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	if _, err := u.file(ctx, callerID, fileID); err != nil {
+		return nil, err
+	}
+
+	return u.shareRepo.GetSharesByFileID(ctx, fileID)
+}
+
+func (u *shareUseCase) GetUserShares(ctx context.Context, callerID, userID uint) ([]*domain.ShareLink, error) {
+	if err := checkUser(callerID, userID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	return u.shareRepo.GetSharesByUserID(ctx, userID)
+}
+
+func (u *shareUseCase) RevokeShare(ctx context.Context, callerID uint, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	link, err := u.shareRepo.GetShareByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Links of deleted files can only be revoked by their owner
+	if link.UserID != callerID {
+		if _, err := u.file(ctx, callerID, link.FileID); errors.Is(err, domain.ErrFileNotFound) {
+			return domain.ErrForbidden
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return u.shareRepo.DeleteShare(ctx, id)
+}
+
+func (u *shareUseCase) OpenShare(ctx context.Context, token, password string) (*domain.UserFile, io.ReadSeekCloser, error) {
+	lookupCtx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	link, err := u.shareRepo.GetShareByTokenHash(lookupCtx, hashShareToken(token))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt) {
+		return nil, nil, domain.ErrShareExpired
+	}
+	if link.PasswordHash != "" && bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+		return nil, nil, domain.ErrSharePasswordWrong
+	}
+	if link.MaxDownloads > 0 && link.Downloads >= link.MaxDownloads {
+		return nil, nil, domain.ErrShareLimitReached
+	}
+
+	file, err := u.fileRepo.GetFileByID(lookupCtx, link.FileID)
+	if errors.Is(err, domain.ErrFileNotFound) {
+		// The file was deleted after the link was created
+		return nil, nil, domain.ErrShareNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := file.ScanStatus.Check(); err != nil {
+		return nil, nil, err
+	}
+
+	// The stream outlives this call, so it is bound to the request context only
+	content, err := u.fileRepo.OpenFileContent(ctx, file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return file, content, nil
+}
+
+func (u *shareUseCase) CountShareDownload(ctx context.Context, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	link, err := u.shareRepo.GetShareByTokenHash(ctx, hashShareToken(token))
+	if err != nil {
+		return err
+	}
+	return u.shareRepo.CountDownload(ctx, link)
+}
+
+// hashShareToken returns the hex SHA-256 under which a token is stored.
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// file returns the file with the given ID if the caller may write to it.
+func (u *shareUseCase) file(ctx context.Context, callerID uint, id string) (*domain.UserFile, error) {
+	file, err := u.fileRepo.GetFileByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.access.checkFile(ctx, callerID, file, domain.PermissionWrite); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
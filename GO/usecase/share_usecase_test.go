@@ -0,0 +1,167 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCreateShare_StoresOnlyHashes(t *testing.T) {
+	mockShareRepo := new(mocks.ShareRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+
+	useCase := NewShareUseCase(mockShareRepo, mockFileRepo, mockFolderRepo, mockGrantRepo, 2*time.Second)
+
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(&domain.UserFile{ID: "abc123", UserID: 1}, nil)
+	mockShareRepo.On("CreateShare", mock.Anything, mock.Anything).Return(nil)
+
+	response, err := useCase.CreateShare(context.Background(), 1, "abc123", domain.CreateShareRequest{Password: "secret", MaxDownloads: 3})
+
+	require.NoError(t, err)
+	require.NotEmpty(t, response.Token)
+	link := mockShareRepo.Calls[0].Arguments.Get(1).(*domain.ShareLink)
+	require.Equal(t, hashShareToken(response.Token), link.TokenHash)
+	require.NotEqual(t, response.Token, link.TokenHash)
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte("secret")))
+	require.Equal(t, uint(1), link.UserID)
+	require.Equal(t, 3, link.MaxDownloads)
+}
+
+func TestCreateShare_ExpiryInPast(t *testing.T) {
+	mockShareRepo := new(mocks.ShareRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+
+	useCase := NewShareUseCase(mockShareRepo, mockFileRepo, mockFolderRepo, mockGrantRepo, 2*time.Second)
+
+	past := time.Now().Add(-time.Hour)
+	response, err := useCase.CreateShare(context.Background(), 1, "abc123", domain.CreateShareRequest{ExpiresAt: &past})
+
+	require.ErrorIs(t, err, domain.ErrInvalidShare)
+	require.Nil(t, response)
+	mockShareRepo.AssertNotCalled(t, "CreateShare", mock.Anything, mock.Anything)
+}
+
+func TestOpenShare_Success(t *testing.T) {
+	mockShareRepo := new(mocks.ShareRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+
+	useCase := NewShareUseCase(mockShareRepo, mockFileRepo, mockFolderRepo, mockGrantRepo, 2*time.Second)
+
+	link := &domain.ShareLink{ID: "link1", FileID: "abc123", MaxDownloads: 2, Downloads: 1}
+	file := &domain.UserFile{ID: "abc123", Filename: "file.txt", ScanStatus: domain.ScanClean}
+
+	mockShareRepo.On("GetShareByTokenHash", mock.Anything, hashShareToken("token")).Return(link, nil)
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").Return(file, nil)
+	mockFileRepo.On("OpenFileContent", mock.Anything, file).Return(mocks.NewContent("data"), nil)
+
+	result, content, err := useCase.OpenShare(context.Background(), "token", "")
+
+	require.NoError(t, err)
+	require.Equal(t, file, result)
+	require.NotNil(t, content)
+	mockShareRepo.AssertNotCalled(t, "CountDownload", mock.Anything, mock.Anything)
+}
+
+func TestCountShareDownload(t *testing.T) {
+	mockShareRepo := new(mocks.ShareRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+
+	useCase := NewShareUseCase(mockShareRepo, mockFileRepo, mockFolderRepo, mockGrantRepo, 2*time.Second)
+
+	link := &domain.ShareLink{ID: "link1", FileID: "abc123", MaxDownloads: 2, Downloads: 2}
+	mockShareRepo.On("GetShareByTokenHash", mock.Anything, hashShareToken("token")).Return(link, nil)
+	mockShareRepo.On("CountDownload", mock.Anything, link).Return(domain.ErrShareLimitReached)
+
+	err := useCase.CountShareDownload(context.Background(), "token")
+
+	require.ErrorIs(t, err, domain.ErrShareLimitReached)
+	mockShareRepo.AssertExpectations(t)
+}
+
+func TestOpenShare_Expired(t *testing.T) {
+	mockShareRepo := new(mocks.ShareRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+
+	useCase := NewShareUseCase(mockShareRepo, mockFileRepo, mockFolderRepo, mockGrantRepo, 2*time.Second)
+
+	expired := time.Now().Add(-time.Minute)
+	mockShareRepo.On("GetShareByTokenHash", mock.Anything, hashShareToken("token")).
+		Return(&domain.ShareLink{ID: "link1", FileID: "abc123", ExpiresAt: &expired}, nil)
+
+	_, _, err := useCase.OpenShare(context.Background(), "token", "")
+
+	require.ErrorIs(t, err, domain.ErrShareExpired)
+	mockFileRepo.AssertNotCalled(t, "OpenFileContent", mock.Anything, mock.Anything)
+}
+
+func TestOpenShare_WrongPassword(t *testing.T) {
+	mockShareRepo := new(mocks.ShareRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+
+	useCase := NewShareUseCase(mockShareRepo, mockFileRepo, mockFolderRepo, mockGrantRepo, 2*time.Second)
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	mockShareRepo.On("GetShareByTokenHash", mock.Anything, hashShareToken("token")).
+		Return(&domain.ShareLink{ID: "link1", FileID: "abc123", PasswordHash: string(passwordHash)}, nil)
+
+	_, _, err = useCase.OpenShare(context.Background(), "token", "guess")
+
+	require.ErrorIs(t, err, domain.ErrSharePasswordWrong)
+	mockFileRepo.AssertNotCalled(t, "OpenFileContent", mock.Anything, mock.Anything)
+}
+
+func TestOpenShare_LimitReached(t *testing.T) {
+	mockShareRepo := new(mocks.ShareRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+
+	useCase := NewShareUseCase(mockShareRepo, mockFileRepo, mockFolderRepo, mockGrantRepo, 2*time.Second)
+
+	mockShareRepo.On("GetShareByTokenHash", mock.Anything, hashShareToken("token")).
+		Return(&domain.ShareLink{ID: "link1", FileID: "abc123", MaxDownloads: 2, Downloads: 2}, nil)
+
+	_, _, err := useCase.OpenShare(context.Background(), "token", "")
+
+	require.ErrorIs(t, err, domain.ErrShareLimitReached)
+	mockShareRepo.AssertNotCalled(t, "CountDownload", mock.Anything, mock.Anything)
+}
+
+func TestOpenShare_Quarantined(t *testing.T) {
+	mockShareRepo := new(mocks.ShareRepository)
+	mockFileRepo := new(mocks.FileRepository)
+	mockFolderRepo := new(mocks.FolderRepository)
+	mockGrantRepo := new(mocks.GrantRepository)
+
+	useCase := NewShareUseCase(mockShareRepo, mockFileRepo, mockFolderRepo, mockGrantRepo, 2*time.Second)
+
+	mockShareRepo.On("GetShareByTokenHash", mock.Anything, hashShareToken("token")).
+		Return(&domain.ShareLink{ID: "link1", FileID: "abc123"}, nil)
+	mockFileRepo.On("GetFileByID", mock.Anything, "abc123").
+		Return(&domain.UserFile{ID: "abc123", ScanStatus: domain.ScanInfected}, nil)
+
+	_, _, err := useCase.OpenShare(context.Background(), "token", "")
+
+	require.ErrorIs(t, err, domain.ErrFileQuarantined)
+	mockShareRepo.AssertNotCalled(t, "CountDownload", mock.Anything, mock.Anything)
+	mockFileRepo.AssertNotCalled(t, "OpenFileContent", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,101 @@
+package usecase
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/OgiDac/CompanyTask/domain"
+)
+
+const (
+	// maxFileTags bounds the tags of a single file
+	maxFileTags  = 32
+	maxTagLength = 64
+)
+
+// normalizeTags lowercases tags and collapses their whitespace, so tags that
+// only differ in case or spacing are one tag. The result is sorted without
+// duplicates. Commas separate tags in uploads, so no tag contains one.
+func normalizeTags(tags []string) ([]string, error) {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		if tag == "" || len(tag) > maxTagLength || strings.Contains(tag, ",") {
+			return nil, domain.ErrInvalidTag
+		}
+		normalized = append(normalized, tag)
+	}
+
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+	if len(normalized) > maxFileTags {
+		return nil, domain.ErrTooManyTags
+	}
+	return normalized, nil
+}
+
+// mergeTags returns the sorted union of two normalized tag lists.
+func mergeTags(tags, added []string) []string {
+	merged := append(slices.Clone(tags), added...)
+	slices.Sort(merged)
+	return slices.Compact(merged)
+}
+
+func (f *fileUseCase) SetFileTags(ctx context.Context, callerID uint, id string, tags []string) (*domain.UserFileMeta, error) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	file, err := f.file(ctx, callerID, id, domain.PermissionWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.fileRepo.SetFileTags(ctx, file, tags); err != nil {
+		return nil, err
+	}
+
+	return fileMeta(file), nil
+}
+
+// GetTags lists the tags of the user's files. Only the user can list them.
+func (f *fileUseCase) GetTags(ctx context.Context, callerID, userID uint) ([]*domain.TagCount, error) {
+	if err := checkUser(callerID, userID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	return f.fileRepo.GetTagCounts(ctx, userID)
+}
+
+// RenameTags renames tags on all of the user's files. Only the user can
+// rename their tags.
+func (f *fileUseCase) RenameTags(ctx context.Context, callerID, userID uint, rename domain.TagRename) (int, error) {
+	if err := checkUser(callerID, userID); err != nil {
+		return 0, err
+	}
+
+	from, err := normalizeTags(rename.From)
+	if err != nil {
+		return 0, err
+	}
+	to, err := normalizeTags([]string{rename.To})
+	if err != nil {
+		return 0, err
+	}
+	if len(from) == 0 {
+		return 0, domain.ErrInvalidTag
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	return f.fileRepo.RenameTags(ctx, userID, from, to[0])
+}
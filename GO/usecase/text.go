@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/fulltext"
+)
+
+const (
+	// defaultContentSearchLimit is the number of results of content searches
+	// that don't ask for one
+	defaultContentSearchLimit = 20
+	maxContentSearchLimit     = 100
+	// contentCandidates bounds the matches ranked before the caller's access
+	// to them is checked
+	contentCandidates = 200
+	// snippetWidth is the approximate length of result snippets in characters
+	snippetWidth = 160
+)
+
+// indexText stores the searchable text of the current version of file.
+// Files without extractable text and infected ones are left out of full-text
+// search; a file's first version has no earlier text to remove.
+func (f *fileUseCase) indexText(ctx context.Context, file *domain.UserFile) error {
+	format := fulltext.FormatOf(file.ContentType, file.Filename)
+	if format == "" || file.ScanStatus == domain.ScanInfected {
+		if file.Version <= 1 {
+			return nil
+		}
+		return f.fileRepo.SetFileText(ctx, file, "")
+	}
+
+	content, err := f.fileRepo.OpenFileContent(ctx, file)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	text, err := fulltext.Extract(format, io.LimitReader(content, fulltext.MaxContentLength))
+	if err != nil {
+		return err
+	}
+
+	return f.fileRepo.SetFileText(ctx, file, text)
+}
+
+// SearchContent searches the text of the caller's files and of files other
+// users shared with them. Files the caller can't read are left out.
+func (f *fileUseCase) SearchContent(ctx context.Context, callerID uint, query string, limit int) ([]*domain.ContentSearchHit, error) {
+	query = strings.TrimSpace(query)
+	if len(fulltext.Terms(query)) == 0 || limit < 0 {
+		return nil, domain.ErrInvalidSearch
+	}
+	switch {
+	case limit == 0:
+		limit = defaultContentSearchLimit
+	case limit > maxContentSearchLimit:
+		limit = maxContentSearchLimit
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	// Only owners who granted the caller something can have files it may read
+	owners := []uint{callerID}
+	grants, err := f.access.grantRepo.GetGrantsByUserID(ctx, callerID)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[uint]bool{callerID: true}
+	for _, grant := range grants {
+		if !seen[grant.OwnerID] {
+			seen[grant.OwnerID] = true
+			owners = append(owners, grant.OwnerID)
+		}
+	}
+
+	matches, err := f.fileRepo.SearchFileText(ctx, query, owners, contentCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := []*domain.ContentSearchHit{}
+	for _, match := range matches {
+		if len(hits) == limit {
+			break
+		}
+
+		// Trashed files and text of a version that is no longer current don't match
+		file, err := f.fileRepo.GetFileByID(ctx, match.FileID)
+		if errors.Is(err, domain.ErrFileNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if file.Version != match.Version {
+			continue
+		}
+		err = f.access.checkFile(ctx, callerID, file, domain.PermissionRead)
+		if errors.Is(err, domain.ErrForbidden) || errors.Is(err, domain.ErrFolderNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		text, err := f.fileRepo.GetFileText(ctx, file.ID)
+		if errors.Is(err, domain.ErrFileNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		hits = append(hits, &domain.ContentSearchHit{
+			UserFileMeta: fileMeta(file),
+			OwnerID:      file.UserID,
+			Score:        match.Score,
+			Snippet:      fulltext.Snippet(text, query, snippetWidth),
+		})
+	}
+
+	return hits, nil
+}
@@ -0,0 +1,240 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+)
+
+// thumbnailBatch bounds how many files a single generator run processes.
+const thumbnailBatch = 20
+
+// maxThumbnailPixels refuses images whose decoded form would take too much memory.
+const maxThumbnailPixels = 40_000_000
+
+// errUnusableImage marks content that will never yield thumbnails, as opposed
+// to failures reading it that are worth retrying.
+var errUnusableImage = errors.New("unusable image")
+
+func (f *fileUseCase) GetThumbnail(ctx context.Context, callerID uint, id string, size domain.ThumbnailSize) (*domain.Thumbnail, io.ReadSeekCloser, error) {
+	if !validThumbnailSize(size) {
+		return nil, nil, domain.ErrInvalidThumbnailSize
+	}
+
+	file, err := f.GetFileByID(ctx, callerID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	thumbnail, ok := file.FindThumbnail(size)
+	if !ok {
+		return nil, nil, domain.ErrThumbnailNotFound
+	}
+
+	content, err := f.fileRepo.OpenThumbnail(ctx, thumbnail)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &thumbnail, content, nil
+}
+
+func (f *fileUseCase) GenerateThumbnails(ctx context.Context) (int, error) {
+	listCtx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	files, err := f.fileRepo.GetPendingThumbnails(listCtx, thumbnailBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	// One file failing to load must not hold up the others
+	generated := 0
+	var firstErr error
+	for _, file := range files {
+		if err := f.generateThumbnails(ctx, file); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		generated++
+	}
+
+	return generated, firstErr
+}
+
+// generateThumbnails stores every thumbnail size of the current version of
+// file. Content that can't be decoded as an image is marked failed so it isn't
+// tried again.
+func (f *fileUseCase) generateThumbnails(ctx context.Context, file *domain.UserFile) error {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	img, format, err := f.decodeImage(ctx, file)
+	if errors.Is(err, errUnusableImage) {
+		return f.fileRepo.SetThumbnails(ctx, file, domain.ThumbnailFailed, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	var thumbnails []domain.Thumbnail
+	for _, s := range domain.ThumbnailSizes {
+		resized := resizeToFit(img, s.Edge)
+
+		var buf bytes.Buffer
+		contentType, err := encodeThumbnail(&buf, resized, format)
+		if err != nil {
+			_ = f.fileRepo.DeleteThumbnails(context.Background(), thumbnails)
+			return err
+		}
+
+		thumbnail := domain.Thumbnail{
+			Size:        s.Size,
+			ContentType: contentType,
+			Width:       resized.Bounds().Dx(),
+			Height:      resized.Bounds().Dy(),
+			CreatedAt:   time.Now().UTC(),
+		}
+		if err := f.fileRepo.StoreThumbnail(ctx, file, &thumbnail, &buf); err != nil {
+			_ = f.fileRepo.DeleteThumbnails(context.Background(), thumbnails)
+			return err
+		}
+		thumbnails = append(thumbnails, thumbnail)
+	}
+
+	return f.fileRepo.SetThumbnails(ctx, file, domain.ThumbnailReady, thumbnails)
+}
+
+// decodeImage reads the current version of file as an image and returns it
+// with its format. The size is checked before the pixels are decoded.
+func (f *fileUseCase) decodeImage(ctx context.Context, file *domain.UserFile) (image.Image, string, error) {
+	content, err := f.fileRepo.OpenFileContent(ctx, file)
+	if err != nil {
+		return nil, "", err
+	}
+	defer content.Close()
+
+	reader := &readErrorRecorder{reader: content}
+	config, format, err := image.DecodeConfig(reader)
+	if err != nil {
+		return nil, "", reader.failure()
+	}
+	if config.Width <= 0 || config.Height <= 0 || int64(config.Width)*int64(config.Height) > maxThumbnailPixels {
+		return nil, "", errUnusableImage
+	}
+
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	img, format, err := image.Decode(reader)
+	if err != nil {
+		return nil, "", reader.failure()
+	}
+
+	return img, format, nil
+}
+
+// readErrorRecorder remembers failures of the underlying reader, so they can
+// be told apart from content the image decoders reject.
+type readErrorRecorder struct {
+	reader io.Reader
+	err    error
+}
+
+func (r *readErrorRecorder) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// failure returns the read error behind a failed decode, or errUnusableImage
+// when the content itself was rejected.
+func (r *readErrorRecorder) failure() error {
+	if r.err != nil {
+		return r.err
+	}
+	return errUnusableImage
+}
+
+// encodeThumbnail writes img as JPEG for photos and as PNG otherwise, which
+// keeps transparency, and returns the content type written.
+func encodeThumbnail(w io.Writer, img image.Image, format string) (string, error) {
+	if format == "jpeg" {
+		return "image/jpeg", jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	}
+	return "image/png", png.Encode(w, img)
+}
+
+// resizeToFit scales src down to fit a square of edge pixels, keeping its
+// aspect ratio. Every target pixel is the average of the source pixels it
+// covers. Images that already fit are returned at their own size.
+func resizeToFit(src image.Image, edge int) *image.RGBA {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// Premultiplied alpha keeps transparent pixels from bleeding into the average
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	tw, th := w, h
+	if w > edge || h > edge {
+		if w >= h {
+			tw, th = edge, max(1, h*edge/w)
+		} else {
+			tw, th = max(1, w*edge/h), edge
+		}
+	}
+	if tw == w && th == h {
+		return rgba
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := y*h/th, (y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := x*w/tw, (x+1)*w/tw
+
+			var sum [4]uint64
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					sum[0] += uint64(p[0])
+					sum[1] += uint64(p[1])
+					sum[2] += uint64(p[2])
+					sum[3] += uint64(p[3])
+				}
+			}
+
+			n := uint64((y1 - y0) * (x1 - x0))
+			d := dst.Pix[y*dst.Stride+x*4:]
+			for i := range sum {
+				d[i] = uint8(sum[i] / n)
+			}
+		}
+	}
+
+	return dst
+}
+
+func validThumbnailSize(size domain.ThumbnailSize) bool {
+	for _, s := range domain.ThumbnailSizes {
+		if s.Size == size {
+			return true
+		}
+	}
+	return false
+}
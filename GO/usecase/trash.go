@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/config"
+	"github.com/OgiDac/CompanyTask/domain"
+)
+
+// defaultTrashRetentionDays is how long deleted files are kept unless
+// FILE_TRASH_RETENTION_DAYS is set.
+const defaultTrashRetentionDays = 30
+
+// trashBatch bounds how many files a single purger run deletes.
+const trashBatch = 100
+
+func trashRetention(env *config.Env) time.Duration {
+	days := env.FileTrashRetentionDays
+	if days <= 0 {
+		days = defaultTrashRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// GetTrash lists the user's deleted files. Only the user can see their trash.
+func (f *fileUseCase) GetTrash(ctx context.Context, callerID, userID uint) ([]*domain.UserFileMeta, error) {
+	if err := checkUser(callerID, userID); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	files, err := f.fileRepo.GetTrashedFiles(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := []*domain.UserFileMeta{}
+	for _, file := range files {
+		meta = append(meta, fileMeta(file))
+	}
+
+	return meta, nil
+}
+
+// RestoreFile takes a file out of the owner's trash, back into the folder it
+// was deleted from. Files of folders that were deleted since go to the root.
+func (f *fileUseCase) RestoreFile(ctx context.Context, callerID uint, id string) (*domain.UserFileMeta, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	file, err := f.fileRepo.GetTrashedFile(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUser(callerID, file.UserID); err != nil {
+		return nil, err
+	}
+	// Expired files wait in the trash only until the sweeper deletes them
+	if file.ExpiresAt != nil && !file.ExpiresAt.After(time.Now()) {
+		return nil, domain.ErrFileExpired
+	}
+
+	folderID := file.FolderID
+	if folderID != "" {
+		folder, err := f.folderRepo.GetFolderByID(ctx, folderID)
+		switch {
+		case errors.Is(err, domain.ErrFolderNotFound):
+			folderID = ""
+		case err != nil:
+			return nil, err
+		case folder.UserID != file.UserID:
+			folderID = ""
+		}
+	}
+
+	if err := f.fileRepo.RestoreFile(ctx, file, folderID); err != nil {
+		return nil, err
+	}
+
+	return fileMeta(file), nil
+}
+
+// EmptyTrash permanently deletes every file in the user's trash and returns
+// how many were deleted.
+func (f *fileUseCase) EmptyTrash(ctx context.Context, callerID, userID uint) (int, error) {
+	if err := checkUser(callerID, userID); err != nil {
+		return 0, err
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	files, err := f.fileRepo.GetTrashedFiles(listCtx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return f.purge(ctx, files, f.fileRepo.DeleteFile)
+}
+
+func (f *fileUseCase) PurgeTrash(ctx context.Context) (int, error) {
+	listCtx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	files, err := f.fileRepo.GetExpiredTrash(listCtx, time.Now().Add(-f.trashRetention), trashBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	return f.purge(ctx, files, f.fileRepo.DeleteFile)
+}
+
+// purge permanently deletes files with remove and releases their usage and
+// grants. One file failing doesn't hold up the others; files remove no longer
+// matches, like files restored meanwhile, are skipped.
+func (f *fileUseCase) purge(ctx context.Context, files []*domain.UserFile, remove func(context.Context, *domain.UserFile) error) (int, error) {
+	purged := 0
+	var firstErr error
+	for _, file := range files {
+		err := f.purgeFile(ctx, file, remove)
+		if errors.Is(err, domain.ErrFileNotFound) {
+			continue
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		purged++
+	}
+
+	return purged, firstErr
+}
+
+func (f *fileUseCase) purgeFile(ctx context.Context, file *domain.UserFile, remove func(context.Context, *domain.UserFile) error) error {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+
+	if err := remove(ctx, file); err != nil {
+		return err
+	}
+	f.access.deleteGrants(ctx, domain.Resource{Type: domain.ResourceFile, ID: file.ID})
+
+	var size int64
+	for _, v := range file.Versions {
+		size += v.Size
+	}
+
+	return f.quotaRepo.AddUsage(ctx, file.UserID, -size, -1)
+}
@@ -0,0 +1,211 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/integrity"
+	"github.com/OgiDac/CompanyTask/repository"
+)
+
+type uploadUseCase struct {
+	userRepo    repository.UserRepository
+	uploadRepo  repository.UploadRepository
+	fileUseCase domain.FileUseCase
+	expiry      time.Duration
+	timeout     time.Duration
+}
+
+func NewUploadUseCase(
+	userRepo repository.UserRepository,
+	uploadRepo repository.UploadRepository,
+	fileUseCase domain.FileUseCase,
+	expiry time.Duration,
+	timeout time.Duration,
+) domain.UploadUseCase {
+	return &uploadUseCase{
+		userRepo:    userRepo,
+		uploadRepo:  uploadRepo,
+		fileUseCase: fileUseCase,
+		expiry:      expiry,
+		timeout:     timeout,
+	}
+}
+
+func (u *uploadUseCase) CreateUpload(ctx context.Context, callerID, userID uint, length int64, folderID, filename, contentType string, digests []domain.ContentDigest) (*domain.FileUpload, error) {
+	if length < 0 {
+		return nil, errors.New("invalid upload length")
+	}
+	// Reject digests that can never match now rather than after the upload
+	if _, err := integrity.NewVerifier(digests); err != nil {
+		return nil, err
+	}
+
+	createCtx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	// Fail early instead of after the whole file has been sent
+	if _, err := u.userRepo.GetUserByID(createCtx, userID); err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	if filename == "" {
+		filename = "upload"
+	}
+
+	if err := u.fileUseCase.CheckUpload(createCtx, callerID, userID, folderID, filename, length); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	upload := &domain.FileUpload{
+		UserID:      userID,
+		CreatedBy:   callerID,
+		FolderID:    folderID,
+		Filename:    filename,
+		ContentType: contentType,
+		Length:      length,
+		Digests:     digests,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(u.expiry),
+	}
+
+	if err := u.uploadRepo.CreateUpload(createCtx, upload); err != nil {
+		return nil, err
+	}
+
+	if upload.Length == 0 {
+		if err := u.complete(ctx, upload); err != nil {
+			return nil, err
+		}
+	}
+
+	return upload, nil
+}
+
+func (u *uploadUseCase) GetUpload(ctx context.Context, callerID uint, id string) (*domain.FileUpload, error) {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	upload, err := u.upload(ctx, callerID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(upload.ExpiresAt) {
+		return nil, domain.ErrUploadExpired
+	}
+
+	return upload, nil
+}
+
+func (u *uploadUseCase) WriteChunk(ctx context.Context, callerID uint, id string, offset int64, chunk io.Reader) (*domain.FileUpload, error) {
+	upload, err := u.GetUpload(ctx, callerID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset != upload.Offset {
+		return nil, domain.ErrUploadOffsetMismatch
+	}
+	if upload.Completed() {
+		return upload, nil
+	}
+
+	// Chunks are streamed under the request context; they can take far longer than the timeout
+	remaining := upload.Length - upload.Offset
+	_, err = u.uploadRepo.AppendChunk(ctx, upload, io.LimitReader(chunk, remaining), time.Now().UTC().Add(u.expiry))
+	if err != nil {
+		return upload, err
+	}
+
+	if upload.Offset == upload.Length {
+		if err := u.complete(ctx, upload); err != nil {
+			return upload, err
+		}
+	}
+
+	return upload, nil
+}
+
+func (u *uploadUseCase) TerminateUpload(ctx context.Context, callerID uint, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, u.timeout)
+	defer cancel()
+
+	upload, err := u.upload(ctx, callerID, id)
+	if err != nil {
+		return err
+	}
+
+	return u.uploadRepo.DeleteUpload(ctx, upload)
+}
+
+func (u *uploadUseCase) PurgeExpiredUploads(ctx context.Context) (int, error) {
+	uploads, err := u.uploadRepo.GetExpiredUploads(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, upload := range uploads {
+		if err := u.uploadRepo.DeleteUpload(ctx, upload); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// complete hands the assembled upload to the file use case, so finished
+// uploads go through the same user check and storage path as regular ones.
+// An upload the file use case rejects is deleted, as sending it again can't
+// change the outcome; other failures keep it, so an empty PATCH at its full
+// length retries storing it.
+func (u *uploadUseCase) complete(ctx context.Context, upload *domain.FileUpload) error {
+	content, err := u.uploadRepo.OpenUploadContent(ctx, upload)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	meta, err := u.fileUseCase.UploadFile(ctx, upload.Creator(), upload.UserID, upload.FolderID, upload.Filename, upload.ContentType, nil, nil, upload.Digests, content)
+	if err != nil {
+		if uploadRejected(err) {
+			_ = u.uploadRepo.DeleteUpload(context.Background(), upload)
+		}
+		return err
+	}
+
+	return u.uploadRepo.CompleteUpload(ctx, upload, meta.ID)
+}
+
+// uploadRejected reports whether the file use case refused the content itself
+// rather than failing to store it.
+func uploadRejected(err error) bool {
+	for _, rejected := range []error{
+		domain.ErrDigestMismatch, domain.ErrContentTypeMismatch, domain.ErrContentTypeNotAllowed,
+		domain.ErrQuotaExceeded, domain.ErrInvalidFilename, domain.ErrFolderNotFound, domain.ErrForbidden,
+	} {
+		if errors.Is(err, rejected) {
+			return true
+		}
+	}
+	return err.Error() == "user not found"
+}
+
+// upload returns the upload with the given ID if the caller started it.
+func (u *uploadUseCase) upload(ctx context.Context, callerID uint, id string) (*domain.FileUpload, error) {
+	upload, err := u.uploadRepo.GetUploadByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if upload.Creator() != callerID {
+		return nil, domain.ErrForbidden
+	}
+	return upload, nil
+}
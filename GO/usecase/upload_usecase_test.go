@@ -0,0 +1,212 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OgiDac/CompanyTask/domain"
+	"github.com/OgiDac/CompanyTask/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateUpload_UserNotFound(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockUploadRepo := new(mocks.UploadRepository)
+	mockFileUseCase := new(mocks.FileUseCase)
+
+	useCase := NewUploadUseCase(mockUserRepo, mockUploadRepo, mockFileUseCase, time.Hour, 2*time.Second)
+
+	mockUserRepo.On("GetUserByID", mock.Anything, uint(2)).Return(nil, errors.New("record not found"))
+
+	upload, err := useCase.CreateUpload(context.Background(), 2, 2, 10, "", "file.txt", "text/plain", nil)
+
+	require.EqualError(t, err, "user not found")
+	require.Nil(t, upload)
+	mockUploadRepo.AssertNotCalled(t, "CreateUpload", mock.Anything, mock.Anything)
+}
+
+func TestWriteChunk_OffsetMismatch(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockUploadRepo := new(mocks.UploadRepository)
+	mockFileUseCase := new(mocks.FileUseCase)
+
+	useCase := NewUploadUseCase(mockUserRepo, mockUploadRepo, mockFileUseCase, time.Hour, 2*time.Second)
+
+	mockUploadRepo.On("GetUploadByID", mock.Anything, "up1").Return(&domain.FileUpload{
+		ID:        "up1",
+		UserID:    1,
+		Length:    10,
+		Offset:    4,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}, nil)
+
+	_, err := useCase.WriteChunk(context.Background(), 1, "up1", 2, strings.NewReader("data"))
+
+	require.ErrorIs(t, err, domain.ErrUploadOffsetMismatch)
+	mockUploadRepo.AssertNotCalled(t, "AppendChunk", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWriteChunk_Expired(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockUploadRepo := new(mocks.UploadRepository)
+	mockFileUseCase := new(mocks.FileUseCase)
+
+	useCase := NewUploadUseCase(mockUserRepo, mockUploadRepo, mockFileUseCase, time.Hour, 2*time.Second)
+
+	mockUploadRepo.On("GetUploadByID", mock.Anything, "up1").Return(&domain.FileUpload{
+		ID:        "up1",
+		UserID:    1,
+		Length:    10,
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}, nil)
+
+	_, err := useCase.WriteChunk(context.Background(), 1, "up1", 0, strings.NewReader("data"))
+
+	require.ErrorIs(t, err, domain.ErrUploadExpired)
+}
+
+func TestWriteChunk_LastChunkStoresFile(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockUploadRepo := new(mocks.UploadRepository)
+	mockFileUseCase := new(mocks.FileUseCase)
+
+	useCase := NewUploadUseCase(mockUserRepo, mockUploadRepo, mockFileUseCase, time.Hour, 2*time.Second)
+
+	upload := &domain.FileUpload{
+		ID:          "up1",
+		UserID:      1,
+		Filename:    "file.txt",
+		ContentType: "text/plain",
+		Length:      8,
+		Offset:      4,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	content := io.NopCloser(strings.NewReader("datadata"))
+
+	mockUploadRepo.On("GetUploadByID", mock.Anything, "up1").Return(upload, nil)
+	mockUploadRepo.On("AppendChunk", mock.Anything, upload, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.FileUpload).Offset += 4
+		}).
+		Return(int64(4), nil)
+	mockUploadRepo.On("OpenUploadContent", mock.Anything, upload).Return(content, nil)
+	mockFileUseCase.On("UploadFile", mock.Anything, uint(1), uint(1), "", "file.txt", "text/plain", []string(nil), (*time.Time)(nil), []domain.ContentDigest(nil), content).
+		Return(&domain.UserFileMeta{ID: "file1", Filename: "file.txt"}, nil)
+	mockUploadRepo.On("CompleteUpload", mock.Anything, upload, "file1").Return(nil)
+
+	result, err := useCase.WriteChunk(context.Background(), 1, "up1", 4, strings.NewReader("data"))
+
+	require.NoError(t, err)
+	require.Equal(t, int64(8), result.Offset)
+	mockUploadRepo.AssertExpectations(t)
+	mockFileUseCase.AssertExpectations(t)
+}
+
+func TestWriteChunk_RejectedFileDeletesUpload(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockUploadRepo := new(mocks.UploadRepository)
+	mockFileUseCase := new(mocks.FileUseCase)
+
+	useCase := NewUploadUseCase(mockUserRepo, mockUploadRepo, mockFileUseCase, time.Hour, 2*time.Second)
+
+	upload := &domain.FileUpload{
+		ID:        "up1",
+		UserID:    1,
+		Filename:  "file.txt",
+		Length:    4,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	content := io.NopCloser(strings.NewReader("data"))
+
+	mockUploadRepo.On("GetUploadByID", mock.Anything, "up1").Return(upload, nil)
+	mockUploadRepo.On("AppendChunk", mock.Anything, upload, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.FileUpload).Offset += 4
+		}).
+		Return(int64(4), nil)
+	mockUploadRepo.On("OpenUploadContent", mock.Anything, upload).Return(content, nil)
+	mockFileUseCase.On("UploadFile", mock.Anything, uint(1), uint(1), "", "file.txt", "", []string(nil), (*time.Time)(nil), []domain.ContentDigest(nil), content).
+		Return(nil, domain.ErrDigestMismatch)
+	mockUploadRepo.On("DeleteUpload", mock.Anything, upload).Return(nil)
+
+	_, err := useCase.WriteChunk(context.Background(), 1, "up1", 0, strings.NewReader("data"))
+
+	require.ErrorIs(t, err, domain.ErrDigestMismatch)
+	mockUploadRepo.AssertCalled(t, "DeleteUpload", mock.Anything, upload)
+	mockUploadRepo.AssertNotCalled(t, "CompleteUpload", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWriteChunk_StoreFailureKeepsUpload(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockUploadRepo := new(mocks.UploadRepository)
+	mockFileUseCase := new(mocks.FileUseCase)
+
+	useCase := NewUploadUseCase(mockUserRepo, mockUploadRepo, mockFileUseCase, time.Hour, 2*time.Second)
+
+	upload := &domain.FileUpload{
+		ID:        "up1",
+		UserID:    1,
+		Filename:  "file.txt",
+		Length:    4,
+		Offset:    4,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	content := io.NopCloser(strings.NewReader("data"))
+
+	mockUploadRepo.On("GetUploadByID", mock.Anything, "up1").Return(upload, nil)
+	mockUploadRepo.On("AppendChunk", mock.Anything, upload, mock.Anything, mock.Anything).Return(int64(0), nil)
+	mockUploadRepo.On("OpenUploadContent", mock.Anything, upload).Return(content, nil)
+	mockFileUseCase.On("UploadFile", mock.Anything, uint(1), uint(1), "", "file.txt", "", []string(nil), (*time.Time)(nil), []domain.ContentDigest(nil), content).
+		Return(nil, errors.New("connection reset"))
+
+	// An empty chunk at the full length retries storing the file
+	result, err := useCase.WriteChunk(context.Background(), 1, "up1", 4, strings.NewReader(""))
+
+	require.EqualError(t, err, "connection reset")
+	require.True(t, result.Stalled())
+	mockUploadRepo.AssertNotCalled(t, "DeleteUpload", mock.Anything, mock.Anything)
+}
+
+func TestPurgeExpiredUploads(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockUploadRepo := new(mocks.UploadRepository)
+	mockFileUseCase := new(mocks.FileUseCase)
+
+	useCase := NewUploadUseCase(mockUserRepo, mockUploadRepo, mockFileUseCase, time.Hour, 2*time.Second)
+
+	expired := []*domain.FileUpload{{ID: "up1"}, {ID: "up2"}}
+	mockUploadRepo.On("GetExpiredUploads", mock.Anything, mock.Anything).Return(expired, nil)
+	mockUploadRepo.On("DeleteUpload", mock.Anything, mock.Anything).Return(nil)
+
+	purged, err := useCase.PurgeExpiredUploads(context.Background())
+
+	require.NoError(t, err)
+	require.Equal(t, 2, purged)
+	mockUploadRepo.AssertNumberOfCalls(t, "DeleteUpload", 2)
+}
+
+func TestWriteChunk_OtherCaller(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	mockUploadRepo := new(mocks.UploadRepository)
+	mockFileUseCase := new(mocks.FileUseCase)
+
+	useCase := NewUploadUseCase(mockUserRepo, mockUploadRepo, mockFileUseCase, time.Hour, 2*time.Second)
+
+	mockUploadRepo.On("GetUploadByID", mock.Anything, "up1").Return(&domain.FileUpload{
+		ID:        "up1",
+		UserID:    1,
+		CreatedBy: 3,
+		Length:    10,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}, nil)
+
+	_, err := useCase.WriteChunk(context.Background(), 1, "up1", 0, strings.NewReader("data"))
+
+	require.ErrorIs(t, err, domain.ErrForbidden)
+	mockUploadRepo.AssertNotCalled(t, "AppendChunk", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -127,16 +127,9 @@ func (u *userUseCase) UpdateUser(c context.Context, req domain.UpdateRequest) er
 }
 
 func (u *userUseCase) Login(ctx context.Context, request domain.LoginRequest) (string, string, error) {
-	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
-	defer cancel()
-
-	user, err := u.userRepository.GetUserByEmail(ctx, request.Email)
+	user, err := u.Authenticate(ctx, request)
 	if err != nil {
-		return "", "", errors.New("user does not exist")
-	}
-
-	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)) != nil {
-		return "", "", errors.New("invalid password")
+		return "", "", err
 	}
 
 	accessToken, err := utils.CreateAccessToken(user, u.env.AccessTokenSecret, 5)
@@ -152,6 +145,22 @@ func (u *userUseCase) Login(ctx context.Context, request domain.LoginRequest) (s
 	return accessToken, refreshToken, nil
 }
 
+func (u *userUseCase) Authenticate(ctx context.Context, request domain.LoginRequest) (*domain.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
+	defer cancel()
+
+	user, err := u.userRepository.GetUserByEmail(ctx, request.Email)
+	if err != nil {
+		return nil, errors.New("user does not exist")
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)) != nil {
+		return nil, errors.New("invalid password")
+	}
+
+	return user, nil
+}
+
 func (u *userUseCase) DeleteUser(ctx context.Context, id uint) error {
 	ctx, cancel := context.WithTimeout(ctx, u.contextTimeout)
 	defer cancel()
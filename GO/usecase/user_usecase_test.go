@@ -11,6 +11,7 @@ import (
 	"github.com/OgiDac/CompanyTask/mocks"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func getTestEnv() *config.Env {
@@ -132,3 +133,33 @@ func TestDeleteUser_Success(t *testing.T) {
 
 	mockUserRepo.AssertExpectations(t)
 }
+
+func TestAuthenticate_Success(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	useCase := NewUserUseCase(mockUserRepo, &mocks.Publisher{}, 2*time.Second, getTestEnv())
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("securepassword"), bcrypt.MinCost)
+	require.NoError(t, err)
+	mockUserRepo.On("GetUserByEmail", mock.Anything, "john@example.com").
+		Return(&domain.User{ID: 7, Email: "john@example.com", Password: string(hash)}, nil)
+
+	user, err := useCase.Authenticate(context.Background(), domain.LoginRequest{Email: "john@example.com", Password: "securepassword"})
+
+	require.NoError(t, err)
+	require.Equal(t, uint(7), user.ID)
+}
+
+func TestAuthenticate_InvalidPassword(t *testing.T) {
+	mockUserRepo := new(mocks.UserRepository)
+	useCase := NewUserUseCase(mockUserRepo, &mocks.Publisher{}, 2*time.Second, getTestEnv())
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("securepassword"), bcrypt.MinCost)
+	require.NoError(t, err)
+	mockUserRepo.On("GetUserByEmail", mock.Anything, "john@example.com").
+		Return(&domain.User{ID: 7, Email: "john@example.com", Password: string(hash)}, nil)
+
+	user, err := useCase.Authenticate(context.Background(), domain.LoginRequest{Email: "john@example.com", Password: "wrong"})
+
+	require.EqualError(t, err, "invalid password")
+	require.Nil(t, user)
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseUserIDs parses a comma separated list of user IDs, skipping empty
+// entries.
+func ParseUserIDs(value string) ([]uint, error) {
+	var ids []uint
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(item, 10, 0)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("invalid user ID %q", item)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}